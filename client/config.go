@@ -0,0 +1,66 @@
+// Package client has helpers to create the Kubernetes clients used by the controllers.
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+)
+
+// Config is the configuration used to build the Kubernetes client configuration.
+type Config struct {
+	// KubeConfig is the kubeconfig file path used when not running inside a cluster.
+	// By default `$HOME/.kube/config` will be used.
+	KubeConfig string
+	// ImpersonateUser is the user that the client will impersonate (e.g: for auditing).
+	ImpersonateUser string
+	// ImpersonateGroups are the groups that the client will impersonate.
+	ImpersonateGroups []string
+	// QPS is the maximum queries per second to the Kubernetes API server.
+	// By default it will use the client-go defaults.
+	QPS float32
+	// Burst is the maximum burst of queries to the Kubernetes API server.
+	// By default it will use the client-go defaults.
+	Burst int
+}
+
+func (c *Config) setDefaults() {
+	if c.KubeConfig == "" {
+		c.KubeConfig = filepath.Join(homedir.HomeDir(), ".kube", "config")
+	}
+}
+
+// NewRESTConfig returns a Kubernetes client configuration. It will try using the in cluster
+// configuration first, if not available, it will fallback to the kubeconfig file.
+func NewRESTConfig(cfg Config) (*rest.Config, error) {
+	cfg.setDefaults()
+
+	restCfg, err := rest.InClusterConfig()
+	if err != nil {
+		// No in cluster? let's try locally.
+		restCfg, err = clientcmd.BuildConfigFromFlags("", cfg.KubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubernetes configuration: %w", err)
+		}
+	}
+
+	if cfg.ImpersonateUser != "" || len(cfg.ImpersonateGroups) > 0 {
+		restCfg.Impersonate = rest.ImpersonationConfig{
+			UserName: cfg.ImpersonateUser,
+			Groups:   cfg.ImpersonateGroups,
+		}
+	}
+
+	if cfg.QPS > 0 {
+		restCfg.QPS = cfg.QPS
+	}
+
+	if cfg.Burst > 0 {
+		restCfg.Burst = cfg.Burst
+	}
+
+	return restCfg, nil
+}
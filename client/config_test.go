@@ -0,0 +1,93 @@
+package client_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+
+	"github.com/spotahome/kooper/v2/client"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+func TestNewRESTConfig(t *testing.T) {
+	tests := map[string]struct {
+		cfg    client.Config
+		expCfg func(t *testing.T, cfg *rest.Config)
+	}{
+		"Without options the config should use the kubeconfig defaults.": {
+			cfg: client.Config{},
+			expCfg: func(t *testing.T, cfg *rest.Config) {
+				assert.Equal(t, "https://127.0.0.1:6443", cfg.Host)
+				assert.Equal(t, rest.ImpersonationConfig{}, cfg.Impersonate)
+				assert.Zero(t, cfg.QPS)
+				assert.Zero(t, cfg.Burst)
+			},
+		},
+
+		"Impersonation options should be set on the config.": {
+			cfg: client.Config{
+				ImpersonateUser:   "auditor",
+				ImpersonateGroups: []string{"group-0", "group-1"},
+			},
+			expCfg: func(t *testing.T, cfg *rest.Config) {
+				assert.Equal(t, "auditor", cfg.Impersonate.UserName)
+				assert.Equal(t, []string{"group-0", "group-1"}, cfg.Impersonate.Groups)
+			},
+		},
+
+		"QPS and burst options should be set on the config.": {
+			cfg: client.Config{
+				QPS:   42.5,
+				Burst: 100,
+			},
+			expCfg: func(t *testing.T, cfg *rest.Config) {
+				assert.Equal(t, float32(42.5), cfg.QPS)
+				assert.Equal(t, 100, cfg.Burst)
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			// Make sure we are not in cluster and use a test kubeconfig.
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			path := filepath.Join(t.TempDir(), "config")
+			require.NoError(os.WriteFile(path, []byte(testKubeConfig), 0600))
+			test.cfg.KubeConfig = path
+
+			cfg, err := client.NewRESTConfig(test.cfg)
+			require.NoError(err)
+			test.expCfg(t, cfg)
+		})
+	}
+}
+
+func TestNewRESTConfigMissingKubeConfig(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	_, err := client.NewRESTConfig(client.Config{KubeConfig: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// runAutoScaledWorkers runs the workers scaling them between the min and max workers based on
+// the queued objects, until the context is done.
+//
+// The workers are retired cancelling their context, so they finish their current object before
+// exiting. The queue never gives the same object to multiple workers at the same time, so the
+// objects are not handled concurrently while scaling.
+func (g *generic) runAutoScaledWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// The worker IDs select the worker handler (`HandlerFactory`), so an ID is only reused once the
+	// goroutine of the retired worker using it has exited (e.g: it can be blocked waiting for an object).
+	var freeMu sync.Mutex
+	freeIDs := make([]int, 0, g.cfg.MaxWorkers)
+	for i := g.cfg.MaxWorkers - 1; i >= 0; i-- {
+		freeIDs = append(freeIDs, i)
+	}
+	takeID := func() (int, bool) {
+		freeMu.Lock()
+		defer freeMu.Unlock()
+		if len(freeIDs) == 0 {
+			return 0, false
+		}
+		id := freeIDs[len(freeIDs)-1]
+		freeIDs = freeIDs[:len(freeIDs)-1]
+		return id, true
+	}
+	releaseID := func(id int) {
+		freeMu.Lock()
+		defer freeMu.Unlock()
+		freeIDs = append(freeIDs, id)
+	}
+
+	workers := []context.CancelFunc{}
+	scale := func(n int) {
+		for len(workers) < n {
+			// All the IDs are in use by running or retiring workers, try again on the next scale.
+			worker, ok := takeID()
+			if !ok {
+				break
+			}
+			workerCtx, cancel := context.WithCancel(ctx)
+			workers = append(workers, cancel)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer releaseID(worker)
+				wait.Until(func() { g.runWorker(workerCtx, worker) }, time.Second, workerCtx.Done())
+			}()
+		}
+
+		for len(workers) > n {
+			last := len(workers) - 1
+			workers[last]()
+			workers = workers[:last]
+		}
+	}
+	scale(g.cfg.MinWorkers)
+
+	ticker := time.NewTicker(g.cfg.AutoScaleInterval)
+	defer ticker.Stop()
+
+	// Only scale when the queue has the same state on two consecutive checks (sustained).
+	lastWaiting := 0
+	for {
+		select {
+		case <-ctx.Done():
+			scale(0)
+			return
+		case <-ticker.C:
+		}
+
+		waiting := g.queue.Len(ctx)
+		current := len(workers)
+		n := current
+		switch {
+		case waiting > 0 && lastWaiting > 0:
+			n = current + waiting
+			if n > g.cfg.MaxWorkers {
+				n = g.cfg.MaxWorkers
+			}
+		case waiting == 0 && lastWaiting == 0 && current > g.cfg.MinWorkers:
+			n = current - 1
+		}
+		lastWaiting = waiting
+
+		if n != current {
+			g.logger.Infof("scaling workers from %d to %d", current, n)
+			scale(n)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"reflect"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ChangeDetector knows if an object has changed on an event, returning false
+// means that the event will be ignored. On add events the old object is nil.
+type ChangeDetector func(old, new runtime.Object) bool
+
+// GenerationChanged is a ChangeDetector that detects changes only when the object generation
+// changes. On CRDs with status subresource the generation is only bumped on spec changes, so
+// this is the idiomatic way of ignoring status only updates.
+var GenerationChanged ChangeDetector = func(old, new runtime.Object) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+
+	return oldMeta.GetGeneration() != newMeta.GetGeneration()
+}
+
+// AnnotationsChanged is a ChangeDetector that detects changes only when the object annotations
+// change.
+var AnnotationsChanged ChangeDetector = func(old, new runtime.Object) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+
+	return !reflect.DeepEqual(oldMeta.GetAnnotations(), newMeta.GetAnnotations())
+}
+
+// SemanticChanged is a ChangeDetector that detects changes only when the objects are different
+// ignoring the managed fields, the resource version and the status. Used as the `UpdateChangeDetector`,
+// avoids handling the updates without semantic changes (e.g: the ones made by apply based tooling
+// that only change the managed fields).
+var SemanticChanged ChangeDetector = func(old, new runtime.Object) bool {
+	if old == nil || new == nil {
+		return true
+	}
+
+	oldContent, err := semanticContent(old)
+	if err != nil {
+		return true
+	}
+	newContent, err := semanticContent(new)
+	if err != nil {
+		return true
+	}
+
+	return !reflect.DeepEqual(oldContent, newContent)
+}
+
+// semanticContent returns the unstructured content of an object without the fields that are
+// ignored by `SemanticChanged`.
+func semanticContent(obj runtime.Object) (map[string]interface{}, error) {
+	// The unstructured objects content is not copied by the converter, and they are shared.
+	if _, ok := obj.(runtime.Unstructured); ok {
+		obj = obj.DeepCopyObject()
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(content, "status")
+	if objMeta, ok := content["metadata"].(map[string]interface{}); ok {
+		delete(objMeta, "managedFields")
+		delete(objMeta, "resourceVersion")
+	}
+
+	return content, nil
+}
+
+// LabelsMatch returns a ChangeDetector that detects changes only when the new object labels
+// match the selector, so the events of the objects that don't match will be ignored.
+func LabelsMatch(selector labels.Selector) ChangeDetector {
+	return func(_, new runtime.Object) bool {
+		newMeta, err := meta.Accessor(new)
+		if err != nil {
+			return false
+		}
+
+		return selector.Matches(labels.Set(newMeta.GetLabels()))
+	}
+}
+
+// AllChanged returns a ChangeDetector that detects changes only when all the detectors detect
+// a change.
+func AllChanged(detectors ...ChangeDetector) ChangeDetector {
+	return func(old, new runtime.Object) bool {
+		for _, d := range detectors {
+			if !d(old, new) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyChanged returns a ChangeDetector that detects changes when any of the detectors detects
+// a change.
+func AnyChanged(detectors ...ChangeDetector) ChangeDetector {
+	return func(old, new runtime.Object) bool {
+		for _, d := range detectors {
+			if d(old, new) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ReconciledGenerationAnnotation is the annotation that stores the last object generation that
+// has been reconciled.
+const ReconciledGenerationAnnotation = "kooper.io/reconciled-generation"
+
+// MarkReconciled records the current generation of the object as reconciled, the object needs
+// to be updated afterwards to persist the annotation.
+func MarkReconciled(obj metav1.Object) {
+	ann := obj.GetAnnotations()
+	if ann == nil {
+		ann = map[string]string{}
+	}
+	ann[ReconciledGenerationAnnotation] = strconv.FormatInt(obj.GetGeneration(), 10)
+	obj.SetAnnotations(ann)
+}
+
+// IsReconciled returns true if the current generation of the object has been marked as reconciled.
+func IsReconciled(obj metav1.Object) bool {
+	gen, ok := obj.GetAnnotations()[ReconciledGenerationAnnotation]
+	return ok && gen == strconv.FormatInt(obj.GetGeneration(), 10)
+}
+
+// NotReconciled is a ChangeDetector that detects changes only when the current generation of
+// the object has not been marked as reconciled (using `MarkReconciled`). Used as the
+// `AddChangeDetector`, avoids reprocessing the already reconciled objects when the controller
+// restarts.
+var NotReconciled ChangeDetector = func(_, new runtime.Object) bool {
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+
+	return !IsReconciled(newMeta)
+}
+
+// isResync returns true if the update event is a resync of the same object.
+func isResync(old, new interface{}) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return false
+	}
+
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
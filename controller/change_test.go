@@ -0,0 +1,385 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenerationChanged(t *testing.T) {
+	tests := map[string]struct {
+		old    runtime.Object
+		new    runtime.Object
+		expRes bool
+	}{
+		"Same generation should not be a change.": {
+			old:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: 1, ResourceVersion: "1"}},
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: 1, ResourceVersion: "2"}},
+			expRes: false,
+		},
+
+		"Different generation should be a change.": {
+			old:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: 1, ResourceVersion: "1"}},
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: 2, ResourceVersion: "2"}},
+			expRes: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expRes, controller.GenerationChanged(test.old, test.new))
+		})
+	}
+}
+
+func TestAnnotationsChanged(t *testing.T) {
+	tests := map[string]struct {
+		old    runtime.Object
+		new    runtime.Object
+		expRes bool
+	}{
+		"Same annotations should not be a change.": {
+			old:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1"}, Generation: 1}},
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1"}, Generation: 2}},
+			expRes: false,
+		},
+
+		"Different annotations should be a change.": {
+			old:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1"}}},
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "2"}}},
+			expRes: true,
+		},
+
+		"Without old object (add event) should be a change.": {
+			new:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1"}}},
+			expRes: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expRes, controller.AnnotationsChanged(test.old, test.new))
+		})
+	}
+}
+
+func TestSemanticChanged(t *testing.T) {
+	newDeployment := func(replicas int32, rv string, managedBy string, readyReplicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "test",
+				ResourceVersion: rv,
+				ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: managedBy, Operation: metav1.ManagedFieldsOperationApply}},
+			},
+			Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: readyReplicas},
+		}
+	}
+
+	tests := map[string]struct {
+		old    runtime.Object
+		new    runtime.Object
+		expRes bool
+	}{
+		"An update that only changes the managed fields and resource version should not be a change.": {
+			old:    newDeployment(1, "1", "kubectl", 0),
+			new:    newDeployment(1, "2", "helm", 0),
+			expRes: false,
+		},
+
+		"An update that only changes the status should not be a change.": {
+			old:    newDeployment(1, "1", "kubectl", 0),
+			new:    newDeployment(1, "2", "kubectl", 1),
+			expRes: false,
+		},
+
+		"An update that changes the spec should be a change.": {
+			old:    newDeployment(1, "1", "kubectl", 0),
+			new:    newDeployment(2, "2", "kubectl", 0),
+			expRes: true,
+		},
+
+		"An unstructured update that only changes the managed fields should not be a change.": {
+			old: &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "test", "resourceVersion": "1", "managedFields": []interface{}{"kubectl"}},
+				"spec":     map[string]interface{}{"replicas": int64(1)},
+			}},
+			new: &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "test", "resourceVersion": "2", "managedFields": []interface{}{"helm"}},
+				"spec":     map[string]interface{}{"replicas": int64(1)},
+			}},
+			expRes: false,
+		},
+
+		"Without old object (add event) should be a change.": {
+			new:    newDeployment(1, "1", "kubectl", 0),
+			expRes: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var oldCopy, newCopy runtime.Object
+			if test.old != nil {
+				oldCopy = test.old.DeepCopyObject()
+			}
+			newCopy = test.new.DeepCopyObject()
+
+			assert.Equal(test.expRes, controller.SemanticChanged(test.old, test.new))
+
+			// The objects should not be mutated.
+			assert.Equal(oldCopy, test.old)
+			assert.Equal(newCopy, test.new)
+		})
+	}
+}
+
+func TestLabelsMatch(t *testing.T) {
+	tests := map[string]struct {
+		selector labels.Selector
+		new      runtime.Object
+		expRes   bool
+	}{
+		"Objects matching the selector should be a change.": {
+			selector: labels.SelectorFromSet(labels.Set{"app": "test"}),
+			new:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test", "other": "1"}}},
+			expRes:   true,
+		},
+
+		"Objects not matching the selector should not be a change.": {
+			selector: labels.SelectorFromSet(labels.Set{"app": "test"}),
+			new:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "other"}}},
+			expRes:   false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expRes, controller.LabelsMatch(test.selector)(nil, test.new))
+		})
+	}
+}
+
+func TestChangeDetectorsComposition(t *testing.T) {
+	yes := controller.ChangeDetector(func(_, _ runtime.Object) bool { return true })
+	no := controller.ChangeDetector(func(_, _ runtime.Object) bool { return false })
+
+	tests := map[string]struct {
+		detector controller.ChangeDetector
+		expRes   bool
+	}{
+		"All changed with all detecting a change should be a change.": {
+			detector: controller.AllChanged(yes, yes),
+			expRes:   true,
+		},
+
+		"All changed with one not detecting a change should not be a change.": {
+			detector: controller.AllChanged(yes, no),
+			expRes:   false,
+		},
+
+		"Any changed with one detecting a change should be a change.": {
+			detector: controller.AnyChanged(no, yes),
+			expRes:   true,
+		},
+
+		"Any changed without any detecting a change should not be a change.": {
+			detector: controller.AnyChanged(no, no),
+			expRes:   false,
+		},
+
+		"Composed detectors should be a change based on the object.": {
+			detector: controller.AllChanged(
+				controller.LabelsMatch(labels.SelectorFromSet(labels.Set{"app": "test"})),
+				controller.AnyChanged(controller.GenerationChanged, controller.AnnotationsChanged),
+			),
+			expRes: true,
+		},
+	}
+
+	old := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test"}, Generation: 1}}
+	new := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test"}, Generation: 1, Annotations: map[string]string{"a": "1"}}}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expRes, test.detector(old, new))
+		})
+	}
+}
+
+func TestGenericControllerUpdateChangeDetector(t *testing.T) {
+	tests := map[string]struct {
+		update     func(pod *corev1.Pod)
+		expHandled int
+	}{
+		"A status only update (same generation) should not be handled.": {
+			update: func(pod *corev1.Pod) {
+				pod.ResourceVersion = "2"
+				pod.Status.Phase = corev1.PodRunning
+			},
+			expHandled: 1,
+		},
+
+		"A spec update (new generation) should be handled.": {
+			update: func(pod *corev1.Pod) {
+				pod.ResourceVersion = "2"
+				pod.Generation = 2
+				pod.Spec.Hostname = "test"
+			},
+			expHandled: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			pod := newPod("test", "test")
+			pod.Generation = 1
+			pod.ResourceVersion = "1"
+			mc := fake.NewSimpleClientset(pod)
+
+			var mu sync.Mutex
+			handled := 0
+			getHandled := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return handled
+			}
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled++
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            newPodRetriever(mc),
+				UpdateChangeDetector: controller.GenerationChanged,
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Wait for the initial add.
+			require.Eventually(func() bool { return getHandled() == 1 }, 1*time.Second, 5*time.Millisecond)
+
+			newPod := pod.DeepCopy()
+			test.update(newPod)
+			_, err = mc.CoreV1().Pods("test").Update(ctx, newPod, metav1.UpdateOptions{})
+			require.NoError(err)
+
+			time.Sleep(100 * time.Millisecond)
+			assert.Equal(test.expHandled, getHandled())
+		})
+	}
+}
+
+func TestNotReconciled(t *testing.T) {
+	reconciledPod := func(gen, reconciledGen int64) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: reconciledGen}}
+		controller.MarkReconciled(pod)
+		pod.Generation = gen
+		return pod
+	}
+
+	tests := map[string]struct {
+		obj    runtime.Object
+		expRes bool
+	}{
+		"An object without reconciled mark should be a change.": {
+			obj:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Generation: 1}},
+			expRes: true,
+		},
+
+		"An object with its generation marked as reconciled should not be a change.": {
+			obj:    reconciledPod(2, 2),
+			expRes: false,
+		},
+
+		"An object with a previous generation marked as reconciled should be a change.": {
+			obj:    reconciledPod(3, 2),
+			expRes: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expRes, controller.NotReconciled(nil, test.obj))
+		})
+	}
+}
+
+func TestGenericControllerAddChangeDetectorSkipsReconciled(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	// Reconciled by a previous controller.
+	reconciled := newPod("test", "reconciled")
+	reconciled.Generation = 2
+	controller.MarkReconciled(reconciled)
+
+	// Reconciled by a previous controller but changed afterwards.
+	changed := newPod("test", "changed")
+	changed.Generation = 1
+	controller.MarkReconciled(changed)
+	changed.Generation = 2
+
+	// Never reconciled.
+	unreconciled := newPod("test", "unreconciled")
+	unreconciled.Generation = 1
+
+	mc := fake.NewSimpleClientset(reconciled, changed, unreconciled)
+
+	var mu sync.Mutex
+	handled := []string{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, obj.(*corev1.Pod).Name)
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newPodRetriever(mc),
+		AddChangeDetector: controller.NotReconciled,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	}, 1*time.Second, 5*time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch([]string{"changed", "unreconciled"}, handled)
+}
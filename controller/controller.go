@@ -5,14 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 
 	"github.com/spotahome/kooper/v2/controller/leaderelection"
 	"github.com/spotahome/kooper/v2/log"
@@ -21,21 +27,136 @@ import (
 var (
 	// ErrControllerNotValid will be used when the controller has invalid configuration.
 	ErrControllerNotValid = errors.New("controller not valid")
+	// ErrCacheSyncTimeout will be used when the controller stops because the cache has not been
+	// synced within the `CacheSyncTimeout`.
+	ErrCacheSyncTimeout = errors.New("timed out waiting for caches to sync")
+	// ErrFatalWatch will be used when the controller stops because of a watch error that
+	// `IsFatalWatchError` considers fatal.
+	ErrFatalWatch = errors.New("fatal watch error")
 )
 
 // Controller is the object that will implement the different kinds of controllers that will be running
 // on the application.
 type Controller interface {
-	// Run runs the controller and blocks until the context is `Done`.
+	// Run runs the controller and blocks until the context is `Done`. It returns nil when the controller
+	// is stopped with the context, and an error if it stops for another reason (e.g: `ErrCacheSyncTimeout`,
+	// `ErrFatalWatch`).
 	Run(ctx context.Context) error
 }
 
+// The controllers returned by `New` implement the next optional interfaces, these can be used
+// type asserting the controller (e.g: `ctrl.(controller.Pauser)`).
+
+// Pauser is the optional interface of the controllers that can pause the handling of namespaces.
+type Pauser interface {
+	// PauseNamespace pauses the handling of the objects of a namespace, the events of these objects
+	// will be held (not dropped) until the namespace is resumed.
+	PauseNamespace(namespace string)
+	// ResumeNamespace resumes the handling of the objects of a paused namespace.
+	ResumeNamespace(namespace string)
+}
+
+// KeyStatuser is the optional interface of the controllers that report the status of the object keys.
+type KeyStatuser interface {
+	// KeyStatus returns the processing status of an object key.
+	KeyStatus(key string) KeyStatus
+	// QueueSnapshot returns a best-effort snapshot of the keys known by the queue (queued, processing
+	// and failing) sorted by key, this is useful for diagnostics (e.g: a debug endpoint).
+	QueueSnapshot() []QueueItem
+}
+
+// ObjectAdder is the optional interface of the controllers that can add objects for processing.
+type ObjectAdder interface {
+	// AddForProcessing adds an object to the controller cache and queues it as if an add event
+	// had been received, this is useful to drive the handling on tests without a real informer.
+	// It waits until the controller cache has been synced, the objects not returned by the retriever
+	// will be removed from the cache on a relist.
+	AddForProcessing(ctx context.Context, obj runtime.Object) error
+}
+
+// KeyWaiter is the optional interface of the controllers that can wait for the handling of object keys.
+type KeyWaiter interface {
+	// WaitForKey blocks until the object key is handled successfully the next time, or the
+	// context is done. This is useful to wait for the handling of objects on tests.
+	WaitForKey(ctx context.Context, key string) error
+}
+
+// SelectorUpdater is the optional interface of the controllers that can update their label selector.
+type SelectorUpdater interface {
+	// UpdateSelector updates the label selector of the controller while running, the objects will be
+	// listed again with the new selector, keeping the queue and the workers running. The objects that
+	// don't match the new selector will be handled as deleted (`DeleteHandler`).
+	UpdateSelector(selector labels.Selector)
+}
+
+// SyncNotifier is the optional interface of the controllers that notify when they are synced and ready.
+type SyncNotifier interface {
+	// Synced returns a channel that will be closed when the controller cache has been synced for
+	// the first time, this can be used by other controllers to depend on it (`DependsOn`).
+	Synced() <-chan struct{}
+	// Ready returns a channel that will be closed when the controller is ready for the first time, this
+	// is when the workers start or, if `ReadyAfterKey` is set, when the key has been handled successfully.
+	// This can be used for the readiness checks of the application.
+	Ready() <-chan struct{}
+}
+
+// Seeder is the optional interface of the controllers that can be seeded with objects before running.
+type Seeder interface {
+	// SeedObjects adds objects to the controller cache and queues them before running the controller
+	// (e.g: a snapshot loaded from disk on warm starts), so they are handled without waiting for the
+	// cache sync. The first list of the informer will update the seeded objects, and the ones that
+	// don't exist anymore will be handled as deleted.
+	SeedObjects(objs []runtime.Object) error
+}
+
+// Drainer is the optional interface of the controllers that can be stopped draining their queue.
+type Drainer interface {
+	// StopAndDrain stops the running controller and returns the sorted keys that were queued but not
+	// handled successfully (queued, failing and the ones whose handling failed while stopping), so they
+	// can be handed off to other process (e.g: a blue/green deployment). Like `Run`, it waits for the
+	// handlings in progress to finish, until the controller has stopped or the context is done.
+	StopAndDrain(ctx context.Context) ([]string, error)
+}
+
 // Config is the controller configuration.
 type Config struct {
 	// Handler is the controller handler.
 	Handler Handler
+	// ResultHandler is the controller handler that returns results, it can't be used
+	// at the same time as Handler.
+	ResultHandler ResultHandler
+	// HandlerFactory will create one handler per worker, so each worker uses its own handler
+	// instance and the handlers don't need to be safe for concurrent use. It can't be used at
+	// the same time as Handler or ResultHandler.
+	HandlerFactory func() Handler
 	// Retriever is the controller retriever.
 	Retriever Retriever
+	// LabelSelector is an optional label selector that will be set on the list options of the
+	// retriever, it can be updated while running with `SelectorUpdater.UpdateSelector`. The retriever
+	// must use the received list options label selector.
+	LabelSelector labels.Selector
+	// ListPageSize is the number of objects requested per page on the lists of the informer (e.g: the
+	// initial list), use it to avoid big list responses on big object sets. By default the Kubernetes
+	// client page size (500). The lists that the informer requests without pagination are not affected.
+	ListPageSize int64
+	// InitialSyncOrder is the order in which the listed objects will be queued (e.g: `ByCreationTimestamp`),
+	// instead of the retriever order, this is useful for reproducibility or to handle the objects with
+	// dependencies in order on the initial sync. The objects are sorted on each list (e.g: relists), the
+	// watch events are queued as received. Processing in this order requires a single worker and, when the
+	// lists are paginated (`ListPageSize`), the order is kept only within each page. By default not sorted.
+	InitialSyncOrder ObjectLess
+	// ListOptionsMutator is called with the list options before each list and watch call of the informer,
+	// after the controller has set its options (e.g: `LabelSelector`, `ListPageSize`), so they can be adjusted
+	// at call time (e.g: rotating field selectors).
+	ListOptionsMutator func(options *metav1.ListOptions)
+	// OnRelist is called when the informer has listed all the objects again after a watch reset (e.g: expired
+	// watch, connection errors), not on the initial list nor the periodic resyncs. The cache has the listed
+	// objects when called, use it to invalidate the downstream caches that could be stale.
+	OnRelist func()
+	// WatchTimeout is the duration of the informer watches, when it ends the watch is established again,
+	// this is useful to recover from watches hanging on flaky networks. By default the Kubernetes client
+	// uses a random duration between 5m and 10m. The timeout has seconds precision.
+	WatchTimeout time.Duration
 	// Leader elector will be used to use only one instance, if no set it will be
 	// leader election will be ignored
 	LeaderElector leaderelection.Runner
@@ -43,15 +164,251 @@ type Config struct {
 	MetricsRecorder MetricsRecorder
 	// Logger will log messages of the controller.
 	Logger log.Logger
+	// ObjectLocker is an optional lock that will be acquired per object before handling
+	// it and released afterwards, if the lock is held by someone else the object will be
+	// queued again after the `ObjectLockRetryInterval`. If not set, objects will be handled
+	// without a lock.
+	ObjectLocker ObjectLocker
+	// ObjectLockRetryInterval is the interval to try again the handling of the objects whose lock
+	// (`ObjectLocker`) is held by someone else. By default 1s.
+	ObjectLockRetryInterval time.Duration
 
 	// name of the controller.
 	Name string
 	// ConcurrentWorkers is the number of concurrent workers the controller will have running processing events.
+	// This is the maximum number of objects handled concurrently, the same object is never handled
+	// concurrently by multiple workers.
 	ConcurrentWorkers int
-	// ResyncInterval is the interval the controller will process all the selected resources.
+	// ConcurrencyLimiter is an optional limiter that will be acquired before handling each object, share
+	// it between multiple controllers to limit the objects handled concurrently by all of them.
+	ConcurrencyLimiter *ConcurrencyLimiter
+	// MaxReconcileRate is the maximum number of handlings per second that the controller will start,
+	// regardless of the workers and the queued objects (e.g: to protect a downstream API). The workers wait
+	// before handling the objects when the rate is reached. By default not limited.
+	MaxReconcileRate float64
+	// MaxReconcileBurst is the number of handlings that can be started at once over `MaxReconcileRate`.
+	// By default 1.
+	MaxReconcileBurst int
+	// InitialWorkers is the number of concurrent workers that will process the objects queued on the
+	// controller start (initial sync). The initial sync ends the first time a worker finds the queue
+	// empty, from that moment the controller scales down to `ConcurrentWorkers`, the extra workers
+	// exit after finishing their current object. If not set or lower than `ConcurrentWorkers`, the
+	// controller will always use `ConcurrentWorkers`. The initial workers share the queue with the rest,
+	// so the same object is never handled concurrently, even if it's queued again while being handled.
+	InitialWorkers int
+	// AutoScale will scale the number of workers between `MinWorkers` and `MaxWorkers` based on the queued
+	// objects, instead of using a fixed number of workers (`ConcurrentWorkers` and `InitialWorkers` are
+	// ignored). When the queue has waiting objects on two consecutive checks (`AutoScaleInterval`), a worker
+	// is added for each waiting object, and when the queue is empty on two consecutive checks, a worker is
+	// removed after finishing its current object. The same object is never handled concurrently while scaling.
+	AutoScale bool
+	// MinWorkers is the minimum number of workers used by `AutoScale`. By default 1.
+	MinWorkers int
+	// MaxWorkers is the maximum number of workers used by `AutoScale`. By default `ConcurrentWorkers`.
+	MaxWorkers int
+	// AutoScaleInterval is the interval of the `AutoScale` queue checks. By default 5s.
+	AutoScaleInterval time.Duration
+	// WaitUntilReady is an optional function that will be called after the cache sync and before
+	// starting the workers, the objects will not be handled until it returns (the events will be
+	// queued meanwhile). This can be used to wait for dependencies (e.g: a database). If it returns
+	// an error the controller will stop.
+	WaitUntilReady func(ctx context.Context) error
+	// ReadyAfterKey is an optional object key (e.g: a bootstrap object) that will be queued periodically
+	// after the workers start until it's handled successfully, the controller will not be ready (`Ready`)
+	// until then, so the readiness depends on a real handling and not only on the cache sync.
+	ReadyAfterKey string
+	// ReadyAfterKeyInterval is the interval the `ReadyAfterKey` will be queued until it's handled
+	// successfully. By default 10s.
+	ReadyAfterKeyInterval time.Duration
+	// StartupDelay is an optional fixed time to wait after the cache sync and before starting the
+	// workers (and before WaitUntilReady), prefer `WaitUntilReady` when readiness can be checked.
+	StartupDelay time.Duration
+	// DependsOn are optional signals (e.g: other controller `Synced`) that will be waited after the
+	// cache sync and before starting the workers (and before WaitUntilReady), the objects will not be
+	// handled until all the signals are closed.
+	DependsOn []<-chan struct{}
+	// CacheSyncTimeout is the maximum time the controller will wait for the initial cache sync (first
+	// list), if the cache is not synced within this time the controller will stop with an
+	// `ErrCacheSyncTimeout` error. By default it waits until the controller is stopped.
+	CacheSyncTimeout time.Duration
+	// IsFatalWatchError is an optional function that will be called with the errors of the informer
+	// list and watch requests, if it returns true the controller will stop with an `ErrFatalWatch` error
+	// (e.g: the resource doesn't exist or the controller doesn't have permissions). By default the
+	// informer retries on all the errors.
+	IsFatalWatchError func(err error) bool
+	// ResyncInterval is the interval the controller will process all the selected resources. If not
+	// set (0), it will use the default of 3m, to disable the resync use `DisableResync`.
 	ResyncInterval time.Duration
+	// SkipUnchangedResyncs will not handle the resynced objects whose version (`ObjectVersion`) is the same
+	// as the last successfully handled one. This reduces the resync load, but the handlers will not be
+	// called periodically to fix the drift of the objects that haven't changed (e.g: external resources).
+	// The duplicated events of the same object version received after handling it (e.g: the overlap of the
+	// initial list and watch) are handled as resyncs, so they are skipped too.
+	SkipUnchangedResyncs bool
+	// ObjectVersion is the version of the objects used by `SkipUnchangedResyncs`. By default `ResourceVersion`.
+	ObjectVersion ObjectVersion
+	// ReconciledStore stores the versions (`ObjectVersion`) of the successfully handled objects. When set, the
+	// first handling of each object after the controller starts is skipped if its version is the stored one, use
+	// a persistent store (e.g: `NewConfigMapReconciledStore`) to not handle the unchanged objects again after a
+	// restart. It's also used by `SkipUnchangedResyncs`. By default not set.
+	ReconciledStore ReconciledStore
+	// ReconciledStoreFlushInterval is the interval the changes of a buffered reconciled store
+	// (`BufferedReconciledStore`) are persisted, they are also persisted once the controller stops.
+	// By default 5s.
+	ReconciledStoreFlushInterval time.Duration
+	// ResyncEnqueueRate is the maximum number of objects per second that will be queued on each
+	// resync, this spreads the resync of big object sets over time instead of queueing all at once.
+	// The objects that can't be queued at this rate within the resync interval will be queued at the
+	// end of the interval. By default the resync objects are not rate limited.
+	ResyncEnqueueRate float64
+	// ResyncEnqueueJitter is the maximum random delay added to each rate limited resync enqueue.
+	ResyncEnqueueJitter time.Duration
+	// ResyncClassifier sets the resync interval of each object, so the objects are resynced at different
+	// intervals instead of all of them at the `ResyncInterval` (used by the unclassified objects). The
+	// intervals have a precision of a second. Can't be used with `DisableResync`. By default not set.
+	ResyncClassifier ResyncClassifier
+	// Clock is the clock used to schedule the classified resyncs (`ResyncClassifier`) and the reconciled store
+	// flushes (`ReconciledStoreFlushInterval`), and to measure the queued times of the objects (in queue duration
+	// metrics), useful to test them with a fake clock. By default the real clock.
+	Clock clock.Clock
+	// ProcessingTimeout is the maximum duration of the handling of an object, when reached the handling
+	// context will be cancelled. The objects can override it with the `ProcessingTimeoutAnnotation`
+	// annotation. By default there is no timeout.
+	ProcessingTimeout time.Duration
+	// MaxProcessingTimeout is the hard limit of the handling duration of an object, it limits the
+	// timeouts set by the objects with the `ProcessingTimeoutAnnotation` annotation. By default there
+	// is no limit.
+	MaxProcessingTimeout time.Duration
+	// RequeueAfterOnSuccess is the duration the objects will be queued again after being handled successfully
+	// without requesting a requeue (`Result.RequeueAfter`), this can be used to poll the objects that are
+	// not ready yet. By default the objects are not queued again.
+	RequeueAfterOnSuccess time.Duration
+	// MinRequeueAfter is the minimum requeue duration that the handlers can request (`Result.RequeueAfter`),
+	// the lower durations are raised to it. By default not limited.
+	MinRequeueAfter time.Duration
+	// MaxRequeueAfter is the maximum requeue duration that the handlers can request (`Result.RequeueAfter`),
+	// the higher durations are lowered to it. By default not limited.
+	MaxRequeueAfter time.Duration
+	// BeforeReconcile is an optional hook called with the object key before the handler, the returned
+	// context will be used by the handler and `AfterReconcile` (e.g: with an acquired resource). If it
+	// returns an error the handler is skipped and the error is handled as a handling error, except for
+	// `ErrSkipReconcile` that skips the handler successfully.
+	BeforeReconcile func(ctx context.Context, key string) (context.Context, error)
+	// AfterReconcile is an optional hook called with the object key and the handler error after the
+	// handler (e.g: to release the resources acquired by `BeforeReconcile`). It's not called when the
+	// handler is skipped by `BeforeReconcile`.
+	AfterReconcile func(ctx context.Context, key string, err error)
+	// ContextFactory is an optional func that returns the context of the handling of an object key from the
+	// parent context (e.g: with tenant scoped values or clients), the returned cancel func is called when the
+	// handling finishes. The rest of the handling contexts (e.g: the timeout) are derived from it.
+	ContextFactory func(parent context.Context, key string) (context.Context, context.CancelFunc)
+	// ReconcileEnabledAnnotation is the annotation that skips the handling of the objects when it's set to
+	// `false`, the skipped objects are handled as successful (without calling the hooks). By default
+	// `ReconcileEnabledAnnotation`.
+	ReconcileEnabledAnnotation string
 	// ProcessingJobRetries is the number of times the job will try to reprocess the event before returning a real error.
 	ProcessingJobRetries int
+	// DisableForgetOnNotFound will retry the handler errors that are Kubernetes not found errors (e.g: the
+	// object handled has been deleted meanwhile), by default these errors are not retried.
+	DisableForgetOnNotFound bool
+	// RetryPolicy decides if and when the failed objects will be retried, it replaces the default retries
+	// (`ProcessingJobRetries`, `DisableForgetOnNotFound` and `RetryRateLimiter`). By default the default
+	// retries are used.
+	RetryPolicy RetryPolicy
+	// RetryRateLimiter is the policy that will decide when a failed object will be processed again. By default
+	// an exponential backoff per object is used, so objects that fail repeatedly are deprioritized and don't
+	// starve the processing of the healthy objects. Use `NewFullJitterRateLimiter` to spread the retries
+	// of the objects failing at the same time.
+	RetryRateLimiter workqueue.RateLimiter
+	// RecoverHandlerPanics will recover the panics of the handlers and treat them as processing errors,
+	// by default the panics are not recovered.
+	RecoverHandlerPanics bool
+	// MaxHandlerPanics is the number of times the handling of an object can panic (without succeeding
+	// meanwhile) before the object is dropped without retrying it, the dropped objects are reported with
+	// a forgotten event (`OnEvent`) with an `ErrMaxHandlerPanicsReached` error. Only used when the panics
+	// are recovered. By default 3.
+	MaxHandlerPanics int
+	// DeleteHandler is an optional handler that will be called with the last known state of the deleted
+	// objects. The objects missing from the cache when they are processed are always handled by this
+	// handler, if the last known state is not available (e.g: dropped from the deleted objects cache),
+	// it will receive a `metav1.PartialObjectMetadata` with only the namespace and name. Deletions are
+	// best effort (e.g: a deletion is missed if the controller is not running when the object is deleted),
+	// use finalizers for reliable clean ups.
+	DeleteHandler Handler
+	// DeletedObjectsCacheSize is the maximum number of deleted objects that will be stored waiting to be
+	// handled by the DeleteHandler, when full, the oldest deleted objects will be dropped. By default 1000.
+	DeletedObjectsCacheSize int
+	// CancelOnDelete will cancel the handling context of an object when the object is deleted while
+	// being handled, so the handlers can stop early.
+	CancelOnDelete bool
+	// ErrorClassifier will categorize the handler errors for the metrics, only used if the metrics recorder
+	// implements `ErrorMetricsRecorder`. By default `DefaultErrorClassifier`.
+	ErrorClassifier ErrorClassifier
+	// DistinctErrorMetrics will measure only the distinct consecutive errors of each object (only used if the
+	// metrics recorder implements `ErrorMetricsRecorder`), so an object failing repeatedly with the same error
+	// is measured once, until it succeeds or fails with a different error. This measures the failing objects
+	// instead of the failed attempts (the retries are already measured by the queued events metrics).
+	DistinctErrorMetrics bool
+	// DeepCopyObjects will pass a deep copy of the cached objects to the handlers. By default the
+	// handlers receive the objects of the informer cache, these are shared, so the handlers must not
+	// mutate them (e.g: copy them before updating), otherwise the cache will be corrupted.
+	DeepCopyObjects bool
+	// ProfilingLabels will set the pprof labels of the controller name (`controller`) and the handled
+	// resource kind (`resource`) while handling the objects, so the CPU profiles attribute the handling
+	// time to the controllers and resources.
+	ProfilingLabels bool
+	// Scheme is the scheme used to resolve the GroupVersionKind of the handled objects that don't have
+	// the type information set (`GVKFromContext`). By default client-go kubernetes scheme.
+	Scheme *runtime.Scheme
+	// OnEvent is an optional hook that will be called on every lifecycle event of the objects
+	// (e.g: enqueued, started, failed...), it is called synchronously so it should be fast
+	// and safe for concurrent use. This is useful to assert the controller behavior on tests.
+	OnEvent func(Event)
+	// FaultInjector is an optional fault injector for chaos testing (e.g: `controllertest.NewFaultInjector`),
+	// it's called with the object key before handling it, and if it returns an error the handler is not
+	// called and the error is processed as a handling error. Don't use it on production.
+	FaultInjector FaultInjector
+	// ShardFilter is an optional filter that will be called with the object key before queueing an
+	// object event, if it returns false the event will be ignored. This can be used to shard the
+	// objects across multiple instances (e.g: consistent hashing of the keys based on the replica count).
+	ShardFilter func(key string) bool
+	// ObjectFilter is an optional filter that will be called with the objects before queueing their events
+	// (including the resyncs and deletes), if it returns false the events will be ignored, e.g: `OwnedBy` to
+	// only handle the objects owned by an operator resource. The objects that stop being accepted are not
+	// handled, not even as deleted.
+	ObjectFilter ObjectFilter
+	// KeyNormalizer is an optional function that will be called with the object keys before queueing
+	// them, the equivalent keys can be normalized to the same key so they are queued and handled once.
+	// The normalized key is the key of the object that will be handled from the controller cache, if
+	// there is no object with that key it will be handled as deleted.
+	KeyNormalizer func(key string) string
+	// OnIdle is an optional callback that will be called when the controller has processed all the
+	// queued objects (the queue is empty and there are no objects being processed). Objects waiting
+	// for a retry backoff are not taken into account.
+	OnIdle func(ctx context.Context)
+	// IdleDebounce is the time the controller needs to be idle before calling OnIdle, this
+	// avoids flapping between consecutive events. By default 1s.
+	IdleDebounce time.Duration
+	// UpdateChangeDetector is an optional change detector that will ignore the update events where
+	// the object hasn't changed (e.g `GenerationChanged`). Resyncs are not affected by the detector.
+	UpdateChangeDetector ChangeDetector
+	// AddChangeDetector is an optional change detector that will ignore the add events where the
+	// detector doesn't detect a change (e.g `NotReconciled`), the old object will be nil.
+	AddChangeDetector ChangeDetector
+	// LogUpdateDiffs will log the changes of the labels, the annotations and the spec fields of the handled
+	// update events (e.g: for auditing), the diff is shallow (by direct field). Resyncs are not logged.
+	LogUpdateDiffs bool
+	// UpdateDiffMaxSize is the maximum size of the logged update diffs (`LogUpdateDiffs`), the bigger diffs
+	// are truncated. By default 1024.
+	UpdateDiffMaxSize int
+	// ErrorLogRateLimitWindow is the window used to collapse the repeated object processing error
+	// logs (same object and error) into a single log line. By default 5s.
+	ErrorLogRateLimitWindow time.Duration
+	// LogRetriesAtLevel is the log level of the processing errors that will be retried, the errors of the
+	// objects that will not be retried anymore are logged at error level. By default warning level.
+	LogRetriesAtLevel log.Level
+	// DisableErrorLogRateLimit will disable the rate limit of the object processing error logs.
+	DisableErrorLogRateLimit bool
 	// DisableResync will disable resyncing, if disabled the controller only will react on event updates and resync
 	// all when it runs for the first time.
 	// This is useful for secondary resource controllers (e.g pod controller of a primary controller based on deployments).
@@ -63,10 +420,21 @@ func (c *Config) setDefaults() error {
 		return fmt.Errorf("a controller name is required")
 	}
 
-	if c.Handler == nil {
+	handlers := 0
+	for _, set := range []bool{c.Handler != nil, c.ResultHandler != nil, c.HandlerFactory != nil} {
+		if set {
+			handlers++
+		}
+	}
+
+	if handlers == 0 {
 		return fmt.Errorf("a handler is required")
 	}
 
+	if handlers > 1 {
+		return fmt.Errorf("handler, result handler and handler factory can't be used at the same time")
+	}
+
 	if c.Retriever == nil {
 		return fmt.Errorf("a retriever is required")
 	}
@@ -89,43 +457,254 @@ func (c *Config) setDefaults() error {
 		c.ConcurrentWorkers = 3
 	}
 
+	if c.AutoScale {
+		if c.MinWorkers <= 0 {
+			c.MinWorkers = 1
+		}
+
+		if c.MaxWorkers <= 0 {
+			c.MaxWorkers = c.ConcurrentWorkers
+		}
+
+		if c.MaxWorkers < c.MinWorkers {
+			return fmt.Errorf("max workers can't be lower than min workers")
+		}
+
+		if c.AutoScaleInterval <= 0 {
+			c.AutoScaleInterval = 5 * time.Second
+		}
+
+		// The maximum number of workers (e.g: worker handlers) is the same as the fixed workers.
+		c.ConcurrentWorkers = c.MaxWorkers
+		c.InitialWorkers = c.MaxWorkers
+	}
+
+	if c.InitialWorkers < c.ConcurrentWorkers {
+		c.InitialWorkers = c.ConcurrentWorkers
+	}
+
 	if c.ResyncInterval <= 0 {
 		c.ResyncInterval = 3 * time.Minute
 	}
 
 	if c.DisableResync {
+		if c.ResyncClassifier != nil {
+			return fmt.Errorf("resync classifier can't be used with the resync disabled")
+		}
 		c.ResyncInterval = 0 // 0 == resync disabled.
 	}
 
+	if c.Clock == nil {
+		c.Clock = clock.RealClock{}
+	}
+
+	if c.UpdateDiffMaxSize <= 0 {
+		c.UpdateDiffMaxSize = 1024
+	}
+
+	if c.ReconcileEnabledAnnotation == "" {
+		c.ReconcileEnabledAnnotation = ReconcileEnabledAnnotation
+	}
+
+	if c.Scheme == nil {
+		c.Scheme = scheme.Scheme
+	}
+
+	if c.MaxHandlerPanics <= 0 {
+		c.MaxHandlerPanics = 3
+	}
+
+	if c.DeletedObjectsCacheSize <= 0 {
+		c.DeletedObjectsCacheSize = 1000
+	}
+
+	if c.ErrorLogRateLimitWindow <= 0 {
+		c.ErrorLogRateLimitWindow = 5 * time.Second
+	}
+
+	if c.ReadyAfterKeyInterval <= 0 {
+		c.ReadyAfterKeyInterval = 10 * time.Second
+	}
+
+	if c.ObjectVersion == nil {
+		c.ObjectVersion = ResourceVersion
+	}
+
+	if c.ObjectLockRetryInterval <= 0 {
+		c.ObjectLockRetryInterval = 1 * time.Second
+	}
+
+	if c.ReconciledStoreFlushInterval <= 0 {
+		c.ReconciledStoreFlushInterval = 5 * time.Second
+	}
+
+	if c.MaxReconcileBurst <= 0 {
+		c.MaxReconcileBurst = 1
+	}
+
+	if c.MaxRequeueAfter > 0 && c.MaxRequeueAfter < c.MinRequeueAfter {
+		return fmt.Errorf("max requeue after can't be lower than min requeue after")
+	}
+
+	switch c.LogRetriesAtLevel {
+	case "":
+		c.LogRetriesAtLevel = log.LevelWarning
+	case log.LevelDebug, log.LevelInfo, log.LevelWarning, log.LevelError:
+	default:
+		return fmt.Errorf("invalid retries log level: %s", c.LogRetriesAtLevel)
+	}
+
+	if c.IdleDebounce <= 0 {
+		c.IdleDebounce = time.Second
+	}
+
 	if c.ProcessingJobRetries < 0 {
 		c.ProcessingJobRetries = 0
 	}
 
+	if c.ErrorClassifier == nil {
+		c.ErrorClassifier = DefaultErrorClassifier
+	}
+
+	if c.RetryRateLimiter == nil {
+		c.RetryRateLimiter = workqueue.DefaultControllerRateLimiter()
+	}
+
 	return nil
 }
 
+var (
+	_ Controller      = &generic{}
+	_ Pauser          = &generic{}
+	_ KeyStatuser     = &generic{}
+	_ ObjectAdder     = &generic{}
+	_ KeyWaiter       = &generic{}
+	_ SelectorUpdater = &generic{}
+	_ SyncNotifier    = &generic{}
+	_ Seeder          = &generic{}
+	_ Drainer         = &generic{}
+)
+
 // generic controller is a controller that can be used to create different kind of controllers.
 type generic struct {
 	queue     blockingQueue             // queue will have the jobs that the controller will get and send to handlers.
+	tracking  *trackingBlockingQueue    // tracking will know the status of the queued jobs.
 	informer  cache.SharedIndexInformer // informer will notify be inform us about resource changes.
 	processor processor                 // processor will call the user handler (logic).
+	pauser    *namespacePauser          // pauser will hold the objects of paused namespaces.
+	idle      *idleNotifier             // idle will notify when the controller has processed all the objects.
+	events    *eventNotifier            // events will notify the lifecycle events of the objects.
+	enqueue   func(key string)          // enqueue will queue the object keys owned by the controller.
+	triggers  *keyTriggers              // triggers will have the triggers of the queued object keys.
+	fatalC    chan error                // fatalC will receive the fatal watch errors of the informer.
+	selector  *listSelector             // selector is the label selector of the informer.
+	resyncer  *weightedResyncer         // resyncer will resync the classified objects.
+	flusher   *reconciledFlusher        // flusher will persist the buffered reconciled store.
+
+	syncedC    chan struct{} // syncedC will be closed when the cache has been synced for the first time.
+	syncedOnce sync.Once
+
+	readyC    chan struct{} // readyC will be closed when the controller is ready for the first time.
+	readyOnce sync.Once
+
+	initialSynced int32 // initialSynced will be set (atomically) when the initial sync objects have been processed.
+	seeded        int32 // seeded will be set (atomically) when the cache has been seeded with objects.
 
 	running   bool
+	stop      context.CancelFunc // stop will stop the current run.
+	stoppedC  chan struct{}      // stoppedC will be closed when the current run has stopped.
 	runningMu sync.Mutex
 	cfg       Config
 	metrics   MetricsRecorder
 	leRunner  leaderelection.Runner
 	logger    log.Logger
+	errLogger log.Logger // errLogger is the logger used for the processing errors.
 }
 
-func listerWatcherFromRetriever(ret Retriever) cache.ListerWatcher {
+// listerWatcherConfig is the configuration of the informer lister watcher.
+type listerWatcherConfig struct {
+	selector      *listSelector
+	listPageSize  int64
+	listOrder     ObjectLess
+	watchTimeout  time.Duration
+	mutateOptions func(*metav1.ListOptions)
+	onErr         func(error)
+	// onRelist is called when a watch starts after a list that is not the first one.
+	onRelist func()
+}
+
+func listerWatcherFromRetriever(ret Retriever, cfg listerWatcherConfig) cache.ListerWatcher {
+	if cfg.mutateOptions == nil {
+		cfg.mutateOptions = func(*metav1.ListOptions) {}
+	}
+	if cfg.onRelist == nil {
+		cfg.onRelist = func() {}
+	}
+
+	// The informer lists and watches from the same goroutine, the lock is only for safety.
+	var mu sync.Mutex
+	lists := 0
+	listed := false
+
 	// TODO(slok): pass context when Kubernetes updates its ListerWatchers ¯\_(ツ)_/¯.
 	return &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return ret.List(context.TODO(), options)
+			// Only set the page size on the paginated lists, an unset limit means the informer wants a
+			// full list (e.g: served from the API watch cache or a fallback of an expired paginated list).
+			if cfg.listPageSize > 0 && options.Limit > 0 {
+				options.Limit = cfg.listPageSize
+			}
+			cfg.selector.list(&options)
+			cfg.mutateOptions(&options)
+			obj, err := ret.List(context.TODO(), options)
+			if err != nil {
+				cfg.onErr(err)
+				return nil, err
+			}
+			if cfg.listOrder != nil {
+				err := sortList(obj, cfg.listOrder)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// A list is complete on its last page.
+			if l, err := meta.ListAccessor(obj); err == nil && l.GetContinue() == "" {
+				mu.Lock()
+				lists++
+				listed = true
+				mu.Unlock()
+			}
+
+			return obj, nil
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return ret.Watch(context.TODO(), options)
+			if cfg.watchTimeout > 0 {
+				timeoutSeconds := int64(cfg.watchTimeout.Seconds())
+				options.TimeoutSeconds = &timeoutSeconds
+			}
+			err := cfg.selector.watchStarting(&options)
+			if err != nil {
+				return nil, err
+			}
+			cfg.mutateOptions(&options)
+			w, err := ret.Watch(context.TODO(), options)
+			if err != nil {
+				cfg.onErr(err)
+				return nil, err
+			}
+			cfg.selector.watchStarted(w)
+
+			// The informer watches after storing the listed objects, so the relist is complete.
+			mu.Lock()
+			relisted := listed && lists > 1
+			listed = false
+			mu.Unlock()
+			if relisted {
+				cfg.onRelist()
+			}
+
+			return w, nil
 		},
 	}
 }
@@ -141,13 +720,14 @@ func New(cfg *Config) (Controller, error) {
 	// Create the queue that will have our received job changes.
 	queue := newRateLimitingBlockingQueue(
 		cfg.ProcessingJobRetries,
-		workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), cfg.Name),
+		workqueue.NewNamedRateLimitingQueue(cfg.RetryRateLimiter, cfg.Name),
 	)
 
 	// Measure the queue.
 	queue, err = newMetricsBlockingQueue(
 		cfg.Name,
 		cfg.MetricsRecorder,
+		cfg.Clock,
 		queue,
 		cfg.Logger,
 	)
@@ -155,57 +735,319 @@ func New(cfg *Config) (Controller, error) {
 		return nil, fmt.Errorf("could not measure the queue: %w", err)
 	}
 
+	// Track the status of the queue keys.
+	trackingQueue := newTrackingBlockingQueue(queue)
+	queue = trackingQueue
+
 	// store is the internal cache where objects will be store.
 	store := cache.Indexers{}
-	lw := listerWatcherFromRetriever(cfg.Retriever)
-	informer := cache.NewSharedIndexInformer(lw, nil, cfg.ResyncInterval, store)
+	// fatalWatchErrC will receive the fatal watch errors that will stop the controller.
+	fatalWatchErrC := make(chan error, 1)
+	onErr := func(err error) {
+		if cfg.IsFatalWatchError != nil && cfg.IsFatalWatchError(err) {
+			select {
+			case fatalWatchErrC <- err:
+			default:
+			}
+		}
+	}
+	selector := newListSelector(cfg.LabelSelector)
+	lw := listerWatcherFromRetriever(cfg.Retriever, listerWatcherConfig{
+		selector:      selector,
+		listPageSize:  cfg.ListPageSize,
+		listOrder:     cfg.InitialSyncOrder,
+		watchTimeout:  cfg.WatchTimeout,
+		mutateOptions: cfg.ListOptionsMutator,
+		onErr:         onErr,
+		onRelist:      cfg.OnRelist,
+	})
+	// The classified objects are resynced by the weighted resyncer instead of the informer.
+	informerResync := cfg.ResyncInterval
+	if cfg.ResyncClassifier != nil {
+		informerResync = 0
+	}
+	informer := cache.NewSharedIndexInformer(lw, nil, informerResync, store)
+
+	// Measure the cache.
+	if cmrec, ok := cfg.MetricsRecorder.(CacheMetricsRecorder); ok {
+		indexer := informer.GetIndexer()
+		err := cmrec.RegisterResourceCacheLengthFunc(cfg.Name, func(_ context.Context) int { return len(indexer.ListKeys()) })
+		if err != nil {
+			return nil, fmt.Errorf("could not measure the cache: %w", err)
+		}
+	}
+
+	// canceler will cancel the handling of the deleted objects.
+	var canceler *processingCanceler
+	if cfg.CancelOnDelete {
+		canceler = newProcessingCanceler()
+	}
+
+	// deleted will have the last known state of the deleted objects for the delete handler.
+	var deleted *deletedObjectsCache
+	if cfg.DeleteHandler != nil {
+		deleted = newDeletedObjectsCache(cfg.DeletedObjectsCacheSize)
+	}
+
+	// reconciled will have the versions of the handled objects to skip the unchanged objects.
+	var reconciled *reconciledVersions
+	if cfg.SkipUnchangedResyncs || cfg.ReconciledStore != nil {
+		store := cfg.ReconciledStore
+		if store == nil {
+			store = NewMemoryReconciledStore()
+		}
+		reconciled = newReconciledVersions(cfg.ObjectVersion, store, cfg.ReconciledStore != nil, cfg.Logger)
+	}
+	// disabled will have the objects skipped by the reconcile enabled annotation.
+	disabled := newDisabledReconciles()
+
+	flusher := newReconciledFlusher(cfg.ReconciledStore, cfg.ReconciledStoreFlushInterval, cfg.Clock, cfg.Logger)
+
+	events := newEventNotifier(cfg.OnEvent)
+
+	// owned returns true if the key is owned by this controller.
+	owned := func(key string) bool {
+		return cfg.ShardFilter == nil || cfg.ShardFilter(key)
+	}
+
+	// normalize returns the normalized key of the object key.
+	normalize := func(key string) string {
+		if cfg.KeyNormalizer == nil {
+			return key
+		}
+		return cfg.KeyNormalizer(key)
+	}
+
+	// enqueueNormalizedEvent will add the normalized object key of an informer event to the queue if the
+	// key is owned by this controller, the trigger is kept until the key is handled.
+	triggers := newKeyTriggers()
+	enqueueNormalizedEvent := func(key string, trigger Trigger) {
+		if !owned(key) {
+			return
+		}
+		if trigger != TriggerOther {
+			triggers.set(key, trigger)
+		}
+		events.notify(EventEnqueued, key, nil)
+		queue.Add(context.TODO(), key)
+	}
+
+	// enqueueEvent is like enqueueNormalizedEvent but normalizing the object key.
+	enqueueEvent := func(key string, trigger Trigger) {
+		enqueueNormalizedEvent(normalize(key), trigger)
+	}
+
+	// enqueue will add the object key to the queue if the key is owned by this controller.
+	enqueue := func(key string) { enqueueEvent(key, TriggerOther) }
+
+	// enqueueResync will add the resync object key to the queue at the resync pace (if paced).
+	pacer := newResyncPacer(cfg.ResyncEnqueueRate, cfg.ResyncEnqueueJitter, cfg.ResyncInterval)
+	enqueueResync := func(key string) {
+		key = normalize(key)
+		if !owned(key) {
+			return
+		}
+		triggers.set(key, TriggerResync)
+		events.notify(EventResynced, key, nil)
+		if pacer != nil {
+			queue.AddAfter(context.TODO(), key, pacer.delay())
+		} else {
+			queue.Add(context.TODO(), key)
+		}
+	}
+
+	// resyncObject will queue the resynced object unless its resyncs have been stopped, or it's unchanged
+	// and the unchanged resyncs are skipped.
+	stopped := newStoppedResyncs()
+	resyncObject := func(key string, obj runtime.Object) {
+		if !filterAccepts(cfg.ObjectFilter, obj) || stopped.has(normalize(key)) {
+			return
+		}
+		if cfg.SkipUnchangedResyncs && reconciled.matches(context.Background(), key, obj) {
+			return
+		}
+		enqueueResync(key)
+	}
+	resyncer := newWeightedResyncer(cfg.ResyncClassifier, cfg.ResyncInterval, cfg.Clock, informer.GetIndexer(), resyncObject)
+	if resyncer != nil {
+		informer.AddEventHandler(resyncer.handler())
+	}
 
 	// Set up our informer event handler.
 	// Objects are already in our local store. Add only keys/jobs on the queue so they can re processed
 	// afterwards.
 	informer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
+			if !filterAccepts(cfg.ObjectFilter, obj) {
+				return
+			}
+			if cfg.AddChangeDetector != nil && !cfg.AddChangeDetector(nil, obj.(runtime.Object)) {
+				return
+			}
+
 			key, err := cache.MetaNamespaceKeyFunc(obj)
 			if err != nil {
 				cfg.Logger.Warningf("could not add item from 'add' event to queue: %s", err)
 				return
 			}
-			queue.Add(context.TODO(), key)
+			enqueueEvent(key, TriggerAdd)
 		},
-		UpdateFunc: func(_ interface{}, new interface{}) {
+		UpdateFunc: func(old interface{}, new interface{}) {
+			if !filterAccepts(cfg.ObjectFilter, new) {
+				return
+			}
+			resync := isResync(old, new)
+			if cfg.UpdateChangeDetector != nil && !resync &&
+				!cfg.UpdateChangeDetector(old.(runtime.Object), new.(runtime.Object)) {
+				return
+			}
+
 			key, err := cache.MetaNamespaceKeyFunc(new)
 			if err != nil {
 				cfg.Logger.Warningf("could not add item from 'update' event to queue: %s", err)
 				return
 			}
-			queue.Add(context.TODO(), key)
+
+			if resync {
+				resyncObject(key, new.(runtime.Object))
+				return
+			}
+
+			if cfg.LogUpdateDiffs && owned(normalize(key)) {
+				diff, err := updateDiff(old.(runtime.Object), new.(runtime.Object), cfg.UpdateDiffMaxSize)
+				if err != nil {
+					cfg.Logger.Warningf("could not get %s update diff: %s", key, err)
+				} else if diff != "" {
+					cfg.Logger.WithKV(log.KV{"object-key": key, "diff": diff}).Infof("object updated")
+				}
+			}
+			stopped.set(normalize(key), false)
+			enqueueEvent(key, TriggerUpdate)
 		},
 		DeleteFunc: func(obj interface{}) {
+			if !filterAccepts(cfg.ObjectFilter, obj) {
+				return
+			}
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			if err != nil {
 				cfg.Logger.Warningf("could not add item from 'delete' event to queue: %s", err)
 				return
 			}
-			queue.Add(context.TODO(), key)
+
+			// The deleted object is handled with the normalized key.
+			key = normalize(key)
+			reconciled.delete(context.Background(), key)
+			stopped.set(key, false)
+			disabled.forget(key)
+			if owned(key) {
+				deleted.set(key, obj)
+				canceler.cancel(key)
+			}
+			enqueueNormalizedEvent(key, TriggerDelete)
 		},
-	}, cfg.ResyncInterval)
+	}, informerResync)
+
+	// Repeated processing errors will be collapsed so they don't flood the logs.
+	errLogger := cfg.Logger
+	if !cfg.DisableErrorLogRateLimit {
+		errLogger = log.NewRateLimited(cfg.Logger, cfg.ErrorLogRateLimitWindow, "request-id")
+	}
+
+	var handler ResultHandler
+	switch {
+	case cfg.ResultHandler != nil:
+		handler = cfg.ResultHandler
+	case cfg.HandlerFactory != nil:
+		handler = newWorkerHandlers(cfg.InitialWorkers, cfg.HandlerFactory)
+	default:
+		handler = resultHandlerFromHandler(cfg.Handler)
+	}
+	if cfg.DeepCopyObjects {
+		handler = newDeepCopyResultHandler(handler)
+	}
+	if cfg.ProfilingLabels {
+		handler = newProfilingLabelsResultHandler(cfg.Name, handler)
+	}
+	if reconciled != nil {
+		handler = newReconciledResultHandler(reconciled, handler)
+	}
+	if cfg.BeforeReconcile != nil || cfg.AfterReconcile != nil {
+		handler = newHooksResultHandler(cfg.BeforeReconcile, cfg.AfterReconcile, handler)
+	}
+	handler = newTimeoutResultHandler(cfg.ProcessingTimeout, cfg.MaxProcessingTimeout, handler)
+	if cfg.RequeueAfterOnSuccess > 0 {
+		handler = newRequeueOnSuccessResultHandler(cfg.RequeueAfterOnSuccess, handler)
+	}
+	if cfg.MinRequeueAfter > 0 || cfg.MaxRequeueAfter > 0 {
+		handler = newRequeueBoundsResultHandler(cfg.MinRequeueAfter, cfg.MaxRequeueAfter, cfg.Logger, handler)
+	}
+	if cfg.ConcurrencyLimiter != nil {
+		handler = newConcurrencyLimitResultHandler(cfg.ConcurrencyLimiter, handler)
+	}
+	if cfg.MaxReconcileRate > 0 {
+		handler = newRateLimitResultHandler(rate.NewLimiter(rate.Limit(cfg.MaxReconcileRate), cfg.MaxReconcileBurst), handler)
+	}
+	handler = newReconcileEnabledResultHandler(cfg.ReconcileEnabledAnnotation, disabled, cfg.Logger, handler)
+	if omrec, ok := cfg.MetricsRecorder.(OutcomeMetricsRecorder); ok {
+		handler = newOutcomeMetricsResultHandler(cfg.Name, omrec, handler)
+	}
+	if amrec, ok := cfg.MetricsRecorder.(AgeMetricsRecorder); ok {
+		handler = newAgeMetricsResultHandler(cfg.Name, amrec, handler)
+	}
 
 	// Create processing chain: processor(+middlewares) -> handler(+middlewares).
-	processor := newIndexerProcessor(informer.GetIndexer(), cfg.Handler)
-	if cfg.ProcessingJobRetries > 0 {
-		processor = newRetryProcessor(cfg.Name, queue, cfg.Logger, processor)
+	processor := newIndexerProcessor(informer.GetIndexer(), queue, enqueue, cfg.Scheme, handler, deleted, cfg.DeleteHandler, stopped)
+	if cfg.FaultInjector != nil {
+		processor = newFaultInjectorProcessor(cfg.FaultInjector, processor)
+	}
+	processor = newCancelableProcessor(canceler, processor)
+	if cfg.RecoverHandlerPanics {
+		processor = newPanicRecoverProcessor(cfg.MaxHandlerPanics, processor)
+	}
+	if cfg.ObjectLocker != nil {
+		processor = newObjectLockProcessor(cfg.ObjectLocker, queue, cfg.ObjectLockRetryInterval, cfg.Logger, processor)
+	}
+	if emrec, ok := cfg.MetricsRecorder.(ErrorMetricsRecorder); ok {
+		processor = newErrorMetricsProcessor(cfg.Name, emrec, cfg.ErrorClassifier, cfg.DistinctErrorMetrics, processor)
+	}
+	processor = newEventsProcessor(events, processor)
+	switch {
+	case cfg.RetryPolicy != nil:
+		processor = newRetryPolicyProcessor(queue, cfg.RetryPolicy, errLogger, cfg.LogRetriesAtLevel, events, processor)
+	case cfg.ProcessingJobRetries > 0:
+		processor = newRetryProcessor(cfg.Name, queue, errLogger, cfg.LogRetriesAtLevel, events, !cfg.DisableForgetOnNotFound, processor)
 	}
 	processor = newMetricsProcessor(cfg.Name, cfg.MetricsRecorder, processor)
+	pauser := newNamespacePauser(queue, cfg.Logger)
+	processor = newPauseProcessor(pauser, cfg.Logger, processor)
+
+	var idle *idleNotifier
+	if cfg.OnIdle != nil {
+		idle = newIdleNotifier(cfg.IdleDebounce, queue.Len, cfg.OnIdle)
+	}
 
 	// Create our generic controller object.
 	return &generic{
 		queue:     queue,
+		tracking:  trackingQueue,
 		informer:  informer,
+		fatalC:    fatalWatchErrC,
+		syncedC:   make(chan struct{}),
+		readyC:    make(chan struct{}),
+		selector:  selector,
+		resyncer:  resyncer,
+		flusher:   flusher,
 		metrics:   cfg.MetricsRecorder,
 		processor: processor,
+		pauser:    pauser,
+		idle:      idle,
+		events:    events,
+		enqueue:   enqueue,
+		triggers:  triggers,
 		leRunner:  cfg.LeaderElector,
 		cfg:       *cfg,
 		logger:    cfg.Logger,
+		errLogger: errLogger,
 	}, nil
 }
 
@@ -221,11 +1063,86 @@ func (g *generic) setRunning(running bool) {
 	g.running = running
 }
 
+// PauseNamespace satisfies Pauser interface.
+func (g *generic) PauseNamespace(namespace string) {
+	g.logger.WithKV(log.KV{"namespace": namespace}).Infof("pausing namespace")
+	g.pauser.Pause(namespace)
+}
+
+// ResumeNamespace satisfies Pauser interface.
+func (g *generic) ResumeNamespace(namespace string) {
+	g.logger.WithKV(log.KV{"namespace": namespace}).Infof("resuming namespace")
+	g.pauser.Resume(context.TODO(), namespace)
+}
+
+// KeyStatus satisfies KeyStatuser interface.
+func (g *generic) KeyStatus(key string) KeyStatus {
+	return g.tracking.Status(key)
+}
+
+// QueueSnapshot satisfies KeyStatuser interface.
+func (g *generic) QueueSnapshot() []QueueItem {
+	return g.tracking.Snapshot()
+}
+
+// AddForProcessing satisfies ObjectAdder interface.
+func (g *generic) AddForProcessing(ctx context.Context, obj runtime.Object) error {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Errorf("could not get object key: %w", err)
+	}
+
+	// The initial sync would remove the object from the cache.
+	if !cache.WaitForCacheSync(ctx.Done(), g.informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for caches to sync")
+	}
+
+	err = g.informer.GetIndexer().Add(obj)
+	if err != nil {
+		return fmt.Errorf("could not add object to the cache: %w", err)
+	}
+	g.enqueue(key)
+
+	return nil
+}
+
+// UpdateSelector satisfies SelectorUpdater interface.
+func (g *generic) UpdateSelector(selector labels.Selector) {
+	g.logger.WithKV(log.KV{"selector": fmt.Sprint(selector)}).Infof("updating label selector")
+	g.selector.update(selector)
+}
+
+// Synced satisfies SyncNotifier interface.
+func (g *generic) Synced() <-chan struct{} {
+	return g.syncedC
+}
+
+// Ready satisfies SyncNotifier interface.
+func (g *generic) Ready() <-chan struct{} {
+	return g.readyC
+}
+
+// WaitForKey satisfies KeyWaiter interface.
+func (g *generic) WaitForKey(ctx context.Context, key string) error {
+	return g.events.waitForKey(ctx, key)
+}
+
 // Run will run the controller.
 func (g *generic) Run(ctx context.Context) error {
 	// Check if leader election is required.
 	if g.leRunner != nil {
-		return g.leRunner.Run(func() error {
+		return g.leRunner.Run(func(leCtx context.Context) error {
+			// Stop running when the controller is stopped or the leadership is lost.
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() {
+				select {
+				case <-leCtx.Done():
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
 			return g.run(ctx)
 		})
 	}
@@ -244,24 +1161,195 @@ func (g *generic) run(ctx context.Context) error {
 	g.setRunning(true)
 	defer g.setRunning(false)
 
-	// Shutdown when Run is stopped so we can process the last items and the queue doesn't
-	// accept more jobs.
+	// Let the run be stopped with `StopAndDrain`, the stop is notified after all the goroutines
+	// have finished.
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	stoppedC := make(chan struct{})
+	g.runningMu.Lock()
+	g.stop, g.stoppedC = stop, stoppedC
+	g.runningMu.Unlock()
+	defer func() {
+		g.runningMu.Lock()
+		g.stop, g.stoppedC = nil, nil
+		g.runningMu.Unlock()
+		close(stoppedC)
+	}()
+
+	// Flush the reconciled store once all the handlings have finished.
+	defer g.flusher.flush(context.Background())
+
+	// Wait until all the goroutines started by the controller have finished, so we don't
+	// leak goroutines when the controller is stopped.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Stop the informer and the workers when we stop running (e.g: on errors), this is
+	// deferred after waiting for them so it's executed first.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Shutdown when Run is stopped so the queue doesn't accept more jobs and the workers
+	// blocked waiting for jobs finish.
 	defer g.queue.ShutDown(ctx)
 
+	// Load the reconciled store before the informer handlers use it.
+	g.flusher.load(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.flusher.run(ctx)
+	}()
+
 	// Run the informer so it starts listening to resource events.
-	go g.informer.Run(ctx.Done())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.informer.Run(ctx.Done())
+	}()
+
+	// Run the weighted resyncer so the classified objects are resynced.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.resyncer.run(ctx)
+	}()
+
+	// Run the idle notifier so it notifies while the controller is running.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.idle.run(ctx)
+	}()
+
+	// Stop the controller on fatal watch errors, stopErr will return the stop reason.
+	stopErrC := make(chan error, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case err := <-g.fatalC:
+			stopErrC <- fmt.Errorf("%w: %s", ErrFatalWatch, err)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	stopErr := func() error {
+		select {
+		case err := <-stopErrC:
+			return err
+		default:
+			return nil
+		}
+	}
 
 	// Wait until our store, jobs... stuff is synced (first list on resource, resources on store and jobs on queue).
-	if !cache.WaitForCacheSync(ctx.Done(), g.informer.HasSynced) {
-		return fmt.Errorf("timed out waiting for caches to sync")
+	waitForCacheSync := func() error {
+		syncCtx := ctx
+		if g.cfg.CacheSyncTimeout > 0 {
+			var cancelSync context.CancelFunc
+			syncCtx, cancelSync = context.WithTimeout(ctx, g.cfg.CacheSyncTimeout)
+			defer cancelSync()
+		}
+		if !cache.WaitForCacheSync(syncCtx.Done(), g.informer.HasSynced) {
+			if ctx.Err() != nil {
+				return stopErr()
+			}
+			return fmt.Errorf("%w after %s", ErrCacheSyncTimeout, g.cfg.CacheSyncTimeout)
+		}
+		g.syncedOnce.Do(func() { close(g.syncedC) })
+		g.logSyncSummary()
+
+		return nil
+	}
+
+	// The seeded objects are handled without waiting for the cache sync.
+	if !g.isSeeded() {
+		err := waitForCacheSync()
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	// Wait until the dependencies are ready before handling objects.
+	if g.cfg.StartupDelay > 0 {
+		g.logger.Infof("waiting %s before starting the workers", g.cfg.StartupDelay)
+		select {
+		case <-ctx.Done():
+			return stopErr()
+		case <-time.After(g.cfg.StartupDelay):
+		}
+	}
+
+	if len(g.cfg.DependsOn) > 0 {
+		g.logger.Infof("waiting for the dependencies")
+		for _, dep := range g.cfg.DependsOn {
+			select {
+			case <-ctx.Done():
+				return stopErr()
+			case <-dep:
+			}
+		}
+	}
+
+	if g.cfg.WaitUntilReady != nil {
+		g.logger.Infof("waiting until ready")
+		err := g.cfg.WaitUntilReady(ctx)
+		if err != nil {
+			if err := stopErr(); err != nil {
+				return err
+			}
+			return fmt.Errorf("controller not ready: %w", err)
+		}
 	}
 
-	// Start our resource processing worker, if finishes then restart the worker. The workers should
-	// not end.
-	for i := 0; i < g.cfg.ConcurrentWorkers; i++ {
+	if g.cfg.AutoScale {
+		// The auto scaled workers replace the fixed workers.
+		wg.Add(1)
 		go func() {
-			wait.Until(g.runWorker, time.Second, ctx.Done())
+			defer wg.Done()
+			g.runAutoScaledWorkers(ctx)
 		}()
+	} else {
+		// Start our resource processing worker, if finishes then restart the worker. The workers should
+		// not end.
+		for i := 0; i < g.cfg.ConcurrentWorkers; i++ {
+			worker := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait.Until(func() { g.runWorker(ctx, worker) }, time.Second, ctx.Done())
+			}()
+		}
+
+		// Start the extra workers that will only process the initial sync objects.
+		atomic.StoreInt32(&g.initialSynced, 0)
+		for i := g.cfg.ConcurrentWorkers; i < g.cfg.InitialWorkers; i++ {
+			worker := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g.runInitialWorker(ctx, worker)
+			}()
+		}
+	}
+
+	if g.isSeeded() {
+		err := waitForCacheSync()
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	// The controller is ready when the workers start, unless it depends on the handling of a key.
+	if g.cfg.ReadyAfterKey != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.runReadyAfterKey(ctx)
+		}()
+	} else {
+		g.setReady()
 	}
 
 	// Block while running our workers in a continuous way (and re run if they fail). But
@@ -269,25 +1357,101 @@ func (g *generic) run(ctx context.Context) error {
 	<-ctx.Done()
 	g.logger.Infof("stopping controller")
 
-	return nil
+	return stopErr()
+}
+
+// logSyncSummary logs the configuration of the controller and the synced objects in a single line,
+// the resource is resolved from the synced objects.
+func (g *generic) logSyncSummary() {
+	objs := g.informer.GetIndexer().List()
+	resource := ""
+	namespaces := map[string]struct{}{}
+	for _, obj := range objs {
+		rObj, ok := obj.(runtime.Object)
+		if !ok {
+			continue
+		}
+		if resource == "" {
+			resource = objectGVK(g.cfg.Scheme, rObj).GroupKind().String()
+		}
+		if objMeta, err := meta.Accessor(rObj); err == nil && objMeta.GetNamespace() != "" {
+			namespaces[objMeta.GetNamespace()] = struct{}{}
+		}
+	}
+
+	g.logger.WithKV(log.KV{
+		"resource":        resource,
+		"namespaces":      len(namespaces),
+		"workers":         g.cfg.ConcurrentWorkers,
+		"resync-interval": g.cfg.ResyncInterval.String(),
+		"synced-objects":  len(objs),
+	}).Infof("controller cache synced")
 }
 
-// runWorker will start a processing loop on event queue.
-func (g *generic) runWorker() {
+// runWorker will start a processing loop on event queue until the queue is closed or the
+// context is done.
+func (g *generic) runWorker(ctx context.Context, worker int) {
 	for {
+		// Don't start processing new jobs if we are stopping.
+		if ctx.Err() != nil {
+			return
+		}
+
 		// Process next queue job, if needs to stop processing it will return true.
-		if g.processNextJob() {
+		if g.processNextJob(worker) {
 			break
 		}
 	}
 }
 
+// runInitialWorker is like runWorker but it will stop processing once the initial sync
+// objects have been processed, this is the first time the worker finds the queue empty.
+func (g *generic) runInitialWorker(ctx context.Context, worker int) {
+	for {
+		if ctx.Err() != nil || atomic.LoadInt32(&g.initialSynced) == 1 {
+			return
+		}
+
+		if g.queue.Len(ctx) == 0 {
+			if atomic.CompareAndSwapInt32(&g.initialSynced, 0, 1) {
+				g.logger.Infof("initial sync objects processed, scaling down to %d workers", g.cfg.ConcurrentWorkers)
+			}
+			return
+		}
+
+		if g.processNextJob(worker) {
+			return
+		}
+	}
+}
+
+// process processes the object key with the context of the factory (if any), counting its trigger and
+// measuring it as in flight while processing (even on panics).
+func (g *generic) process(ctx context.Context, key string) error {
+	if g.cfg.ContextFactory != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = g.cfg.ContextFactory(ctx, key)
+		defer cancel()
+	}
+
+	if trec, ok := g.metrics.(TriggerMetricsRecorder); ok {
+		trec.IncResourceProcessingTrigger(ctx, g.cfg.Name, string(TriggerFromContext(ctx)))
+	}
+
+	if ifrec, ok := g.metrics.(InFlightMetricsRecorder); ok {
+		ifrec.IncResourceProcessingInFlight(ctx, g.cfg.Name)
+		defer ifrec.DecResourceProcessingInFlight(ctx, g.cfg.Name)
+	}
+
+	return g.processor.Process(ctx, key)
+}
+
 // processNextJob job will process the next job of the queue job and returns if
 // it needs to stop processing.
 //
 // If the queue has been closed then it will end the processing.
-func (g *generic) processNextJob() bool {
-	ctx := context.Background()
+func (g *generic) processNextJob(worker int) bool {
+	ctx := contextWithWorker(context.Background(), worker)
 
 	// Get next job.
 	nextJob, exit := g.queue.Get(ctx)
@@ -295,20 +1459,28 @@ func (g *generic) processNextJob() bool {
 		return true
 	}
 
+	g.idle.processingStarted()
+	defer g.idle.processingFinished(ctx)
 	defer g.queue.Done(ctx, nextJob)
 	key := nextJob.(string)
 
 	// Process the job.
-	err := g.processor.Process(ctx, key)
+	ctx = contextWithRequestID(ctx)
+	ctx = contextWithTrigger(ctx, g.triggers.take(key))
+	err := g.process(ctx, key)
+
+	if err != nil {
+		g.events.notify(EventForgotten, key, err)
+	}
 
-	logger := g.logger.WithKV(log.KV{"object-key": key})
+	kv := log.KV{"object-key": key, "request-id": RequestIDFromContext(ctx)}
 	switch {
 	case err == nil:
-		logger.Debugf("object processed")
+		g.logger.WithKV(kv).Debugf("object processed")
 	case errors.Is(err, errRequeued):
-		logger.Warningf("error on object processing, retrying: %v", err)
+		g.errLogger.WithKV(kv).Warningf("error on object processing, retrying: %v", err)
 	default:
-		logger.Errorf("error on object processing: %v", err)
+		g.errLogger.WithKV(kv).Errorf("error on object processing: %v", err)
 	}
 
 	return false
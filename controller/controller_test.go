@@ -2,8 +2,13 @@ package controller_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,13 +16,18 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	kubetesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	testclock "k8s.io/utils/clock/testing"
 
 	"github.com/spotahome/kooper/v2/controller"
 	"github.com/spotahome/kooper/v2/controller/controllermock"
@@ -37,6 +47,71 @@ func newNamespaceRetriever(client kubernetes.Interface) controller.Retriever {
 	})
 }
 
+// newPodRetriever returns a Pod retriever for all namespaces.
+func newPodRetriever(client kubernetes.Interface) controller.Retriever {
+	return controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods("").Watch(context.TODO(), options)
+		},
+	})
+}
+
+func newPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+// testLogger is a logger that stores the logged lines.
+type testLogger struct {
+	mu    *sync.Mutex
+	lines *[]string
+	kv    log.KV
+}
+
+func newTestLogger() testLogger {
+	return testLogger{mu: &sync.Mutex{}, lines: &[]string{}, kv: log.KV{}}
+}
+
+func (t testLogger) logf(level, format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	line := fmt.Sprintf("[%s] %s", level, fmt.Sprintf(format, args...))
+	if len(t.kv) > 0 {
+		line = fmt.Sprintf("%s %v", line, map[string]interface{}(t.kv))
+	}
+	*t.lines = append(*t.lines, line)
+}
+
+func (t testLogger) Infof(format string, args ...interface{})    { t.logf("INFO", format, args...) }
+func (t testLogger) Warningf(format string, args ...interface{}) { t.logf("WARN", format, args...) }
+func (t testLogger) Errorf(format string, args ...interface{})   { t.logf("ERROR", format, args...) }
+func (t testLogger) Debugf(format string, args ...interface{})   { t.logf("DEBUG", format, args...) }
+func (t testLogger) WithKV(kv log.KV) log.Logger {
+	kvs := log.KV{}
+	for k, v := range t.kv {
+		kvs[k] = v
+	}
+	for k, v := range kv {
+		kvs[k] = v
+	}
+	return testLogger{mu: t.mu, lines: t.lines, kv: kvs}
+}
+
+// Lines returns the logged lines that contain the substring.
+func (t testLogger) Lines(substr string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := []string{}
+	for _, l := range *t.lines {
+		if strings.Contains(l, substr) {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
 func onKubeClientListNamespaceReturn(client *fake.Clientset, nss *corev1.NamespaceList) {
 	client.AddReactor("list", "namespaces", func(action kubetesting.Action) (bool, runtime.Object, error) {
 		return true, nss, nil
@@ -309,7 +384,10 @@ func TestGenericControllerWithLeaderElection(t *testing.T) {
 			// Run multiple controller in background.
 			go func() { resultC <- c1.Run(ctx) }()
 			// Let the first controller became the leader.
-			time.Sleep(200 * time.Microsecond)
+			require.Eventually(func() bool {
+				_, err := mc.CoordinationV1().Leases("default").Get(ctx, "test", metav1.GetOptions{})
+				return err == nil
+			}, 1*time.Second, 1*time.Millisecond)
 			go func() { resultC <- c2.Run(ctx) }()
 			go func() { resultC <- c3.Run(ctx) }()
 
@@ -328,3 +406,2633 @@ func TestGenericControllerWithLeaderElection(t *testing.T) {
 		})
 	}
 }
+
+func TestGenericControllerShardFilter(t *testing.T) {
+	tests := map[string]struct {
+		shardFilter func(key string) bool
+		expHandled  []string
+	}{
+		"Without shard filter all the objects should be handled.": {
+			expHandled: []string{"testing-0", "testing-1", "testing-2", "testing-3"},
+		},
+
+		"With a shard filter only the owned objects should be handled.": {
+			shardFilter: func(key string) bool {
+				return key == "testing-0" || key == "testing-2"
+			},
+			expHandled: []string{"testing-0", "testing-2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", 4)
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			var mu sync.Mutex
+			handled := []string{}
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled = append(handled, obj.(*corev1.Namespace).Name)
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:        "test",
+				Handler:     h,
+				Retriever:   newNamespaceRetriever(mc),
+				ShardFilter: test.shardFilter,
+				Logger:      log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handled) >= len(test.expHandled)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// Give some time to the not owned objects in case they are handled.
+			time.Sleep(50 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.ElementsMatch(test.expHandled, handled)
+		})
+	}
+}
+
+func TestGenericControllerFailingObjectsDontStarveHealthyObjects(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	const healthyObjects = 50
+	nsList, _ := createNamespaceList("testing", healthyObjects+1)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The first object will always fail, the rest are healthy.
+	var mu sync.Mutex
+	failures := 0
+	healthyHandled := 0
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if obj.(*corev1.Namespace).Name == "testing-0" {
+			failures++
+			return fmt.Errorf("wanted error")
+		}
+		healthyHandled++
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		ConcurrentWorkers:    1,
+		ProcessingJobRetries: 1000,
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// All the healthy objects should be processed fast regardless of the failing one.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return healthyHandled == healthyObjects
+	}, 500*time.Millisecond, 5*time.Millisecond)
+
+	// The failing object retries should have been backed off, not hogging the worker.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Less(failures, healthyObjects)
+}
+
+func TestGenericControllerErrorLogRateLimit(t *testing.T) {
+	const (
+		objects = 2
+		retries = 9
+	)
+
+	tests := map[string]struct {
+		disableRateLimit bool
+		expRetryLines    int
+	}{
+		"Repeated processing errors should be logged once per object.": {
+			expRetryLines: objects,
+		},
+
+		"Repeated processing errors without rate limit should be logged every time.": {
+			disableRateLimit: true,
+			expRetryLines:    objects * retries,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", objects)
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			// All the objects fail at the same time with the same error.
+			var handled int32
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				atomic.AddInt32(&handled, 1)
+				return fmt.Errorf("wanted error")
+			})
+
+			logger := newTestLogger()
+			c, err := controller.New(&controller.Config{
+				Name:                     "test",
+				Handler:                  h,
+				Retriever:                newNamespaceRetriever(mc),
+				ProcessingJobRetries:     retries,
+				RetryRateLimiter:         workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Millisecond, retries),
+				DisableErrorLogRateLimit: test.disableRateLimit,
+				Logger:                   logger,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				return atomic.LoadInt32(&handled) == int32(objects*(retries+1))
+			}, 1*time.Second, 5*time.Millisecond)
+			// The errors are logged after handling.
+			time.Sleep(20 * time.Millisecond)
+
+			// Every object error should be logged.
+			assert.Len(logger.Lines("item requeued due to processing error"), test.expRetryLines)
+			assert.Len(logger.Lines("error on object processing"), objects)
+			for _, ns := range nsList.Items {
+				assert.NotEmpty(logger.Lines("object-key:" + ns.Name))
+			}
+		})
+	}
+}
+
+func TestGenericControllerResultHandlerRequeueAfter(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 1)
+	mc := fake.NewSimpleClientset(nss[0])
+
+	// Requeue the object until it has been handled 3 times.
+	var handled int32
+	h := controller.ResultHandlerFunc(func(_ context.Context, _ runtime.Object) (controller.Result, error) {
+		if atomic.AddInt32(&handled, 1) < 3 {
+			return controller.Result{RequeueAfter: 10 * time.Millisecond}, nil
+		}
+		return controller.Result{}, nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:          "test",
+		ResultHandler: h,
+		Retriever:     newNamespaceRetriever(mc),
+		Logger:        log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool { return atomic.LoadInt32(&handled) == 3 }, 1*time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&handled))
+}
+
+func TestGenericControllerHandlerValidation(t *testing.T) {
+	mc := fake.NewSimpleClientset()
+	h := controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil })
+	rh := controller.ResultHandlerFunc(func(context.Context, runtime.Object) (controller.Result, error) {
+		return controller.Result{}, nil
+	})
+
+	hf := func() controller.Handler { return h }
+
+	tests := map[string]struct {
+		handler        controller.Handler
+		resultHandler  controller.ResultHandler
+		handlerFactory func() controller.Handler
+		expErr         bool
+	}{
+		"Without handlers should fail.": {
+			expErr: true,
+		},
+
+		"With a handler should not fail.": {
+			handler: h,
+		},
+
+		"With a result handler should not fail.": {
+			resultHandler: rh,
+		},
+
+		"With a handler factory should not fail.": {
+			handlerFactory: hf,
+		},
+
+		"With both handlers should fail.": {
+			handler:       h,
+			resultHandler: rh,
+			expErr:        true,
+		},
+
+		"With a handler and a handler factory should fail.": {
+			handler:        h,
+			handlerFactory: hf,
+			expErr:         true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := controller.New(&controller.Config{
+				Name:           "test",
+				Handler:        test.handler,
+				ResultHandler:  test.resultHandler,
+				HandlerFactory: test.handlerFactory,
+				Retriever:      newNamespaceRetriever(mc),
+				Logger:         log.Dummy,
+			})
+			if test.expErr {
+				assert.ErrorIs(t, err, controller.ErrControllerNotValid)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGenericControllerResyncEnqueueRate(t *testing.T) {
+	const objects = 10
+
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", objects)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// Store the times of the resync handlings (second handling of each object).
+	var mu sync.Mutex
+	handled := map[string]int{}
+	resyncTimes := []time.Time{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		name := obj.(*corev1.Namespace).Name
+		handled[name]++
+		if handled[name] == 2 {
+			resyncTimes = append(resyncTimes, time.Now())
+		}
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ResyncInterval:    1 * time.Second,
+		ResyncEnqueueRate: 20,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(resyncTimes) == objects
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// At 20 objects per second, the resync of all the objects should be spread for at least ~450ms.
+	mu.Lock()
+	defer mu.Unlock()
+	first, last := resyncTimes[0], resyncTimes[0]
+	for _, ts := range resyncTimes {
+		if ts.Before(first) {
+			first = ts
+		}
+		if ts.After(last) {
+			last = ts
+		}
+	}
+	require.GreaterOrEqual(last.Sub(first), 350*time.Millisecond)
+}
+
+func TestGenericControllerAddForProcessing(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	// The informer will not receive any object.
+	mc := fake.NewSimpleClientset()
+
+	handledC := make(chan *corev1.Namespace)
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		handledC <- obj.(*corev1.Namespace)
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newNamespaceRetriever(mc),
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Feed the objects directly.
+	for _, name := range []string{"ns-0", "ns-1"} {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		require.NoError(c.(controller.ObjectAdder).AddForProcessing(ctx, ns))
+
+		select {
+		case got := <-handledC:
+			assert.Equal(ns, got)
+		case <-time.After(1 * time.Second):
+			require.FailNow("timeout waiting for the object handling", name)
+		}
+	}
+}
+
+func TestGenericControllerDisableResync(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 2)
+	mc := fake.NewSimpleClientset(nss[0], nss[1])
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Namespace).Name]++
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:           "test",
+		Handler:        h,
+		Retriever:      newNamespaceRetriever(mc),
+		ResyncInterval: 1 * time.Second, // Minimum resync allowed by the informers.
+		DisableResync:  true,
+		Logger:         log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Wait more than the resync interval.
+	time.Sleep(1500 * time.Millisecond)
+
+	// Watch events should be handled.
+	_, err = mc.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "testing-new"}}, metav1.CreateOptions{})
+	require.NoError(err)
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["testing-new"] == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"testing-0": 1, "testing-1": 1, "testing-new": 1}, handled)
+}
+
+// testNotConcurrentHandler is a handler that is not safe for concurrent use, and detects
+// if it's being used concurrently.
+type testNotConcurrentHandler struct {
+	inUse      int32
+	concurrent int32
+	handled    int32
+}
+
+func (t *testNotConcurrentHandler) Handle(_ context.Context, _ runtime.Object) error {
+	if !atomic.CompareAndSwapInt32(&t.inUse, 0, 1) {
+		atomic.StoreInt32(&t.concurrent, 1)
+		return nil
+	}
+	defer atomic.StoreInt32(&t.inUse, 0)
+
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt32(&t.handled, 1)
+	return nil
+}
+
+func TestGenericControllerHandlerFactory(t *testing.T) {
+	const (
+		workers = 3
+		objects = 30
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", objects)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	var mu sync.Mutex
+	handlers := []*testNotConcurrentHandler{}
+	hf := func() controller.Handler {
+		mu.Lock()
+		defer mu.Unlock()
+		h := &testNotConcurrentHandler{}
+		handlers = append(handlers, h)
+		return h
+	}
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		HandlerFactory:    hf,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: workers,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Wait until all the objects have been handled.
+	handled := func() int32 {
+		mu.Lock()
+		defer mu.Unlock()
+		total := int32(0)
+		for _, h := range handlers {
+			total += atomic.LoadInt32(&h.handled)
+		}
+		return total
+	}
+	require.Eventually(func() bool { return handled() == objects }, 2*time.Second, 5*time.Millisecond)
+
+	// Every worker should have used its own handler.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(handlers, workers)
+	for _, h := range handlers {
+		assert.Zero(atomic.LoadInt32(&h.concurrent), "handler used concurrently")
+		assert.NotZero(atomic.LoadInt32(&h.handled), "handler not used")
+	}
+}
+
+func TestGenericControllerSameKeyIsNotProcessedConcurrently(t *testing.T) {
+	const (
+		workers = 4
+		objects = 4
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", objects)
+	mc := fake.NewSimpleClientset()
+
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	maxInFlightKey, maxInFlight, handled := 0, 0, 0
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		name := obj.(*corev1.Namespace).Name
+
+		mu.Lock()
+		inFlight[name]++
+		total := 0
+		for _, v := range inFlight {
+			total += v
+		}
+		if inFlight[name] > maxInFlightKey {
+			maxInFlightKey = inFlight[name]
+		}
+		if total > maxInFlight {
+			maxInFlight = total
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight[name]--
+		handled++
+		mu.Unlock()
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: workers,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Add the same objects multiple times while they are being handled.
+	for i := 0; i < 10; i++ {
+		for _, ns := range nss {
+			require.NoError(c.(controller.ObjectAdder).AddForProcessing(ctx, ns))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	require.Eventually(func() bool {
+		return c.(controller.KeyStatuser).KeyStatus("testing-0") == controller.KeyStatusNotPresent &&
+			c.(controller.KeyStatuser).KeyStatus("testing-3") == controller.KeyStatusNotPresent
+	}, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(1, maxInFlightKey, "the same key should not be handled concurrently")
+	assert.Greater(maxInFlight, 1, "different keys should be handled concurrently")
+	assert.Greater(handled, objects, "the objects should be handled multiple times")
+}
+
+func TestGenericControllerInitialWorkers(t *testing.T) {
+	const (
+		initialWorkers    = 6
+		concurrentWorkers = 2
+		objects           = 30
+		handleLatency     = 20 * time.Millisecond
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("initial", objects)
+	objs := []runtime.Object{}
+	for _, ns := range nss {
+		objs = append(objs, ns)
+	}
+	mc := fake.NewSimpleClientset(objs...)
+
+	// Track the number of objects being handled at the same time.
+	var mu sync.Mutex
+	handled, inFlight, maxInFlight, overSteady := 0, 0, 0, 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		if inFlight > concurrentWorkers {
+			overSteady++
+		}
+		mu.Unlock()
+
+		time.Sleep(handleLatency)
+
+		mu.Lock()
+		inFlight--
+		handled++
+		mu.Unlock()
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: concurrentWorkers,
+		InitialWorkers:    initialWorkers,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The initial sync objects should be handled by all the initial workers.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == objects
+	}, 5*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	assert.Greater(maxInFlight, concurrentWorkers)
+	assert.LessOrEqual(maxInFlight, initialWorkers)
+	handled, overSteady = 0, 0
+	mu.Unlock()
+
+	// Once drained, the new objects should be handled by the steady workers. The initial workers
+	// that were already waiting for an object when the queue was drained can handle one more.
+	_, newNss := createNamespaceList("steady", objects)
+	for _, ns := range newNss {
+		_, err := mc.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+		require.NoError(err)
+	}
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == objects
+	}, 5*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.LessOrEqual(overSteady, initialWorkers-concurrentWorkers)
+}
+
+func TestGenericControllerInitialWorkersKeySerialization(t *testing.T) {
+	const (
+		initialWorkers    = 6
+		concurrentWorkers = 1
+		objects           = 30
+		handleLatency     = 20 * time.Millisecond
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("initial", objects)
+	objs := []runtime.Object{}
+	for _, ns := range nss {
+		objs = append(objs, ns)
+	}
+	mc := fake.NewSimpleClientset(objs...)
+
+	// Track the number of objects and the same object being handled at the same time.
+	var mu sync.Mutex
+	handled, inFlight, maxInFlight, maxKeyInFlight := map[string]int{}, map[string]int{}, 0, 0
+	total := 0
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		name := obj.(*corev1.Namespace).Name
+		mu.Lock()
+		inFlight[name]++
+		if inFlight[name] > maxKeyInFlight {
+			maxKeyInFlight = inFlight[name]
+		}
+		total++
+		if total > maxInFlight {
+			maxInFlight = total
+		}
+		mu.Unlock()
+
+		time.Sleep(handleLatency)
+
+		mu.Lock()
+		inFlight[name]--
+		total--
+		handled[name]++
+		mu.Unlock()
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: concurrentWorkers,
+		InitialWorkers:    initialWorkers,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Queue the same object repeatedly while the initial sync objects are handled.
+	for i := 0; i < 20; i++ {
+		err := c.(controller.ObjectAdder).AddForProcessing(ctx, nss[0].DeepCopy())
+		require.NoError(err)
+		time.Sleep(handleLatency / 4)
+	}
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == objects && total == 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// The initial sync should be handled in parallel, but never the same object concurrently.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Greater(maxInFlight, concurrentWorkers)
+	assert.Greater(handled[nss[0].Name], 1)
+	assert.Equal(1, maxKeyInFlight)
+}
+
+func TestGenericControllerWaitUntilReady(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 2)
+	mc := fake.NewSimpleClientset(nss[0], nss[1])
+
+	var mu sync.Mutex
+	handled := 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+		return nil
+	})
+
+	waitingC := make(chan struct{})
+	readyC := make(chan struct{})
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newNamespaceRetriever(mc),
+		WaitUntilReady: func(ctx context.Context) error {
+			close(waitingC)
+			<-readyC
+			return nil
+		},
+		Logger: log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// While not ready, the objects should be queued but not handled.
+	<-waitingC
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(0, handled)
+	mu.Unlock()
+	assert.Equal(controller.KeyStatusQueued, c.(controller.KeyStatuser).KeyStatus("testing-0"))
+
+	// Once ready, the objects should be handled.
+	close(readyC)
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == 2
+	}, 1*time.Second, 5*time.Millisecond)
+}
+
+func TestGenericControllerWaitUntilReadyError(t *testing.T) {
+	require := require.New(t)
+
+	_, nss := createNamespaceList("testing", 1)
+	mc := fake.NewSimpleClientset(nss[0])
+
+	c, err := controller.New(&controller.Config{
+		Name: "test",
+		Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+			require.FailNow("the object should not be handled")
+			return nil
+		}),
+		Retriever:      newNamespaceRetriever(mc),
+		WaitUntilReady: func(_ context.Context) error { return fmt.Errorf("wanted error") },
+		Logger:         log.Dummy,
+	})
+	require.NoError(err)
+
+	err = c.Run(context.Background())
+	require.Error(err)
+}
+
+func TestGenericControllerStartupDelay(t *testing.T) {
+	const startupDelay = 200 * time.Millisecond
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 1)
+	mc := fake.NewSimpleClientset(nss[0])
+
+	handledC := make(chan time.Time, 1)
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		handledC <- time.Now()
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:         "test",
+		Handler:      h,
+		Retriever:    newNamespaceRetriever(mc),
+		StartupDelay: startupDelay,
+		Logger:       log.Dummy,
+	})
+	require.NoError(err)
+	start := time.Now()
+	go func() { _ = c.Run(ctx) }()
+
+	select {
+	case handledAt := <-handledC:
+		assert.GreaterOrEqual(handledAt.Sub(start), startupDelay)
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for the object handling")
+	}
+}
+
+func TestGenericControllerResyncEnqueueRateSlowerThanInterval(t *testing.T) {
+	const objects = 30
+
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, nss := createNamespaceList("testing", objects)
+	objs := []runtime.Object{}
+	for _, ns := range nss {
+		objs = append(objs, ns)
+	}
+	mc := fake.NewSimpleClientset(objs...)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Namespace).Name]++
+		return nil
+	})
+
+	// At 10 objects per second, a resync of all the objects takes longer than the resync interval.
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ResyncInterval:    1 * time.Second,
+		ResyncEnqueueRate: 10,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The resync delays should not accumulate between resyncs, so all the objects should be
+	// resynced (at least) on each resync interval.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ns := range nsList.Items {
+			if handled[ns.Name] < 3 {
+				return false
+			}
+		}
+		return true
+	}, 4*time.Second, 10*time.Millisecond)
+}
+
+func TestGenericControllerListPageSize(t *testing.T) {
+	const (
+		objects  = 10
+		pageSize = 3
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", objects)
+
+	// Paginated list that uses the index of the next object as the continue token.
+	var mu sync.Mutex
+	limits := []int64{}
+	ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			mu.Lock()
+			limits = append(limits, options.Limit)
+			mu.Unlock()
+
+			start := 0
+			if options.Continue != "" {
+				start, _ = strconv.Atoi(options.Continue)
+			}
+			end := start + int(options.Limit)
+			if options.Limit == 0 || end > len(nsList.Items) {
+				end = len(nsList.Items)
+			}
+
+			page := nsList.DeepCopy()
+			page.Items = page.Items[start:end]
+			if end < len(nsList.Items) {
+				page.Continue = strconv.Itoa(end)
+			}
+			return page, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	})
+
+	handled := map[string]bool{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Namespace).Name] = true
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:         "test",
+		Handler:      h,
+		Retriever:    ret,
+		ListPageSize: pageSize,
+		Logger:       log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == objects
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The initial list should be requested in pages of the configured size.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]int64{pageSize, pageSize, pageSize, pageSize}, limits)
+}
+
+func TestGenericControllerStopReason(t *testing.T) {
+	nsList, _ := createNamespaceList("testing", 1)
+	forbiddenErr := apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", fmt.Errorf("wanted error"))
+
+	tests := map[string]struct {
+		listErr           error
+		cacheSyncTimeout  time.Duration
+		isFatalWatchError func(err error) bool
+		stopAfter         time.Duration
+		expErr            error
+	}{
+		"Stopping the controller with the context should not return an error.": {
+			stopAfter: 100 * time.Millisecond,
+		},
+
+		"Stopping the controller with the context while syncing the cache should not return an error.": {
+			listErr:   forbiddenErr,
+			stopAfter: 100 * time.Millisecond,
+		},
+
+		"Not syncing the cache within the cache sync timeout should return a cache sync timeout error.": {
+			listErr:          forbiddenErr,
+			cacheSyncTimeout: 100 * time.Millisecond,
+			stopAfter:        1 * time.Second,
+			expErr:           controller.ErrCacheSyncTimeout,
+		},
+
+		"Not fatal watch errors should not stop the controller.": {
+			listErr:           forbiddenErr,
+			isFatalWatchError: apierrors.IsNotFound,
+			stopAfter:         100 * time.Millisecond,
+		},
+
+		"Fatal watch errors should return a fatal watch error.": {
+			listErr:           forbiddenErr,
+			isFatalWatchError: apierrors.IsForbidden,
+			stopAfter:         1 * time.Second,
+			expErr:            controller.ErrFatalWatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					if test.listErr != nil {
+						return nil, test.listErr
+					}
+					return nsList, nil
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return watch.NewFake(), nil
+				},
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name: "test",
+				Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+					return nil
+				}),
+				Retriever:         ret,
+				CacheSyncTimeout:  test.cacheSyncTimeout,
+				IsFatalWatchError: test.isFatalWatchError,
+				Logger:            log.Dummy,
+			})
+			require.NoError(err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.stopAfter)
+			defer cancel()
+			start := time.Now()
+			err = c.Run(ctx)
+
+			if test.expErr != nil {
+				assert.ErrorIs(err, test.expErr)
+				assert.Less(time.Since(start), test.stopAfter, "the controller should stop before the context")
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestGenericControllerDeepCopyObjects(t *testing.T) {
+	tests := map[string]struct {
+		deepCopy    bool
+		expMutation bool
+	}{
+		"Without deep copy the handler mutations should affect the cache.": {
+			deepCopy:    false,
+			expMutation: true,
+		},
+
+		"With deep copy the handler mutations should not affect the cache.": {
+			deepCopy:    true,
+			expMutation: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			// The handler mutates the object, the second handling will get the object from the cache.
+			labelsC := make(chan map[string]string, 2)
+			h := controller.ResultHandlerFunc(func(_ context.Context, obj runtime.Object) (controller.Result, error) {
+				ns := obj.(*corev1.Namespace)
+				select {
+				case labelsC <- ns.Labels:
+				default:
+				}
+				ns.Labels = map[string]string{"mutated": "true"}
+				return controller.Result{RequeueAfter: 10 * time.Millisecond}, nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:            "test",
+				ResultHandler:   h,
+				Retriever:       newNamespaceRetriever(mc),
+				DeepCopyObjects: test.deepCopy,
+				Logger:          log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			var gotLabels map[string]string
+			for i := 0; i < 2; i++ {
+				select {
+				case gotLabels = <-labelsC:
+				case <-time.After(1 * time.Second):
+					require.FailNow("timeout waiting for the object handling")
+				}
+			}
+
+			if test.expMutation {
+				assert.Equal(map[string]string{"mutated": "true"}, gotLabels)
+			} else {
+				assert.Empty(gotLabels)
+			}
+		})
+	}
+}
+
+func TestGenericControllerWatchTimeout(t *testing.T) {
+	tests := map[string]struct {
+		watchTimeout time.Duration
+		expTimeout   func(t *testing.T, timeoutSeconds *int64)
+	}{
+		"Without a watch timeout, the Kubernetes client default should be used.": {
+			expTimeout: func(t *testing.T, timeoutSeconds *int64) {
+				require.NotNil(t, timeoutSeconds)
+				assert.GreaterOrEqual(t, *timeoutSeconds, int64(5*60))
+			},
+		},
+
+		"With a watch timeout, the watches should use it.": {
+			watchTimeout: 42 * time.Second,
+			expTimeout: func(t *testing.T, timeoutSeconds *int64) {
+				require.NotNil(t, timeoutSeconds)
+				assert.Equal(t, int64(42), *timeoutSeconds)
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			watchOptsC := make(chan metav1.ListOptions, 1)
+			ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return mc.CoreV1().Namespaces().List(context.TODO(), options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					select {
+					case watchOptsC <- options:
+					default:
+					}
+					return mc.CoreV1().Namespaces().Watch(context.TODO(), options)
+				},
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name: "test",
+				Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+					return nil
+				}),
+				Retriever:    ret,
+				WatchTimeout: test.watchTimeout,
+				Logger:       log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			select {
+			case opts := <-watchOptsC:
+				test.expTimeout(t, opts.TimeoutSeconds)
+			case <-time.After(1 * time.Second):
+				require.FailNow("timeout waiting for the watch")
+			}
+		})
+	}
+}
+
+func TestGenericControllerForgetOnNotFound(t *testing.T) {
+	const retries = 3
+
+	tests := map[string]struct {
+		disableForgetOnNotFound bool
+		handlerErr              error
+		expCalls                int
+	}{
+		"Not found errors should not be retried by default.": {
+			handlerErr: apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "test"),
+			expCalls:   1,
+		},
+
+		"Wrapped not found errors should not be retried by default.": {
+			handlerErr: fmt.Errorf("wrapped: %w", apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "test")),
+			expCalls:   1,
+		},
+
+		"Not found errors should be retried if disabled.": {
+			disableForgetOnNotFound: true,
+			handlerErr:              apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "test"),
+			expCalls:                1 + retries,
+		},
+
+		"Other errors should be retried.": {
+			handlerErr: fmt.Errorf("wanted error"),
+			expCalls:   1 + retries,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			var calls int32
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				atomic.AddInt32(&calls, 1)
+				return test.handlerErr
+			})
+
+			forgottenC := make(chan struct{})
+			c, err := controller.New(&controller.Config{
+				Name:                    "test",
+				Handler:                 h,
+				Retriever:               newNamespaceRetriever(mc),
+				ProcessingJobRetries:    retries,
+				RetryRateLimiter:        workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond),
+				DisableForgetOnNotFound: test.disableForgetOnNotFound,
+				OnEvent: func(ev controller.Event) {
+					if ev.Type == controller.EventForgotten {
+						close(forgottenC)
+					}
+				},
+				Logger: log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			select {
+			case <-forgottenC:
+			case <-time.After(1 * time.Second):
+				require.FailNow("timeout waiting for the object to be forgotten")
+			}
+			assert.Equal(int32(test.expCalls), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestGenericControllerDependsOn(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, nss := createNamespaceList("testing", 1)
+	mc := fake.NewSimpleClientset(nss[0])
+
+	// Controller A will not sync until its list is released.
+	releaseListC := make(chan struct{})
+	retA := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			<-releaseListC
+			return nsList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	})
+	noopHandler := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error { return nil })
+	ctrlA, err := controller.New(&controller.Config{
+		Name:      "a",
+		Handler:   noopHandler,
+		Retriever: retA,
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+
+	// Controller B depends on A being synced.
+	handledBC := make(chan bool, 1)
+	ctrlB, err := controller.New(&controller.Config{
+		Name: "b",
+		Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+			select {
+			case <-ctrlA.(controller.SyncNotifier).Synced():
+				handledBC <- true
+			default:
+				handledBC <- false
+			}
+			return nil
+		}),
+		Retriever: newNamespaceRetriever(mc),
+		DependsOn: []<-chan struct{}{ctrlA.(controller.SyncNotifier).Synced()},
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+
+	go func() { _ = ctrlA.Run(ctx) }()
+	go func() { _ = ctrlB.Run(ctx) }()
+
+	// While A is not synced, B objects should be queued but not handled.
+	require.Eventually(func() bool {
+		return ctrlB.(controller.KeyStatuser).KeyStatus("testing-0") == controller.KeyStatusQueued
+	}, 1*time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(handledBC)
+
+	// Once A is synced, B objects should be handled.
+	close(releaseListC)
+	select {
+	case <-ctrlA.(controller.SyncNotifier).Synced():
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for controller A sync")
+	}
+	select {
+	case aSynced := <-handledBC:
+		assert.True(aSynced, "controller B should handle after controller A is synced")
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for controller B handling")
+	}
+}
+
+func TestGenericControllerLogRetriesAtLevel(t *testing.T) {
+	const retries = 2
+
+	tests := map[string]struct {
+		level        log.Level
+		expRetryLine string
+	}{
+		"By default the retries should be logged at warning level.": {
+			expRetryLine: "[WARN] item requeued due to processing error",
+		},
+
+		"The retries should be logged at the configured level.": {
+			level:        log.LevelDebug,
+			expRetryLine: "[DEBUG] item requeued due to processing error",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			forgottenC := make(chan struct{})
+			logger := newTestLogger()
+			c, err := controller.New(&controller.Config{
+				Name: "test",
+				Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+					return fmt.Errorf("wanted error")
+				}),
+				Retriever:                newNamespaceRetriever(mc),
+				ProcessingJobRetries:     retries,
+				RetryRateLimiter:         workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond),
+				LogRetriesAtLevel:        test.level,
+				DisableErrorLogRateLimit: true,
+				OnEvent: func(ev controller.Event) {
+					if ev.Type == controller.EventForgotten {
+						close(forgottenC)
+					}
+				},
+				Logger: logger,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			select {
+			case <-forgottenC:
+			case <-time.After(1 * time.Second):
+				require.FailNow("timeout waiting for the object to be forgotten")
+			}
+			// The errors are logged after handling.
+			time.Sleep(20 * time.Millisecond)
+
+			// The transient errors should be logged at the retries level, and the exhausted at error level.
+			assert.Len(logger.Lines(test.expRetryLine), retries)
+			assert.Len(logger.Lines("[ERROR] error on object processing"), 1)
+		})
+	}
+}
+
+func TestGenericControllerSkipUnchangedResyncs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 2)
+	mc := fake.NewSimpleClientset(nss[0], nss[1])
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Namespace).Name]++
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		ResyncInterval:       1 * time.Second, // Minimum resync allowed by the informers.
+		SkipUnchangedResyncs: true,
+		// Ignore the update events, so the changes are only handled by the resyncs.
+		UpdateChangeDetector: func(_, _ runtime.Object) bool { return false },
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["testing-0"] == 1 && handled["testing-1"] == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Change an object since the last handling.
+	ns := nss[1].DeepCopy()
+	ns.Labels = map[string]string{"changed": "true"}
+	ns.ResourceVersion = "42"
+	_, err = mc.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	require.NoError(err)
+
+	// The changed object should be handled on the resync, and the unchanged should be skipped.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["testing-1"] == 2
+	}, 3*time.Second, 5*time.Millisecond)
+	time.Sleep(1500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"testing-0": 1, "testing-1": 2}, handled)
+}
+
+func TestGenericControllerRequestID(t *testing.T) {
+	const objects = 5
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", objects)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The objects are handled concurrently and fail so the processing is logged.
+	var mu sync.Mutex
+	ids := map[string]string{}
+	h := controller.HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		ids[obj.(*corev1.Namespace).Name] = controller.RequestIDFromContext(ctx)
+		return fmt.Errorf("wanted error")
+	})
+
+	logger := newTestLogger()
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: objects,
+		Logger:            logger,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		return len(logger.Lines("error on object processing")) == objects
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Every processing should have a different ID, present on its logs.
+	mu.Lock()
+	defer mu.Unlock()
+	uniqueIDs := map[string]bool{}
+	for name, id := range ids {
+		require.NotEmpty(id)
+		uniqueIDs[id] = true
+		lines := logger.Lines("request-id:" + id)
+		if assert.Len(lines, 1) {
+			assert.Contains(lines[0], "object-key:"+name)
+		}
+	}
+	assert.Len(uniqueIDs, objects)
+	assert.Empty(controller.RequestIDFromContext(context.Background()))
+}
+
+func TestGenericControllerInitialSyncOrder(t *testing.T) {
+	now := time.Now()
+	newNs := func(name string, createdAgo time.Duration) corev1.Namespace {
+		return corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-createdAgo)),
+		}}
+	}
+
+	tests := map[string]struct {
+		order    controller.ObjectLess
+		expOrder []string
+	}{
+		"Without order, the objects should be processed in the retriever order.": {
+			expOrder: []string{"ns-a", "ns-b", "ns-c", "ns-d", "ns-e"},
+		},
+
+		"Ordering by creation timestamp should process the oldest objects first, by name on the same time.": {
+			order:    controller.ByCreationTimestamp,
+			expOrder: []string{"ns-d", "ns-b", "ns-e", "ns-a", "ns-c"},
+		},
+
+		"A custom order should process the objects in that order.": {
+			order: func(a, b runtime.Object) bool {
+				return a.(*corev1.Namespace).Name > b.(*corev1.Namespace).Name
+			},
+			expOrder: []string{"ns-e", "ns-d", "ns-c", "ns-b", "ns-a"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList := &corev1.NamespaceList{Items: []corev1.Namespace{
+				newNs("ns-a", 1*time.Hour),
+				newNs("ns-b", 3*time.Hour),
+				newNs("ns-c", 1*time.Hour),
+				newNs("ns-d", 4*time.Hour),
+				newNs("ns-e", 2*time.Hour),
+			}}
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			var mu sync.Mutex
+			handled := []string{}
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled = append(handled, obj.(*corev1.Namespace).Name)
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:              "test",
+				Handler:           h,
+				Retriever:         newNamespaceRetriever(mc),
+				InitialSyncOrder:  test.order,
+				ConcurrentWorkers: 1,
+				Logger:            log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handled) == len(test.expOrder)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(test.expOrder, handled)
+		})
+	}
+}
+
+func TestGenericControllerListOptionsMutator(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+
+	// Store the options received by the retriever.
+	var mu sync.Mutex
+	listOpts := []metav1.ListOptions{}
+	watchOpts := []metav1.ListOptions{}
+	ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			listOpts = append(listOpts, options)
+			return nsList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			watchOpts = append(watchOpts, options)
+			return watch.NewFake(), nil
+		},
+	})
+
+	calls := 0
+	mutator := func(options *metav1.ListOptions) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		options.FieldSelector = fmt.Sprintf("metadata.name=call-%d", calls)
+	}
+
+	c, err := controller.New(&controller.Config{
+		Name:               "test",
+		Handler:            controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Retriever:          ret,
+		LabelSelector:      labels.SelectorFromSet(labels.Set{"app": "test"}),
+		ListOptionsMutator: mutator,
+		Logger:             log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(watchOpts) > 0
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The mutator should have been called on the list and on the watch, keeping the controller options.
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(listOpts, 1)
+	assert.Equal("metadata.name=call-1", listOpts[0].FieldSelector)
+	assert.Equal("app=test", listOpts[0].LabelSelector)
+	assert.Equal("metadata.name=call-2", watchOpts[0].FieldSelector)
+	assert.Equal("app=test", watchOpts[0].LabelSelector)
+}
+
+func TestGenericControllerAutoScale(t *testing.T) {
+	const (
+		minWorkers = 1
+		maxWorkers = 4
+		objects    = 20
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", objects)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// Block the handlings until released, so the queue grows, and track the concurrent handlings.
+	release := make(chan struct{})
+	var mu sync.Mutex
+	handled, inFlight, maxInFlight := 0, 0, 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		inFlight--
+		handled++
+		mu.Unlock()
+		return nil
+	})
+
+	logger := newTestLogger()
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		AutoScale:         true,
+		MinWorkers:        minWorkers,
+		MaxWorkers:        maxWorkers,
+		AutoScaleInterval: 20 * time.Millisecond,
+		Logger:            logger,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The workers should scale up to the max with the queue backlog.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return inFlight == maxWorkers
+	}, 1*time.Second, 5*time.Millisecond)
+	assert.NotEmpty(logger.Lines(fmt.Sprintf("scaling workers from %d to %d", minWorkers, maxWorkers)))
+
+	// Once drained, the workers should scale down to the min.
+	close(release)
+	require.Eventually(func() bool {
+		return len(logger.Lines(fmt.Sprintf("scaling workers from %d to %d", minWorkers+1, minWorkers))) == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(objects, handled)
+	assert.Equal(maxWorkers, maxInFlight)
+}
+
+func TestGenericControllerAutoScaleHandlerFactory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset()
+
+	// Each handler instance tracks if it's used concurrently, the objects block on the release
+	// channel of their name prefix.
+	releases := map[string]chan struct{}{"first": make(chan struct{}), "second": make(chan struct{})}
+	var concurrentUses, inFlight, handled int32
+	hf := func() controller.Handler {
+		var instanceInFlight int32
+		return controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			if atomic.AddInt32(&instanceInFlight, 1) > 1 {
+				atomic.AddInt32(&concurrentUses, 1)
+			}
+			atomic.AddInt32(&inFlight, 1)
+
+			ns := obj.(*corev1.Namespace)
+			for prefix, release := range releases {
+				if strings.HasPrefix(ns.Name, prefix) {
+					<-release
+				}
+			}
+
+			atomic.AddInt32(&inFlight, -1)
+			atomic.AddInt32(&instanceInFlight, -1)
+			atomic.AddInt32(&handled, 1)
+			return nil
+		})
+	}
+
+	logger := newTestLogger()
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		HandlerFactory:    hf,
+		Retriever:         newNamespaceRetriever(mc),
+		AutoScale:         true,
+		MinWorkers:        1,
+		MaxWorkers:        2,
+		AutoScaleInterval: 10 * time.Millisecond,
+		Logger:            logger,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+	<-c.(controller.SyncNotifier).Synced()
+
+	add := func(name string) {
+		err := c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		require.NoError(err)
+	}
+
+	// Scale up with a backlog that keeps the queue busy, so the workers are idle (blocked waiting
+	// for an object) when scaled down once drained.
+	add("first-0")
+	add("first-1")
+	add("first-2")
+	require.Eventually(func() bool { return atomic.LoadInt32(&inFlight) == 2 }, 1*time.Second, 5*time.Millisecond)
+	close(releases["first"])
+	require.Eventually(func() bool {
+		return atomic.LoadInt32(&handled) == 3 && len(logger.Lines("scaling workers from 2 to 1")) == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The running worker and the retired one get an object each, then scale up while the retired
+	// worker is still handling its object.
+	add("second-0")
+	add("second-1")
+	require.Eventually(func() bool { return atomic.LoadInt32(&inFlight) == 2 }, 1*time.Second, 5*time.Millisecond)
+	add("third-0")
+	require.Eventually(func() bool {
+		return len(logger.Lines("scaling workers from 1 to 2")) >= 2
+	}, 1*time.Second, 5*time.Millisecond)
+	close(releases["second"])
+	require.Eventually(func() bool { return atomic.LoadInt32(&handled) == 6 }, 1*time.Second, 5*time.Millisecond)
+
+	assert.Equal(int32(0), atomic.LoadInt32(&concurrentUses))
+}
+
+func TestGenericControllerSynced(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+
+	// The list is blocked until released, so the cache can't be synced before.
+	nsList, _ := createNamespaceList("testing", 1)
+	releaseListC := make(chan struct{})
+	ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			<-releaseListC
+			return nsList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	})
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Retriever: ret,
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+
+	isClosed := func() bool {
+		select {
+		case <-c.(controller.SyncNotifier).Synced():
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Before running and while syncing, the channel should be open.
+	require.False(isClosed())
+	stoppedC := make(chan struct{})
+	go func() {
+		_ = c.Run(ctx)
+		close(stoppedC)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.False(isClosed())
+
+	// Once synced, the channel should be closed.
+	close(releaseListC)
+	require.Eventually(isClosed, 1*time.Second, 5*time.Millisecond)
+
+	// Once synced it should remain closed, even after stopping the controller.
+	cancelCtx()
+	<-stoppedC
+	require.True(isClosed())
+}
+
+func TestGenericControllerReady(t *testing.T) {
+	tests := map[string]struct {
+		readyAfterKey  string
+		sentinelFails  int
+		expReadyAfterN int
+	}{
+		"Without ready key the controller should be ready when the workers start.": {
+			expReadyAfterN: 0,
+		},
+
+		"With ready key the controller should be ready after the key is handled successfully.": {
+			readyAfterKey:  "bootstrap",
+			sentinelFails:  2,
+			expReadyAfterN: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bootstrap"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+			)
+
+			// The sentinel handlings fail until the expected number of failures.
+			var mu sync.Mutex
+			sentinelHandled := 0
+			sentinelFails := test.sentinelFails
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				if obj.(*corev1.Namespace).Name != "bootstrap" {
+					return nil
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				sentinelHandled++
+				if sentinelHandled <= sentinelFails {
+					return fmt.Errorf("wanted error")
+				}
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                  "test",
+				Handler:               h,
+				Retriever:             newNamespaceRetriever(mc),
+				ReadyAfterKey:         test.readyAfterKey,
+				ReadyAfterKeyInterval: 20 * time.Millisecond,
+				Logger:                log.Dummy,
+			})
+			require.NoError(err)
+
+			isReady := func() bool {
+				select {
+				case <-c.(controller.SyncNotifier).Ready():
+					return true
+				default:
+					return false
+				}
+			}
+
+			require.False(isReady())
+			go func() { _ = c.Run(ctx) }()
+			require.Eventually(isReady, 1*time.Second, 5*time.Millisecond)
+
+			// The ready key should have been handled successfully before being ready.
+			mu.Lock()
+			defer mu.Unlock()
+			if test.readyAfterKey != "" {
+				assert.Equal(test.expReadyAfterN, sentinelHandled)
+			}
+		})
+	}
+}
+
+func TestGenericControllerSeedObjects(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	newNS := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"}}
+	}
+
+	// The list is blocked until released, so the cache can't be synced before.
+	releaseListC := make(chan struct{})
+	ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			<-releaseListC
+			return &corev1.NamespaceList{
+				ListMeta: metav1.ListMeta{ResourceVersion: "2"},
+				Items:    []corev1.Namespace{*newNS("seeded-0"), *newNS("listed-0")},
+			}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	})
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	deleted := map[string]int{}
+	record := func(m map[string]int) controller.Handler {
+		return controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			m[obj.(metav1.Object).GetName()]++
+			return nil
+		})
+	}
+	c, err := controller.New(&controller.Config{
+		Name:          "test",
+		Handler:       record(handled),
+		DeleteHandler: record(deleted),
+		Retriever:     ret,
+		Logger:        log.Dummy,
+	})
+	require.NoError(err)
+
+	err = c.(controller.Seeder).SeedObjects([]runtime.Object{newNS("seeded-0"), newNS("seeded-1")})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The seeded objects should be handled before the cache sync.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["seeded-0"] == 1 && handled["seeded-1"] == 1
+	}, 1*time.Second, 5*time.Millisecond)
+	select {
+	case <-c.(controller.SyncNotifier).Synced():
+		require.Fail("cache should not be synced")
+	default:
+	}
+
+	// Objects can't be seeded while running.
+	err = c.(controller.Seeder).SeedObjects([]runtime.Object{newNS("seeded-2")})
+	assert.Error(err)
+
+	// Once listed, the new objects should be handled and the missing seeded objects deleted.
+	close(releaseListC)
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["listed-0"] == 1 && deleted["seeded-1"] == 1
+	}, 1*time.Second, 5*time.Millisecond)
+	<-c.(controller.SyncNotifier).Synced()
+}
+
+func TestGenericControllerListWatchOverlap(t *testing.T) {
+	newNS := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"}}
+	}
+
+	tests := map[string]struct {
+		duplicateAfterHandling bool
+		skipUnchangedResyncs   bool
+	}{
+		"A duplicated add received while the object is queued should be deduplicated by the queue.": {
+			duplicateAfterHandling: false,
+		},
+
+		"A duplicated add received after handling the object should be skipped as an unchanged resync.": {
+			duplicateAfterHandling: true,
+			skipUnchangedResyncs:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			var mu sync.Mutex
+			handled := map[string]int{}
+			handledC := make(chan struct{})
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				name := obj.(*corev1.Namespace).Name
+				handled[name]++
+				if name == "ns-0" && handled[name] == 1 {
+					close(handledC)
+				}
+				return nil
+			})
+
+			// The first watch delivers the listed object again (overlap) and then a new object, the
+			// informer delivers the events in order, so the duplicate is processed before the new one.
+			duplicatedC := make(chan struct{})
+			duplicateAfterHandling := test.duplicateAfterHandling
+			ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return &corev1.NamespaceList{
+						ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+						Items:    []corev1.Namespace{*newNS("ns-0")},
+					}, nil
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					fw := watch.NewFakeWithChanSize(2, false)
+					go func() {
+						if duplicateAfterHandling {
+							<-handledC
+						}
+						fw.Add(newNS("ns-0"))
+						fw.Add(newNS("ns-1"))
+					}()
+					return fw, nil
+				},
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            ret,
+				SkipUnchangedResyncs: test.skipUnchangedResyncs,
+				OnEvent: func(ev controller.Event) {
+					if ev.Key == "ns-1" && ev.Type == controller.EventEnqueued {
+						close(duplicatedC)
+					}
+				},
+				// Start handling after the duplicate if it has to be received while queued.
+				WaitUntilReady: func(ctx context.Context) error {
+					if !duplicateAfterHandling {
+						<-duplicatedC
+					}
+					return nil
+				},
+				Logger: log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return handled["ns-1"] == 1
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// The listed object should be handled once despite the overlap.
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(map[string]int{"ns-0": 1, "ns-1": 1}, handled)
+		})
+	}
+}
+
+func TestGenericControllerSyncSummaryLog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod2"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "pod1"}},
+	)
+	logger := newTestLogger()
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Retriever:         newPodRetriever(mc),
+		ConcurrentWorkers: 3,
+		ResyncInterval:    time.Minute,
+		Logger:            logger,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// A single summary line should be logged with the configuration and the synced objects.
+	require.Eventually(func() bool { return len(logger.Lines("controller cache synced")) == 1 }, 1*time.Second, 5*time.Millisecond)
+	line := logger.Lines("controller cache synced")[0]
+	for _, exp := range []string{"controller-id:test", "resource:Pod", "namespaces:2", "workers:3", "resync-interval:1m0s", "synced-objects:3"} {
+		assert.Contains(line, exp)
+	}
+}
+
+func TestGenericControllerKeyNormalizer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app-secondary"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "orphan-secondary"}},
+	)
+
+	handledC := make(chan string, 10)
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		handledC <- obj.(*corev1.Namespace).Name
+		return nil
+	})
+	deletedC := make(chan runtime.Object, 10)
+	dh := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		deletedC <- obj
+		return nil
+	})
+
+	// The secondary keys are handled as their primary key, a single worker handles them in order.
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		DeleteHandler:     dh,
+		Retriever:         newNamespaceRetriever(mc),
+		KeyNormalizer:     func(key string) string { return strings.TrimSuffix(key, "-secondary") },
+		ConcurrentWorkers: 1,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	nextHandled := func() string {
+		select {
+		case name := <-handledC:
+			return name
+		case <-time.After(1 * time.Second):
+			require.FailNow("timeout waiting for handling")
+		}
+		return ""
+	}
+	nextDeleted := func() runtime.Object {
+		select {
+		case obj := <-deletedC:
+			return obj
+		case <-time.After(1 * time.Second):
+			require.FailNow("timeout waiting for delete handling")
+		}
+		return nil
+	}
+
+	// The secondary key without primary object is handled as deleted, the rest as the primary object.
+	assert.Equal("orphan", nextDeleted().(*metav1.PartialObjectMetadata).Name)
+	<-c.(controller.SyncNotifier).Synced()
+	require.NoError(c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sentinel"}}))
+	handled := []string{}
+	for name := nextHandled(); name != "sentinel"; name = nextHandled() {
+		handled = append(handled, name)
+	}
+	assert.NotEmpty(handled)
+	for _, name := range handled {
+		assert.Equal("app", name)
+	}
+
+	// Deleting a secondary object should handle its primary object.
+	require.NoError(mc.CoreV1().Namespaces().Delete(ctx, "app-secondary", metav1.DeleteOptions{}))
+	assert.Equal("app", nextHandled())
+
+	// Deleting a secondary object without primary object should handle the deleted object.
+	require.NoError(mc.CoreV1().Namespaces().Delete(ctx, "orphan-secondary", metav1.DeleteOptions{}))
+	assert.Equal("orphan-secondary", nextDeleted().(*corev1.Namespace).Name)
+}
+
+func TestGenericControllerRetryPolicy(t *testing.T) {
+	const retryDelay = 50 * time.Millisecond
+	errValidation := fmt.Errorf("validation error")
+
+	// Retry the validation errors twice with a fixed delay, the rest are not retried.
+	policy := func(err error, attempt int) (bool, time.Duration) {
+		if errors.Is(err, errValidation) && attempt <= 2 {
+			return true, retryDelay
+		}
+		return false, 0
+	}
+
+	tests := map[string]struct {
+		handlerErrs []error
+		expAttempts []int
+		expCalls    int
+	}{
+		"Validation errors should be retried twice with the delay and then given up.": {
+			handlerErrs: []error{errValidation, errValidation, errValidation},
+			expAttempts: []int{1, 2, 3},
+			expCalls:    3,
+		},
+
+		"Other errors should not be retried.": {
+			handlerErrs: []error{fmt.Errorf("other error")},
+			expAttempts: []int{1},
+			expCalls:    1,
+		},
+
+		"A successful retry should stop the retries.": {
+			handlerErrs: []error{errValidation, nil},
+			expAttempts: []int{1},
+			expCalls:    2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			var mu sync.Mutex
+			calledAt := []time.Time{}
+			handlerErrs := test.handlerErrs
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calledAt = append(calledAt, time.Now())
+				if len(calledAt) > len(handlerErrs) {
+					return nil
+				}
+				return handlerErrs[len(calledAt)-1]
+			})
+
+			var policyMu sync.Mutex
+			gotAttempts := []int{}
+			c, err := controller.New(&controller.Config{
+				Name:      "test",
+				Handler:   h,
+				Retriever: newNamespaceRetriever(mc),
+				RetryPolicy: func(err error, attempt int) (bool, time.Duration) {
+					policyMu.Lock()
+					defer policyMu.Unlock()
+					gotAttempts = append(gotAttempts, attempt)
+					return policy(err, attempt)
+				},
+				Logger: log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(calledAt) == test.expCalls
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// Wait to check there are no more retries.
+			time.Sleep(3 * retryDelay)
+			mu.Lock()
+			defer mu.Unlock()
+			policyMu.Lock()
+			defer policyMu.Unlock()
+			require.Len(calledAt, test.expCalls)
+			assert.Equal(test.expAttempts, gotAttempts)
+			for i := 1; i < len(calledAt); i++ {
+				assert.GreaterOrEqual(calledAt[i].Sub(calledAt[i-1]), retryDelay)
+			}
+		})
+	}
+}
+
+func TestGenericControllerOnRelist(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+
+	var mu sync.Mutex
+	lists := 0
+	watchers := []*watch.FakeWatcher{}
+	ret := controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			lists++
+			return nsList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			w := watch.NewFake()
+			watchers = append(watchers, w)
+			return w, nil
+		},
+	})
+	lastWatcher := func() *watch.FakeWatcher {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(watchers) == 0 {
+			return nil
+		}
+		return watchers[len(watchers)-1]
+	}
+
+	var relists int32
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Retriever: ret,
+		OnRelist:  func() { atomic.AddInt32(&relists, 1) },
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The initial list should not be a relist.
+	require.Eventually(func() bool { return lastWatcher() != nil }, 1*time.Second, 5*time.Millisecond)
+	assert.Equal(int32(0), atomic.LoadInt32(&relists))
+
+	// Reset the watch twice with an expired error, the informer should relist each time.
+	for i := 1; i <= 2; i++ {
+		w := lastWatcher()
+		w.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+		require.Eventually(func() bool { return lastWatcher() != w }, 5*time.Second, 5*time.Millisecond)
+		assert.Equal(int32(i), atomic.LoadInt32(&relists))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(3, lists)
+}
+
+func TestGenericControllerResyncClassifier(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "hot", Labels: map[string]string{"tier": "critical"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cold"}},
+	)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	resynced := map[string]int{}
+	classified := 0
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Namespace).Name]++
+		return nil
+	})
+
+	// The critical objects are resynced every minute, the rest every 5 minutes.
+	clk := testclock.NewFakeClock(time.Now())
+	c, err := controller.New(&controller.Config{
+		Name:           "test",
+		Handler:        h,
+		Retriever:      newNamespaceRetriever(mc),
+		ResyncInterval: 5 * time.Minute,
+		ResyncClassifier: func(obj runtime.Object) time.Duration {
+			mu.Lock()
+			classified++
+			mu.Unlock()
+			if obj.(*corev1.Namespace).Labels["tier"] == "critical" {
+				return 1 * time.Minute
+			}
+			return 0
+		},
+		Clock: clk,
+		OnEvent: func(ev controller.Event) {
+			if ev.Type == controller.EventResynced {
+				mu.Lock()
+				resynced[ev.Key]++
+				mu.Unlock()
+			}
+		},
+		Logger: log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["hot"] > 0 && handled["cold"] > 0
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Move the time 10 minutes, waiting for the resyncer on each step. The objects are seen when added,
+	// so the hot one is resynced at 1m, 2m... and the cold one at 5m and 10m.
+	for i := 0; i < 20; i++ {
+		require.Eventually(clk.HasWaiters, 1*time.Second, time.Millisecond)
+		clk.Step(30 * time.Second)
+	}
+	require.Eventually(clk.HasWaiters, 1*time.Second, time.Millisecond)
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["hot"] == 11 && handled["cold"] == 3
+	}, 1*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"hot": 10, "cold": 2}, resynced)
+	// Only the due objects should be classified again (once when seen and on each resync).
+	assert.Equal(2+10+2, classified)
+}
+
+func TestGenericControllerResyncClassifierDisabledResync(t *testing.T) {
+	_, err := controller.New(&controller.Config{
+		Name:             "test",
+		Handler:          controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Retriever:        newNamespaceRetriever(fake.NewSimpleClientset()),
+		DisableResync:    true,
+		ResyncClassifier: func(runtime.Object) time.Duration { return time.Minute },
+		Logger:           log.Dummy,
+	})
+	assert.ErrorIs(t, err, controller.ErrControllerNotValid)
+}
+
+func TestGenericControllerStopAndDrain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "handled"}})
+
+	// The handlings block until released and fail, except the first one.
+	startedC := make(chan string, 10)
+	releaseC := make(chan struct{})
+	h := controller.HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		name := obj.(*corev1.Namespace).Name
+		startedC <- name
+		if name == "handled" {
+			return nil
+		}
+		<-releaseC
+		return fmt.Errorf("wanted error")
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: 1,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+
+	// Can't be drained if not running.
+	_, err = c.(controller.Drainer).StopAndDrain(ctx)
+	assert.Error(err)
+
+	runErrC := make(chan error, 1)
+	go func() { runErrC <- c.Run(ctx) }()
+	require.Equal("handled", <-startedC)
+	require.Eventually(func() bool { return c.(controller.KeyStatuser).KeyStatus("handled") == controller.KeyStatusNotPresent }, 1*time.Second, 5*time.Millisecond)
+
+	// Block the only worker and queue more keys behind it.
+	err = c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "blocked"}})
+	require.NoError(err)
+	require.Equal("blocked", <-startedC)
+	for _, name := range []string{"queued-1", "queued-0"} {
+		err = c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		require.NoError(err)
+	}
+
+	// The stop waits for the blocked handling, that fails.
+	drainCtx, cancelDrain := context.WithTimeout(ctx, 1*time.Second)
+	defer cancelDrain()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(releaseC)
+	}()
+	keys, err := c.(controller.Drainer).StopAndDrain(drainCtx)
+	require.NoError(err)
+	assert.Equal([]string{"blocked", "queued-0", "queued-1"}, keys)
+
+	// The controller should be stopped without errors.
+	select {
+	case err := <-runErrC:
+		assert.NoError(err)
+	case <-time.After(1 * time.Second):
+		require.Fail("controller should be stopped")
+	}
+}
+
+func TestGenericControllerLogUpdateDiffs(t *testing.T) {
+	tests := map[string]struct {
+		maxSize int
+		expDiff string
+	}{
+		"The update diffs should be logged.": {
+			expDiff: `metadata.labels.app: "a" -> "b", metadata.labels.team: <none> -> "platform", spec.nodeName: <none> -> "node1"`,
+		},
+
+		"The big update diffs should be truncated.": {
+			maxSize: 20,
+			expDiff: `metadata.labels.app:...(truncated)`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			pod := newPod("ns1", "pod1")
+			pod.Labels = map[string]string{"app": "a"}
+			mc := fake.NewSimpleClientset(pod)
+			logger := newTestLogger()
+			c, err := controller.New(&controller.Config{
+				Name:              "test",
+				Handler:           controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+				Retriever:         newPodRetriever(mc),
+				LogUpdateDiffs:    true,
+				UpdateDiffMaxSize: test.maxSize,
+				Logger:            logger,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+			<-c.(controller.SyncNotifier).Synced()
+
+			pod = pod.DeepCopy()
+			pod.Labels = map[string]string{"app": "b", "team": "platform"}
+			pod.Spec.NodeName = "node1"
+			pod.ResourceVersion = "2"
+			_, err = mc.CoreV1().Pods("ns1").Update(ctx, pod, metav1.UpdateOptions{})
+			require.NoError(err)
+
+			require.Eventually(func() bool { return len(logger.Lines("object updated")) == 1 }, 1*time.Second, 5*time.Millisecond)
+			line := logger.Lines("object updated")[0]
+			assert.Contains(line, "object-key:ns1/pod1")
+			assert.Contains(line, "diff:"+test.expDiff)
+		})
+	}
+}
+
+func TestGenericControllerStopResync(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "terminal", Labels: map[string]string{"state": "done"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "active"}},
+	)
+
+	// The objects in a terminal state stop their resyncs.
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.ResultHandlerFunc(func(_ context.Context, obj runtime.Object) (controller.Result, error) {
+		ns := obj.(*corev1.Namespace)
+		mu.Lock()
+		defer mu.Unlock()
+		handled[ns.Name]++
+		return controller.Result{StopResync: ns.Labels["state"] == "done"}, nil
+	})
+	getHandled := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[name]
+	}
+
+	c, err := controller.New(&controller.Config{
+		Name:           "test",
+		ResultHandler:  h,
+		Retriever:      newNamespaceRetriever(mc),
+		ResyncInterval: 1 * time.Second, // Minimum resync allowed by the informers.
+		Logger:         log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The active object should be resynced, the terminal one not.
+	require.Eventually(func() bool { return getHandled("active") >= 3 }, 5*time.Second, 5*time.Millisecond)
+	require.Equal(1, getHandled("terminal"))
+
+	// A watch update should handle the object and enable its resyncs again.
+	ns, err := mc.CoreV1().Namespaces().Get(ctx, "terminal", metav1.GetOptions{})
+	require.NoError(err)
+	ns.Labels = nil
+	ns.ResourceVersion = "2"
+	_, err = mc.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	require.NoError(err)
+	require.Eventually(func() bool { return getHandled("terminal") >= 3 }, 5*time.Second, 5*time.Millisecond)
+}
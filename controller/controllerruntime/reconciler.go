@@ -0,0 +1,54 @@
+// Package controllerruntime has helpers to reuse controller-runtime components on Kooper controllers.
+package controllerruntime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+// RequeueDelay is the delay used to queue the object again when the reconciler requests
+// a requeue without a duration.
+const RequeueDelay = 1 * time.Second
+
+// FromReconcileReconciler returns a ResultHandler from a controller-runtime reconcile.Reconciler, so
+// the reconcilers can be reused on Kooper controllers. The handled object namespace and name will be
+// used as the reconcile request, and the reconcile result will be mapped to Kooper semantics:
+//
+//   - `RequeueAfter` will queue the object again after the duration.
+//   - `Requeue` will queue the object again after `RequeueDelay`.
+//
+// The deletes are not adapted, the returned handler is only called with the existing objects. To reconcile
+// the deletes, set a `Config.DeleteHandler` that calls the reconciler with the request of the deleted object,
+// like on controller-runtime the reconciler will not find the object.
+func FromReconcileReconciler(r reconcile.Reconciler) controller.ResultHandler {
+	return controller.ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (controller.Result, error) {
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return controller.Result{}, fmt.Errorf("could not get object metadata: %w", err)
+		}
+
+		req := reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: objMeta.GetNamespace(),
+			Name:      objMeta.GetName(),
+		}}
+		res, err := r.Reconcile(ctx, req)
+		switch {
+		case err != nil:
+			return controller.Result{}, err
+		case res.RequeueAfter > 0:
+			return controller.Result{RequeueAfter: res.RequeueAfter}, nil
+		case res.Requeue:
+			return controller.Result{RequeueAfter: RequeueDelay}, nil
+		}
+
+		return controller.Result{}, nil
+	})
+}
@@ -0,0 +1,81 @@
+package controllerruntime_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/controllerruntime"
+)
+
+func newPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func TestFromReconcileReconciler(t *testing.T) {
+	tests := map[string]struct {
+		obj       runtime.Object
+		recResult reconcile.Result
+		recErr    error
+		expReq    reconcile.Request
+		expResult controller.Result
+		expErr    bool
+	}{
+		"The object should be translated into a reconcile request.": {
+			obj:       newPod("ns1", "pod1"),
+			expReq:    reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "pod1"}},
+			expResult: controller.Result{},
+		},
+
+		"A reconcile error should be returned.": {
+			obj:    newPod("ns1", "pod1"),
+			recErr: fmt.Errorf("wanted error"),
+			expReq: reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "pod1"}},
+			expErr: true,
+		},
+
+		"A reconcile requeue after should be translated into a requeue after result.": {
+			obj:       newPod("ns1", "pod1"),
+			recResult: reconcile.Result{RequeueAfter: 42 * time.Second},
+			expReq:    reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "pod1"}},
+			expResult: controller.Result{RequeueAfter: 42 * time.Second},
+		},
+
+		"A reconcile requeue should be translated into a requeue after the default delay result.": {
+			obj:       newPod("ns1", "pod1"),
+			recResult: reconcile.Result{Requeue: true},
+			expReq:    reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "pod1"}},
+			expResult: controller.Result{RequeueAfter: controllerruntime.RequeueDelay},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var gotReq reconcile.Request
+			rec := reconcile.Func(func(_ context.Context, req reconcile.Request) (reconcile.Result, error) {
+				gotReq = req
+				return test.recResult, test.recErr
+			})
+
+			res, err := controllerruntime.FromReconcileReconciler(rec).HandleWithResult(context.TODO(), test.obj)
+
+			assert.Equal(test.expReq, gotReq)
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, res)
+			}
+		})
+	}
+}
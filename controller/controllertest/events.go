@@ -0,0 +1,130 @@
+package controllertest
+
+import (
+	"context"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventsRetriever is a controller.Retriever that sends a list of watch events to a controller
+// without a cluster, this is useful to test the handling of edge cases like bookmarks and
+// watch errors.
+//
+// The events are sent in order on the watches, when a watch ends (e.g: after an error event)
+// the next watch continues with the remaining events. The lists return the objects of the
+// events sent until then, so the relists after a watch error don't change the controller cache.
+type EventsRetriever struct {
+	mu      sync.Mutex
+	events  []watch.Event
+	next    int
+	keys    []string
+	objects map[string]runtime.Object
+	doneC   chan struct{}
+}
+
+// NewEventsRetriever returns a new EventsRetriever.
+func NewEventsRetriever(events []watch.Event) *EventsRetriever {
+	r := &EventsRetriever{
+		events:  events,
+		objects: map[string]runtime.Object{},
+		doneC:   make(chan struct{}),
+	}
+	if len(events) == 0 {
+		close(r.doneC)
+	}
+
+	return r
+}
+
+// List satisfies controller.Retriever interface.
+func (r *EventsRetriever) List(_ context.Context, _ metav1.ListOptions) (runtime.Object, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := &metav1.List{}
+	for _, key := range r.keys {
+		list.Items = append(list.Items, runtime.RawExtension{Object: r.objects[key].DeepCopyObject()})
+	}
+
+	return list, nil
+}
+
+// Watch satisfies controller.Retriever interface.
+func (r *EventsRetriever) Watch(_ context.Context, _ metav1.ListOptions) (watch.Interface, error) {
+	evC := make(chan watch.Event)
+	w := watch.NewProxyWatcher(evC)
+	go r.send(w, evC)
+	return w, nil
+}
+
+// send sends the remaining events to the watcher until the watcher is stopped or an error event
+// is sent (the watch ends on errors).
+func (r *EventsRetriever) send(w *watch.ProxyWatcher, evC chan<- watch.Event) {
+	for {
+		r.mu.Lock()
+		if r.next >= len(r.events) {
+			r.mu.Unlock()
+			return
+		}
+		ev := r.events[r.next]
+		r.mu.Unlock()
+
+		select {
+		case evC <- ev:
+		case <-w.StopChan():
+			return
+		}
+
+		r.mu.Lock()
+		r.apply(ev)
+		r.next++
+		if r.next == len(r.events) {
+			close(r.doneC)
+		}
+		r.mu.Unlock()
+
+		if ev.Type == watch.Error {
+			return
+		}
+	}
+}
+
+// apply updates the listed objects with a sent event, must be called with the lock acquired.
+func (r *EventsRetriever) apply(ev watch.Event) {
+	switch ev.Type {
+	case watch.Added, watch.Modified, watch.Deleted:
+	default:
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(ev.Object)
+	if err != nil {
+		return
+	}
+
+	_, exists := r.objects[key]
+	switch {
+	case ev.Type == watch.Deleted && exists:
+		delete(r.objects, key)
+		for i, k := range r.keys {
+			if k == key {
+				r.keys = append(r.keys[:i], r.keys[i+1:]...)
+				break
+			}
+		}
+	case ev.Type != watch.Deleted:
+		if !exists {
+			r.keys = append(r.keys, key)
+		}
+		r.objects[key] = ev.Object
+	}
+}
+
+// Done returns a channel that will be closed when all the events have been sent.
+func (r *EventsRetriever) Done() <-chan struct{} {
+	return r.doneC
+}
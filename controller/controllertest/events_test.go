@@ -0,0 +1,94 @@
+package controllertest_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/controllertest"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestEventsRetriever(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	withRV := func(ns *corev1.Namespace, rv string) *corev1.Namespace {
+		ns.ResourceVersion = rv
+		return ns
+	}
+	ret := controllertest.NewEventsRetriever([]watch.Event{
+		{Type: watch.Bookmark, Object: withRV(newNamespace("bookmark", nil), "1")},
+		{Type: watch.Error, Object: &metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired}},
+		{Type: watch.Added, Object: withRV(newNamespace("ns-0", nil), "2")},
+		{Type: watch.Added, Object: withRV(newNamespace("ns-1", nil), "3")},
+		{Type: watch.Modified, Object: withRV(newNamespace("ns-0", map[string]string{"team": "a"}), "4")},
+		{Type: watch.Deleted, Object: withRV(newNamespace("ns-1", nil), "5")},
+		{Type: watch.Added, Object: withRV(newNamespace("ns-2", nil), "6")},
+	})
+
+	var mu sync.Mutex
+	handled := map[string]map[string]string{}
+	deleted := map[string]bool{}
+	enqueued := map[string]int{}
+	c, err := controller.New(&controller.Config{
+		Name: "test",
+		Handler: controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ns := obj.(*corev1.Namespace)
+			handled[ns.Name] = ns.Labels
+			return nil
+		}),
+		DeleteHandler: controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// The deleted objects can be handled without their last known state.
+			deleted[obj.(metav1.Object).GetName()] = true
+			return nil
+		}),
+		OnEvent: func(ev controller.Event) {
+			if ev.Type != controller.EventEnqueued {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			enqueued[ev.Key]++
+		},
+		Retriever: ret,
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The watch error makes the informer watch again, so the events take the watch retry backoff.
+	select {
+	case <-ret.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail("events not sent")
+	}
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := handled["ns-2"]
+		return deleted["ns-1"] && ok && handled["ns-0"]["team"] == "a"
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The bookmark and error events should not be queued.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"ns-0": 2, "ns-1": 2, "ns-2": 1}, enqueued)
+	assert.Equal(map[string]bool{"ns-1": true}, deleted)
+}
@@ -0,0 +1,63 @@
+package controllertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+// ErrInjectedFault is the default error of the injected faults.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjectorConfig is the configuration of the fault injector.
+type FaultInjectorConfig struct {
+	// Rate is the fraction of the handlings that will fail (from 0 to 1).
+	Rate float64
+	// Err is the error of the injected faults. By default `ErrInjectedFault`.
+	Err error
+	// Seed is the seed of the random faults, use a fixed one to inject the same faults on every run.
+	// By default a random seed.
+	Seed int64
+}
+
+func (c *FaultInjectorConfig) defaults() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return errors.New("rate must be between 0 and 1")
+	}
+
+	if c.Err == nil {
+		c.Err = ErrInjectedFault
+	}
+
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+
+	return nil
+}
+
+// NewFaultInjector returns a controller.FaultInjector that fails randomly the configured rate of the
+// handlings, to test the resilience of the controllers to transient failures (`controller.Config.FaultInjector`).
+func NewFaultInjector(cfg FaultInjectorConfig) (controller.FaultInjector, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	return func(_ context.Context, _ string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if rnd.Float64() < cfg.Rate {
+			return cfg.Err
+		}
+		return nil
+	}, nil
+}
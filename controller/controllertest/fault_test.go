@@ -0,0 +1,141 @@
+package controllertest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/controllertest"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestFaultInjector(t *testing.T) {
+	errWanted := fmt.Errorf("wanted error")
+
+	tests := map[string]struct {
+		cfg         controllertest.FaultInjectorConfig
+		expErr      bool
+		expMinFails int
+		expMaxFails int
+		expFaultErr error
+	}{
+		"A zero rate should not inject faults.": {
+			cfg:         controllertest.FaultInjectorConfig{Rate: 0},
+			expMinFails: 0,
+			expMaxFails: 0,
+		},
+
+		"A full rate should inject faults on every call.": {
+			cfg:         controllertest.FaultInjectorConfig{Rate: 1},
+			expMinFails: 1000,
+			expMaxFails: 1000,
+			expFaultErr: controllertest.ErrInjectedFault,
+		},
+
+		"A partial rate should inject faults on the fraction of the calls with the custom error.": {
+			cfg:         controllertest.FaultInjectorConfig{Rate: 0.3, Err: errWanted, Seed: 42},
+			expMinFails: 250,
+			expMaxFails: 350,
+			expFaultErr: errWanted,
+		},
+
+		"An invalid rate should fail.": {
+			cfg:    controllertest.FaultInjectorConfig{Rate: 1.5},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			injector, err := controllertest.NewFaultInjector(test.cfg)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			fails := 0
+			for i := 0; i < 1000; i++ {
+				err := injector(context.TODO(), "test")
+				if err != nil {
+					fails++
+					assert.ErrorIs(err, test.expFaultErr)
+				}
+			}
+			assert.GreaterOrEqual(fails, test.expMinFails)
+			assert.LessOrEqual(fails, test.expMaxFails)
+		})
+	}
+}
+
+func TestFaultInjectorController(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	objs := []runtime.Object{}
+	for i := 0; i < 10; i++ {
+		objs = append(objs, newNamespace(fmt.Sprintf("ns-%d", i), nil))
+	}
+	mc := fake.NewSimpleClientset(objs...)
+
+	// Half of the handlings fail with injected faults.
+	injector, err := controllertest.NewFaultInjector(controllertest.FaultInjectorConfig{Rate: 0.5, Seed: 42})
+	require.NoError(err)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	injected := 0
+	c, err := controller.New(&controller.Config{
+		Name: "test",
+		Handler: controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[obj.(*corev1.Namespace).Name]++
+			return nil
+		}),
+		OnEvent: func(ev controller.Event) {
+			if ev.Type == controller.EventFailed && errors.Is(ev.Err, controllertest.ErrInjectedFault) {
+				mu.Lock()
+				defer mu.Unlock()
+				injected++
+			}
+		},
+		Retriever:            controller.NewNamespaceRetriever(mc),
+		FaultInjector:        injector,
+		ProcessingJobRetries: 100,
+		RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Millisecond, 1),
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The faults should be retried until every object is handled.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 10
+	}, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Greater(injected, 0)
+	for name, n := range handled {
+		assert.Equal(1, n, "%s should be handled once", name)
+	}
+}
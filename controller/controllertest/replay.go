@@ -0,0 +1,232 @@
+// Package controllertest has utilities to test controllers, like recording the watch events
+// of a cluster and replaying them on a controller to reproduce issues.
+package controllertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+// Event is a recorded watch event.
+type Event struct {
+	// Type is the type of the watch event.
+	Type watch.EventType `json:"type"`
+	// At is the time of the event since the recording started.
+	At time.Duration `json:"at"`
+	// Object is the JSON of the event object, with its type information.
+	Object json.RawMessage `json:"object"`
+}
+
+// RecorderConfig is the configuration of the Recorder.
+type RecorderConfig struct {
+	// Retriever is the retriever whose watch events will be recorded.
+	Retriever controller.Retriever
+	// Scheme is used to set the type information of the recorded objects. By default
+	// client-go kubernetes scheme.
+	Scheme *runtime.Scheme
+	// Clock is the clock used to set the time of the recorded events. By default the real clock.
+	Clock clock.PassiveClock
+}
+
+func (c *RecorderConfig) defaults() error {
+	if c.Retriever == nil {
+		return fmt.Errorf("retriever is required")
+	}
+
+	if c.Scheme == nil {
+		c.Scheme = scheme.Scheme
+	}
+
+	if c.Clock == nil {
+		c.Clock = clock.RealClock{}
+	}
+
+	return nil
+}
+
+// Recorder is a controller.Retriever that records the watch events (add, update and delete) of
+// a retriever, the lists are not recorded. The recording starts when the Recorder is created.
+type Recorder struct {
+	cfg   RecorderConfig
+	start time.Time
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder(cfg RecorderConfig) (*Recorder, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &Recorder{
+		cfg:   cfg,
+		start: cfg.Clock.Now(),
+	}, nil
+}
+
+// List satisfies controller.Retriever interface.
+func (r *Recorder) List(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+	return r.cfg.Retriever.List(ctx, options)
+}
+
+// Watch satisfies controller.Retriever interface.
+func (r *Recorder) Watch(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	w, err := r.cfg.Retriever.Watch(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return watch.Filter(w, func(ev watch.Event) (watch.Event, bool) {
+		r.record(ev)
+		return ev, true
+	}), nil
+}
+
+func (r *Recorder) record(ev watch.Event) {
+	switch ev.Type {
+	case watch.Added, watch.Modified, watch.Deleted:
+	default:
+		return
+	}
+
+	// Set the type information so the object can be decoded when replaying.
+	obj := ev.Object.DeepCopyObject()
+	if obj.GetObjectKind().GroupVersionKind().Empty() {
+		gvks, _, err := r.cfg.Scheme.ObjectKinds(obj)
+		if err == nil && len(gvks) > 0 {
+			obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+		}
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{
+		Type:   ev.Type,
+		At:     r.cfg.Clock.Since(r.start),
+		Object: data,
+	})
+}
+
+// Events returns the recorded events.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event{}, r.events...)
+}
+
+// PlayerConfig is the configuration of the Player.
+type PlayerConfig struct {
+	// Events are the events that will be replayed.
+	Events []Event
+	// Scheme is used to decode the recorded objects. By default client-go kubernetes scheme.
+	Scheme *runtime.Scheme
+	// Clock is the clock used to wait for the time of the events, use a fake clock to replay
+	// the events deterministically. By default the real clock.
+	Clock clock.Clock
+}
+
+func (c *PlayerConfig) defaults() {
+	if c.Scheme == nil {
+		c.Scheme = scheme.Scheme
+	}
+
+	if c.Clock == nil {
+		c.Clock = clock.RealClock{}
+	}
+}
+
+type playerEvent struct {
+	eventType watch.EventType
+	at        time.Duration
+	obj       runtime.Object
+}
+
+// Player is a controller.Retriever that replays recorded watch events in order, honoring the
+// time of the events. The lists will be empty and the events are replayed only on the first
+// watch, starting when the watch is requested.
+type Player struct {
+	clock  clock.Clock
+	events []playerEvent
+	once   sync.Once
+	doneC  chan struct{}
+}
+
+// NewPlayer returns a new Player.
+func NewPlayer(cfg PlayerConfig) (*Player, error) {
+	cfg.defaults()
+
+	decoder := serializer.NewCodecFactory(cfg.Scheme).UniversalDeserializer()
+	events := make([]playerEvent, 0, len(cfg.Events))
+	for i, ev := range cfg.Events {
+		obj, _, err := decoder.Decode(ev.Object, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode event %d object: %w", i, err)
+		}
+		events = append(events, playerEvent{eventType: ev.Type, at: ev.At, obj: obj})
+	}
+
+	return &Player{
+		clock:  cfg.Clock,
+		events: events,
+		doneC:  make(chan struct{}),
+	}, nil
+}
+
+// List satisfies controller.Retriever interface.
+func (p *Player) List(_ context.Context, _ metav1.ListOptions) (runtime.Object, error) {
+	return &metav1.List{}, nil
+}
+
+// Watch satisfies controller.Retriever interface.
+func (p *Player) Watch(_ context.Context, _ metav1.ListOptions) (watch.Interface, error) {
+	evC := make(chan watch.Event)
+	w := watch.NewProxyWatcher(evC)
+	p.once.Do(func() { go p.play(w, evC) })
+	return w, nil
+}
+
+func (p *Player) play(w *watch.ProxyWatcher, evC chan<- watch.Event) {
+	defer close(p.doneC)
+
+	start := p.clock.Now()
+	for _, ev := range p.events {
+		if d := ev.at - p.clock.Since(start); d > 0 {
+			select {
+			case <-p.clock.After(d):
+			case <-w.StopChan():
+				return
+			}
+		}
+
+		select {
+		case evC <- watch.Event{Type: ev.eventType, Object: ev.obj}:
+		case <-w.StopChan():
+			return
+		}
+	}
+}
+
+// Done returns a channel that will be closed when all the events have been replayed.
+func (p *Player) Done() <-chan struct{} {
+	return p.doneC
+}
@@ -0,0 +1,158 @@
+package controllertest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	testclock "k8s.io/utils/clock/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/controllertest"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	// Record the events of a cluster.
+	mc := fake.NewSimpleClientset()
+	recordClock := testclock.NewFakeClock(time.Now())
+	rec, err := controllertest.NewRecorder(controllertest.RecorderConfig{
+		Retriever: controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return mc.CoreV1().Namespaces().List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return mc.CoreV1().Namespaces().Watch(ctx, options)
+			},
+		}),
+		Clock: recordClock,
+	})
+	require.NoError(err)
+	w, err := rec.Watch(ctx, metav1.ListOptions{})
+	require.NoError(err)
+	defer w.Stop()
+
+	ops := []struct {
+		step time.Duration
+		op   func() error
+	}{
+		{op: func() error {
+			_, err := mc.CoreV1().Namespaces().Create(ctx, newNamespace("ns-0", nil), metav1.CreateOptions{})
+			return err
+		}},
+		{step: 1 * time.Second, op: func() error {
+			_, err := mc.CoreV1().Namespaces().Update(ctx, newNamespace("ns-0", map[string]string{"team": "a"}), metav1.UpdateOptions{})
+			return err
+		}},
+		{step: 2 * time.Second, op: func() error {
+			return mc.CoreV1().Namespaces().Delete(ctx, "ns-0", metav1.DeleteOptions{})
+		}},
+		{op: func() error {
+			_, err := mc.CoreV1().Namespaces().Create(ctx, newNamespace("ns-1", map[string]string{"team": "b"}), metav1.CreateOptions{})
+			return err
+		}},
+	}
+	for _, op := range ops {
+		recordClock.Step(op.step)
+		require.NoError(op.op())
+		select {
+		case <-w.ResultChan():
+		case <-time.After(1 * time.Second):
+			require.FailNow("timeout waiting for the watch event")
+		}
+	}
+
+	events := rec.Events()
+	gotTypes := []watch.EventType{}
+	gotAts := []time.Duration{}
+	for _, ev := range events {
+		gotTypes = append(gotTypes, ev.Type)
+		gotAts = append(gotAts, ev.At)
+	}
+	assert.Equal([]watch.EventType{watch.Added, watch.Modified, watch.Deleted, watch.Added}, gotTypes)
+	assert.Equal([]time.Duration{0, 1 * time.Second, 3 * time.Second, 3 * time.Second}, gotAts)
+
+	// Round trip the recording.
+	data, err := json.Marshal(events)
+	require.NoError(err)
+	var loadedEvents []controllertest.Event
+	require.NoError(json.Unmarshal(data, &loadedEvents))
+
+	// Replay the events on a controller.
+	playClock := testclock.NewFakeClock(time.Now())
+	player, err := controllertest.NewPlayer(controllertest.PlayerConfig{
+		Events: loadedEvents,
+		Clock:  playClock,
+	})
+	require.NoError(err)
+
+	var mu sync.Mutex
+	handled := []string{}
+	record := func(action string) controller.Handler {
+		return controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ns := obj.(*corev1.Namespace)
+			handled = append(handled, fmt.Sprintf("%s:%s:%v", action, ns.Name, ns.Labels))
+			return nil
+		})
+	}
+	waitHandled := func(n int) {
+		require.Eventually(func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(handled) == n
+		}, 1*time.Second, 5*time.Millisecond)
+	}
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           record("handle"),
+		DeleteHandler:     record("delete"),
+		Retriever:         player,
+		ConcurrentWorkers: 1,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Move the time forward only when the player is waiting for the next event.
+	waitHandled(1)
+	require.Eventually(playClock.HasWaiters, 1*time.Second, 5*time.Millisecond)
+	playClock.Step(1 * time.Second)
+	waitHandled(2)
+	require.Eventually(playClock.HasWaiters, 1*time.Second, 5*time.Millisecond)
+	playClock.Step(2 * time.Second)
+	<-player.Done()
+	waitHandled(4)
+
+	mu.Lock()
+	defer mu.Unlock()
+	exp := []string{
+		"handle:ns-0:map[]",
+		"handle:ns-0:map[team:a]",
+		"delete:ns-0:map[team:a]",
+		"handle:ns-1:map[team:b]",
+	}
+	assert.Equal(exp, handled)
+}
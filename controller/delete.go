@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deletedObjectsCache stores the last known state of the deleted objects until these have been
+// handled. The cache is bounded, when full, the oldest deleted objects will be evicted.
+//
+// A nil deletedObjectsCache is valid and will not store anything.
+type deletedObjectsCache struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List // order has the keys in deletion order, used for the eviction.
+	objs  map[string]*list.Element
+}
+
+type deletedObject struct {
+	key string
+	obj runtime.Object
+}
+
+func newDeletedObjectsCache(max int) *deletedObjectsCache {
+	return &deletedObjectsCache{
+		max:   max,
+		order: list.New(),
+		objs:  map[string]*list.Element{},
+	}
+}
+
+// set stores the last known state of a deleted object, the object can be a tombstone.
+func (d *deletedObjectsCache) set(key string, obj interface{}) {
+	if d == nil {
+		return
+	}
+
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	robj, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.objs[key]; ok {
+		e.Value.(*deletedObject).obj = robj
+		d.order.MoveToBack(e)
+		return
+	}
+
+	for d.order.Len() >= d.max {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.objs, oldest.Value.(*deletedObject).key)
+	}
+	d.objs[key] = d.order.PushBack(&deletedObject{key: key, obj: robj})
+}
+
+// get returns the last known state of a deleted object.
+func (d *deletedObjectsCache) get(key string) (runtime.Object, bool) {
+	if d == nil {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.objs[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*deletedObject).obj, true
+}
+
+// evict removes a deleted object from the cache.
+func (d *deletedObjectsCache) evict(key string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.objs[key]; ok {
+		d.order.Remove(e)
+		delete(d.objs, key)
+	}
+}
+
+// processingCanceler knows how to cancel the processing context of the objects being processed.
+//
+// A nil processingCanceler is valid and will not cancel anything.
+type processingCanceler struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newProcessingCanceler() *processingCanceler {
+	return &processingCanceler{cancels: map[string]context.CancelFunc{}}
+}
+
+// track returns a context for the processing of the key that can be canceled, the returned
+// cancel func must be called when the processing finishes.
+func (p *processingCanceler) track(ctx context.Context, key string) (context.Context, context.CancelFunc) {
+	if p == nil {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p.mu.Lock()
+	p.cancels[key] = cancel
+	p.mu.Unlock()
+
+	return ctx, func() {
+		p.mu.Lock()
+		delete(p.cancels, key)
+		p.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel cancels the processing of the key if it is being processed.
+func (p *processingCanceler) cancel(key string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cancel, ok := p.cancels[key]; ok {
+		cancel()
+	}
+}
@@ -0,0 +1,340 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerDeleteHandler(t *testing.T) {
+	tests := map[string]struct {
+		deleteObject func(fw *watch.FakeWatcher, obj *corev1.Namespace)
+	}{
+		"A deleted object from a watch event should be handled by the delete handler with its last known state.": {
+			deleteObject: func(fw *watch.FakeWatcher, obj *corev1.Namespace) {
+				fw.Delete(obj)
+			},
+		},
+
+		"A deleted object from a relist (tombstone) should be handled by the delete handler with its last known state.": {
+			deleteObject: func(fw *watch.FakeWatcher, _ *corev1.Namespace) {
+				// Stopping the watch will make the controller relist, where the object is missing.
+				fw.Stop()
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, nss := createNamespaceList("testing", 2)
+			nsListAfterDelete, _ := createNamespaceList("testing", 1)
+			nsListAfterDelete.ResourceVersion = "10"
+
+			// First list will have all the objects, after that, the deleted object will be missing.
+			mc := &fake.Clientset{}
+			lists := 0
+			mc.AddReactor("list", "namespaces", func(kubetesting.Action) (bool, runtime.Object, error) {
+				lists++
+				if lists == 1 {
+					return true, nsList, nil
+				}
+				return true, nsListAfterDelete, nil
+			})
+			fw := watch.NewFake()
+			watches := 0
+			mc.AddWatchReactor("namespaces", func(kubetesting.Action) (bool, watch.Interface, error) {
+				watches++
+				if watches == 1 {
+					return true, fw, nil
+				}
+				return true, nil, fmt.Errorf("wanted error")
+			})
+
+			var mu sync.Mutex
+			handled := map[string]string{}
+			deleted := []*corev1.Namespace{}
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				ns := obj.(*corev1.Namespace)
+				handled[ns.Name] = ns.Labels["state"]
+				return nil
+			})
+			dh := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				deleted = append(deleted, obj.(*corev1.Namespace))
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:          "test",
+				Handler:       h,
+				DeleteHandler: dh,
+				Retriever:     newNamespaceRetriever(mc),
+				Logger:        log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Update the object that will be deleted so we know the last known state.
+			updated := nss[1].DeepCopy()
+			updated.ResourceVersion = "5"
+			updated.Labels = map[string]string{"state": "updated"}
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handled) == 2
+			}, 1*time.Second, 5*time.Millisecond)
+			fw.Modify(updated)
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return handled["testing-1"] == "updated"
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// Delete the object.
+			test.deleteObject(fw, updated)
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(deleted) == 1
+			}, 5*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal("testing-1", deleted[0].Name)
+			assert.Equal("updated", deleted[0].Labels["state"])
+		})
+	}
+}
+
+func TestGenericControllerCancelOnDelete(t *testing.T) {
+	tests := map[string]struct {
+		cancelOnDelete bool
+		expCanceled    bool
+	}{
+		"Deleting an object being handled should cancel the handling context if enabled.": {
+			cancelOnDelete: true,
+			expCanceled:    true,
+		},
+
+		"Deleting an object being handled should not cancel the handling context if not enabled.": {
+			cancelOnDelete: false,
+			expCanceled:    false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+
+			// The handler will wait until its context is canceled or times out.
+			startedC := make(chan struct{})
+			canceledC := make(chan bool)
+			h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+				close(startedC)
+				select {
+				case <-ctx.Done():
+					canceledC <- true
+				case <-time.After(300 * time.Millisecond):
+					canceledC <- false
+				}
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:           "test",
+				Handler:        h,
+				Retriever:      newNamespaceRetriever(mc),
+				CancelOnDelete: test.cancelOnDelete,
+				Logger:         log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Delete the object while its being handled.
+			<-startedC
+			err = mc.CoreV1().Namespaces().Delete(ctx, nss[0].Name, metav1.DeleteOptions{})
+			require.NoError(err)
+
+			assert.Equal(test.expCanceled, <-canceledC)
+		})
+	}
+}
+
+// testCtxObjectLocker is a lock that records if the unlock context was canceled.
+type testCtxObjectLocker struct {
+	unlockCanceledC chan bool
+}
+
+func (t testCtxObjectLocker) Lock(context.Context, string) (bool, error) { return true, nil }
+func (t testCtxObjectLocker) Unlock(ctx context.Context, _ string) error {
+	select {
+	case t.unlockCanceledC <- ctx.Err() != nil:
+	default:
+	}
+	return nil
+}
+
+func TestGenericControllerCancelOnDeleteReleasesObjectLock(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 1)
+	mc := fake.NewSimpleClientset(nss[0])
+
+	// The handler will wait until its context is canceled.
+	startedC := make(chan struct{})
+	h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+		close(startedC)
+		<-ctx.Done()
+		return nil
+	})
+
+	locker := testCtxObjectLocker{unlockCanceledC: make(chan bool, 1)}
+	c, err := controller.New(&controller.Config{
+		Name:           "test",
+		Handler:        h,
+		Retriever:      newNamespaceRetriever(mc),
+		CancelOnDelete: true,
+		ObjectLocker:   locker,
+		Logger:         log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Delete the object while its being handled, the lock should be released with a valid context.
+	<-startedC
+	err = mc.CoreV1().Namespaces().Delete(ctx, nss[0].Name, metav1.DeleteOptions{})
+	require.NoError(err)
+
+	select {
+	case canceled := <-locker.unlockCanceledC:
+		assert.False(canceled)
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for the object lock release")
+	}
+}
+
+func TestGenericControllerDeleteHandlerWithoutLastKnownState(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 3)
+	mc := fake.NewSimpleClientset(nss[0], nss[1], nss[2])
+
+	// The first handled object blocks the only worker.
+	var mu sync.Mutex
+	handled := []string{}
+	deleted := map[string]runtime.Object{}
+	blockedC := make(chan struct{})
+	releaseC := make(chan struct{})
+	var once sync.Once
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		once.Do(func() {
+			close(blockedC)
+			<-releaseC
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, obj.(*corev1.Namespace).Name)
+		return nil
+	})
+	dh := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		objMeta, err := meta.Accessor(obj)
+		require.NoError(err)
+		deleted[objMeta.GetName()] = obj
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                    "test",
+		Handler:                 h,
+		DeleteHandler:           dh,
+		DeletedObjectsCacheSize: 1,
+		ConcurrentWorkers:       1,
+		Retriever:               newNamespaceRetriever(mc),
+		Logger:                  log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Delete the queued objects while the worker is blocked, the cache only can store the last one.
+	<-blockedC
+	mu.Lock()
+	remaining := []string{}
+	for _, ns := range nss {
+		remaining = append(remaining, ns.Name)
+	}
+	mu.Unlock()
+	var blockedName string
+	require.Eventually(func() bool {
+		for _, name := range remaining {
+			if c.(controller.KeyStatuser).KeyStatus(name) == controller.KeyStatusProcessing {
+				blockedName = name
+				return true
+			}
+		}
+		return false
+	}, 1*time.Second, 5*time.Millisecond)
+	toDelete := []string{}
+	for _, name := range remaining {
+		if name != blockedName {
+			toDelete = append(toDelete, name)
+		}
+	}
+	for _, name := range toDelete {
+		require.NoError(mc.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{}))
+		require.Eventually(func() bool { return c.(controller.KeyStatuser).KeyStatus(name) == controller.KeyStatusQueued }, 1*time.Second, 5*time.Millisecond)
+	}
+	// Give time to the informer to receive the last deletion.
+	time.Sleep(50 * time.Millisecond)
+	close(releaseC)
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(deleted) == 2
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The deleted objects should not be handled by the regular handler, the first deleted object
+	// has been dropped from the cache, so only the key information is available.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{blockedName}, handled)
+	assert.IsType(&metav1.PartialObjectMetadata{}, deleted[toDelete[0]])
+	assert.IsType(&corev1.Namespace{}, deleted[toDelete[1]])
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// updateDiffFields are the fields of the objects compared by the update diffs.
+var updateDiffFields = []struct {
+	name string
+	path []string
+}{
+	{name: "metadata.labels", path: []string{"metadata", "labels"}},
+	{name: "metadata.annotations", path: []string{"metadata", "annotations"}},
+	{name: "spec", path: []string{"spec"}},
+}
+
+// updateDiff returns a shallow diff of the labels, the annotations and the spec of the updated object,
+// one change per direct field (e.g: `metadata.labels.app: "a" -> "b"`) sorted by field. The diff is
+// truncated when it's bigger than the max size. An empty diff means no changes on these fields.
+func updateDiff(old, new runtime.Object, maxSize int) (string, error) {
+	oldContent, err := semanticContent(old)
+	if err != nil {
+		return "", fmt.Errorf("could not get old object content: %w", err)
+	}
+	newContent, err := semanticContent(new)
+	if err != nil {
+		return "", fmt.Errorf("could not get new object content: %w", err)
+	}
+
+	changes := []string{}
+	for _, f := range updateDiffFields {
+		oldField := nestedMap(oldContent, f.path)
+		newField := nestedMap(newContent, f.path)
+
+		keys := map[string]struct{}{}
+		for k := range oldField {
+			keys[k] = struct{}{}
+		}
+		for k := range newField {
+			keys[k] = struct{}{}
+		}
+
+		fieldChanges := []string{}
+		for k := range keys {
+			oldV, oldOK := oldField[k]
+			newV, newOK := newField[k]
+			if oldOK == newOK && reflect.DeepEqual(oldV, newV) {
+				continue
+			}
+			fieldChanges = append(fieldChanges, fmt.Sprintf("%s.%s: %s -> %s", f.name, k, diffValue(oldV, oldOK), diffValue(newV, newOK)))
+		}
+		sort.Strings(fieldChanges)
+		changes = append(changes, fieldChanges...)
+	}
+
+	diff := strings.Join(changes, ", ")
+	if maxSize > 0 && len(diff) > maxSize {
+		diff = diff[:maxSize] + "...(truncated)"
+	}
+
+	return diff, nil
+}
+
+// nestedMap returns the map of the path on the unstructured content, nil if missing.
+func nestedMap(content map[string]interface{}, path []string) map[string]interface{} {
+	m := content
+	for _, p := range path {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	return m
+}
+
+// diffValue returns the JSON representation of a diff value, `<none>` if it's not present.
+func diffValue(v interface{}, ok bool) string {
+	if !ok {
+		return "<none>"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
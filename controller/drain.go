@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StopAndDrain satisfies Drainer interface.
+func (g *generic) StopAndDrain(ctx context.Context) ([]string, error) {
+	g.runningMu.Lock()
+	stop, stoppedC := g.stop, g.stoppedC
+	g.runningMu.Unlock()
+	if stop == nil {
+		return nil, fmt.Errorf("controller not running")
+	}
+
+	// Subscribe before getting the pending keys so the keys handled while stopping are not missed.
+	var mu sync.Mutex
+	succeeded := map[string]bool{}
+	unsubscribe := g.events.subscribe(func(ev Event) {
+		if ev.Type == EventSucceeded {
+			mu.Lock()
+			succeeded[ev.Key] = true
+			mu.Unlock()
+		}
+	})
+	defer unsubscribe()
+
+	pending := map[string]bool{}
+	for _, item := range g.tracking.Snapshot() {
+		pending[item.Key] = true
+	}
+
+	stop()
+	select {
+	case <-stoppedC:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for the controller to stop: %w", ctx.Err())
+	}
+
+	// The keys being handled while stopping are pending unless their handling succeeded, the keys
+	// that are still tracked (e.g: queued again) are pending too.
+	mu.Lock()
+	for key := range succeeded {
+		delete(pending, key)
+	}
+	mu.Unlock()
+	for _, item := range g.tracking.Snapshot() {
+		pending[item.Key] = true
+	}
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
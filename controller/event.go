@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is the type of a controller lifecycle event.
+type EventType string
+
+const (
+	// EventEnqueued is notified when an object key is queued from a watch event.
+	EventEnqueued EventType = "enqueued"
+	// EventResynced is notified when an object key is queued from a resync.
+	EventResynced EventType = "resynced"
+	// EventStarted is notified when the handling of an object starts.
+	EventStarted EventType = "started"
+	// EventSucceeded is notified when the handling of an object succeeds.
+	EventSucceeded EventType = "succeeded"
+	// EventFailed is notified when the handling of an object fails.
+	EventFailed EventType = "failed"
+	// EventRetried is notified when a failed object is queued again to be retried.
+	EventRetried EventType = "retried"
+	// EventForgotten is notified when a failed object will not be retried anymore.
+	EventForgotten EventType = "forgotten"
+)
+
+// Event is a controller lifecycle event of an object key.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+	// Key is the object key.
+	Key string
+	// Time is the time the event happened.
+	Time time.Time
+	// Err is the error of the failed events.
+	Err error
+}
+
+// eventNotifier knows how to notify the controller lifecycle events to the configured hook and
+// to the internal subscribers.
+//
+// A nil eventNotifier is valid and will not notify.
+type eventNotifier struct {
+	onEvent func(Event)
+
+	mu     sync.RWMutex
+	subs   map[int]func(Event)
+	nextID int
+}
+
+func newEventNotifier(onEvent func(Event)) *eventNotifier {
+	return &eventNotifier{
+		onEvent: onEvent,
+		subs:    map[int]func(Event){},
+	}
+}
+
+func (e *eventNotifier) notify(t EventType, key string, err error) {
+	if e == nil {
+		return
+	}
+
+	ev := Event{
+		Type: t,
+		Key:  key,
+		Time: time.Now(),
+		Err:  err,
+	}
+
+	if e.onEvent != nil {
+		e.onEvent(ev)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, sub := range e.subs {
+		sub(ev)
+	}
+}
+
+// subscribe will call the subscriber on every event until unsubscribed.
+func (e *eventNotifier) subscribe(sub func(Event)) (unsubscribe func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := e.nextID
+	e.nextID++
+	e.subs[id] = sub
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.subs, id)
+	}
+}
+
+// waitForKey blocks until the key has been handled successfully or the context is done.
+func (e *eventNotifier) waitForKey(ctx context.Context, key string) error {
+	doneC := make(chan struct{})
+	var once sync.Once
+	unsubscribe := e.subscribe(func(ev Event) {
+		if ev.Key == key && ev.Type == EventSucceeded {
+			once.Do(func() { close(doneC) })
+		}
+	})
+	defer unsubscribe()
+
+	select {
+	case <-doneC:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for %q key: %w", key, ctx.Err())
+	}
+}
@@ -0,0 +1,201 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerOnEvent(t *testing.T) {
+	tests := map[string]struct {
+		retries   int
+		failures  int
+		expEvents []controller.EventType
+	}{
+		"A succeeding object should notify the lifecycle events.": {
+			retries:  1,
+			failures: 0,
+			expEvents: []controller.EventType{
+				controller.EventEnqueued,
+				controller.EventStarted,
+				controller.EventSucceeded,
+			},
+		},
+
+		"A failing and then succeeding object should notify the lifecycle events.": {
+			retries:  3,
+			failures: 2,
+			expEvents: []controller.EventType{
+				controller.EventEnqueued,
+				controller.EventStarted,
+				controller.EventFailed,
+				controller.EventRetried,
+				controller.EventStarted,
+				controller.EventFailed,
+				controller.EventRetried,
+				controller.EventStarted,
+				controller.EventSucceeded,
+			},
+		},
+
+		"A failing object without retries should notify the lifecycle events.": {
+			retries:  0,
+			failures: 1,
+			expEvents: []controller.EventType{
+				controller.EventEnqueued,
+				controller.EventStarted,
+				controller.EventFailed,
+				controller.EventForgotten,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", 1)
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			var mu sync.Mutex
+			calls := 0
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if calls <= test.failures {
+					return fmt.Errorf("wanted error")
+				}
+				return nil
+			})
+
+			events := []controller.Event{}
+			onEvent := func(e controller.Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, e)
+			}
+
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            newNamespaceRetriever(mc),
+				ProcessingJobRetries: test.retries,
+				OnEvent:              onEvent,
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(events) >= len(test.expEvents)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			gotEvents := []controller.EventType{}
+			for _, e := range events {
+				assert.Equal("testing-0", e.Key)
+				assert.False(e.Time.IsZero())
+				if e.Type == controller.EventFailed {
+					assert.Error(e.Err)
+				}
+				gotEvents = append(gotEvents, e.Type)
+			}
+			assert.Equal(test.expEvents, gotEvents)
+		})
+	}
+}
+
+func TestGenericControllerWaitForKey(t *testing.T) {
+	tests := map[string]struct {
+		key      string
+		failures int
+		expErr   bool
+	}{
+		"Waiting for a key that is handled should return after the handling.": {
+			key: "testing-new",
+		},
+
+		"Waiting for a key that fails should return after the successful handling.": {
+			key:      "testing-new",
+			failures: 2,
+		},
+
+		"Waiting for a key that is not handled should fail when the context is done.": {
+			key:    "testing-missing",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset()
+
+			var mu sync.Mutex
+			calls, succeeded := 0, 0
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if calls <= test.failures {
+					return fmt.Errorf("wanted error")
+				}
+				succeeded++
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            newNamespaceRetriever(mc),
+				ProcessingJobRetries: test.failures,
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Create the object after we start waiting.
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				_, _ = mc.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "testing-new"}}, metav1.CreateOptions{})
+			}()
+
+			waitCtx, waitCancel := context.WithTimeout(ctx, 1*time.Second)
+			defer waitCancel()
+			err = c.(controller.KeyWaiter).WaitForKey(waitCtx, test.key)
+
+			if test.expErr {
+				assert.ErrorIs(err, context.DeadlineExceeded)
+			} else if assert.NoError(err) {
+				mu.Lock()
+				defer mu.Unlock()
+				assert.Equal(test.failures+1, calls)
+				assert.Equal(1, succeeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package eventrecorder has a handler that records the result of the handling as Kubernetes
+// events of the handled objects, so the handling errors are visible with `kubectl describe`.
+package eventrecorder
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+const (
+	// ReasonReconcileError is the reason of the warning events recorded on handling errors.
+	ReasonReconcileError = "ReconcileError"
+	// ReasonReconciled is the reason of the normal events recorded on handling successes.
+	ReasonReconciled = "Reconciled"
+)
+
+// Config is the configuration of the event recorder handler.
+type Config struct {
+	// Recorder is the Kubernetes event recorder that will record the events.
+	Recorder record.EventRecorder
+	// Handler is the wrapped handler.
+	Handler controller.Handler
+	// RecordSuccess will record a normal event when the handler succeeds, by default only the
+	// errors are recorded.
+	RecordSuccess bool
+}
+
+func (c *Config) validate() error {
+	if c.Recorder == nil {
+		return fmt.Errorf("event recorder is required")
+	}
+
+	if c.Handler == nil {
+		return fmt.Errorf("handler is required")
+	}
+
+	return nil
+}
+
+// NewHandler returns a handler that wraps a handler and records a warning event on the handled
+// object when the handler returns an error, and optionally a normal event when it succeeds.
+func NewHandler(cfg Config) (controller.Handler, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return controller.HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		err := cfg.Handler.Handle(ctx, obj)
+		if err != nil {
+			cfg.Recorder.Event(obj, corev1.EventTypeWarning, ReasonReconcileError, err.Error())
+			return err
+		}
+
+		if cfg.RecordSuccess {
+			cfg.Recorder.Event(obj, corev1.EventTypeNormal, ReasonReconciled, "Object reconciled successfully")
+		}
+
+		return nil
+	}), nil
+}
@@ -0,0 +1,91 @@
+package eventrecorder_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/eventrecorder"
+)
+
+func TestHandler(t *testing.T) {
+	tests := map[string]struct {
+		recordSuccess bool
+		handlerErr    error
+		expErr        bool
+		expEvents     []string
+	}{
+		"A successful handling should not record events by default.": {
+			expEvents: []string{},
+		},
+
+		"A successful handling should record a normal event if enabled.": {
+			recordSuccess: true,
+			expEvents:     []string{"Normal Reconciled Object reconciled successfully"},
+		},
+
+		"A failed handling should record a warning event with the error.": {
+			handlerErr: fmt.Errorf("something wrong"),
+			expErr:     true,
+			expEvents:  []string{"Warning ReconcileError something wrong"},
+		},
+
+		"A failed handling should record only a warning event if success recording is enabled.": {
+			recordSuccess: true,
+			handlerErr:    fmt.Errorf("something wrong"),
+			expErr:        true,
+			expEvents:     []string{"Warning ReconcileError something wrong"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			recorder := record.NewFakeRecorder(10)
+			h, err := eventrecorder.NewHandler(eventrecorder.Config{
+				Recorder:      recorder,
+				RecordSuccess: test.recordSuccess,
+				Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+					return test.handlerErr
+				}),
+			})
+			require.NoError(err)
+
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
+			err = h.Handle(context.TODO(), pod)
+
+			if test.expErr {
+				assert.ErrorIs(err, test.handlerErr)
+			} else {
+				assert.NoError(err)
+			}
+
+			close(recorder.Events)
+			gotEvents := []string{}
+			for ev := range recorder.Events {
+				gotEvents = append(gotEvents, ev)
+			}
+			assert.Equal(test.expEvents, gotEvents)
+		})
+	}
+}
+
+func TestHandlerConfigValidation(t *testing.T) {
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error { return nil })
+
+	_, err := eventrecorder.NewHandler(eventrecorder.Config{Handler: h})
+	assert.Error(t, err)
+
+	_, err = eventrecorder.NewHandler(eventrecorder.Config{Recorder: record.NewFakeRecorder(1)})
+	assert.Error(t, err)
+}
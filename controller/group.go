@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Group is a group of controllers that will run together. This is a lightweight helper
+// to run multiple controllers on the same app, not a manager.
+type Group struct {
+	controllers []Controller
+}
+
+// NewGroup returns a new controller group.
+func NewGroup(controllers ...Controller) *Group {
+	return &Group{controllers: controllers}
+}
+
+// Run will run all the controllers of the group concurrently and block until all of them have
+// finished. If any of the controllers ends with an error, the rest of them will be stopped.
+//
+// The returned error will aggregate all the errors returned by the controllers.
+func (g *Group) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errC := make(chan error, len(g.controllers))
+	for _, c := range g.controllers {
+		c := c
+		go func() {
+			errC <- c.Run(ctx)
+		}()
+	}
+
+	errs := []error{}
+	for range g.controllers {
+		err := <-errC
+		if err != nil {
+			errs = append(errs, err)
+			// Stop the rest of controllers.
+			cancel()
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
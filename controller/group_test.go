@@ -0,0 +1,101 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// testLeaderElector is a leader election runner that can fail.
+type testLeaderElector struct {
+	err error
+}
+
+func (t testLeaderElector) Run(f func(context.Context) error) error {
+	if t.err != nil {
+		return t.err
+	}
+	return f(context.Background())
+}
+
+func TestGroup(t *testing.T) {
+	errWanted := fmt.Errorf("wanted error")
+
+	tests := map[string]struct {
+		cancelCtx bool
+		errs      []error
+		expErr    error
+	}{
+		"Stopping the group should stop all the controllers without error.": {
+			cancelCtx: true,
+			errs:      []error{nil, nil},
+		},
+
+		"A failing controller should stop all the controllers and return the error.": {
+			errs:   []error{nil, errWanted},
+			expErr: errWanted,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			var handled int32
+			h := controller.HandlerFunc(func(context.Context, runtime.Object) error {
+				atomic.AddInt32(&handled, 1)
+				return nil
+			})
+
+			ctrls := []controller.Controller{}
+			for i, err := range test.errs {
+				_, nss := createNamespaceList("testing", 1)
+				mc := fake.NewSimpleClientset(nss[0])
+				c, err := controller.New(&controller.Config{
+					Name:          fmt.Sprintf("test-%d", i),
+					Handler:       h,
+					Retriever:     newNamespaceRetriever(mc),
+					LeaderElector: testLeaderElector{err: err},
+					Logger:        log.Dummy,
+				})
+				require.NoError(err)
+				ctrls = append(ctrls, c)
+			}
+
+			resultC := make(chan error)
+			go func() { resultC <- controller.NewGroup(ctrls...).Run(ctx) }()
+
+			// Wait until all the controllers are running before stopping them.
+			if test.cancelCtx {
+				require.Eventually(func() bool {
+					return atomic.LoadInt32(&handled) == int32(len(ctrls))
+				}, 1*time.Second, 5*time.Millisecond)
+				cancelCtx()
+			}
+
+			select {
+			case err := <-resultC:
+				if test.expErr != nil {
+					assert.ErrorIs(err, test.expErr)
+				} else {
+					assert.NoError(err)
+				}
+			case <-time.After(1 * time.Second):
+				assert.Fail("timeout waiting for the group to stop")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type gvkCtxKey struct{}
+
+// GVKFromContext returns the GroupVersionKind of the object being handled. If the kind of
+// the object can't be resolved it will return an empty GroupVersionKind.
+func GVKFromContext(ctx context.Context) schema.GroupVersionKind {
+	gvk, _ := ctx.Value(gvkCtxKey{}).(schema.GroupVersionKind)
+	return gvk
+}
+
+// contextWithGVK returns a context with the GroupVersionKind of the object. The type information
+// of the object is used if present (e.g: unstructured objects), otherwise it will be resolved
+// using the scheme (typed objects usually lack the type information).
+func contextWithGVK(ctx context.Context, scheme *runtime.Scheme, obj runtime.Object) context.Context {
+	return context.WithValue(ctx, gvkCtxKey{}, objectGVK(scheme, obj))
+}
+
+// objectGVK returns the GroupVersionKind of the object, from its type information or resolved
+// using the scheme. If it can't be resolved it will return an empty GroupVersionKind.
+func objectGVK(scheme *runtime.Scheme, obj runtime.Object) schema.GroupVersionKind {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := scheme.ObjectKinds(obj)
+		if err == nil && len(gvks) > 0 {
+			gvk = gvks[0]
+		}
+	}
+
+	return gvk
+}
@@ -0,0 +1,89 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerGVKFromContext(t *testing.T) {
+	tests := map[string]struct {
+		retriever func() controller.Retriever
+		expGVK    schema.GroupVersionKind
+	}{
+		"Typed objects without type information should have the GVK resolved from the scheme.": {
+			retriever: func() controller.Retriever {
+				_, nss := createNamespaceList("testing", 1)
+				return newNamespaceRetriever(fake.NewSimpleClientset(nss[0]))
+			},
+			expGVK: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+		},
+
+		"Unstructured objects should have the GVK from their type information.": {
+			retriever: func() controller.Retriever {
+				obj := &unstructured.Unstructured{}
+				obj.SetAPIVersion("example.com/v1alpha1")
+				obj.SetKind("Example")
+				obj.SetName("testing-0")
+				return controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+					ListFunc: func(_ metav1.ListOptions) (runtime.Object, error) {
+						l := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*obj}}
+						l.SetResourceVersion("1")
+						return l, nil
+					},
+					WatchFunc: func(_ metav1.ListOptions) (watch.Interface, error) {
+						return watch.NewFake(), nil
+					},
+				})
+			},
+			expGVK: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Example"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			gvkC := make(chan schema.GroupVersionKind, 1)
+			h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+				select {
+				case gvkC <- controller.GVKFromContext(ctx):
+				default:
+				}
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:      "test",
+				Handler:   h,
+				Retriever: test.retriever(),
+				Logger:    log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			select {
+			case gvk := <-gvkC:
+				assert.Equal(test.expGVK, gvk)
+			case <-time.After(1 * time.Second):
+				require.FailNow("timeout waiting for the object handling")
+			}
+		})
+	}
+}
@@ -2,9 +2,18 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/spotahome/kooper/v2/log"
 )
 
 // Handler knows how to handle the received resources from a kubernetes cluster.
@@ -24,3 +33,330 @@ func (h HandlerFunc) Handle(ctx context.Context, obj runtime.Object) error {
 	}
 	return h(ctx, obj)
 }
+
+// Result is the result of handling an object, it controls how the object will be
+// processed after being handled.
+type Result struct {
+	// RequeueAfter will queue the object again after the duration. If 0 the object
+	// will not be queued again.
+	RequeueAfter time.Duration
+	// Outcome is an optional label of what the handling did (e.g: created, updated, noop...), it will
+	// be recorded on the metrics if the metrics recorder implements `OutcomeMetricsRecorder`.
+	Outcome string
+	// EnqueueKeys are the keys of other objects that will be queued (e.g: related objects) after
+	// the object has been handled successfully.
+	EnqueueKeys []string
+	// StopResync will exclude the object from the next resyncs (e.g: it reached a terminal state), the
+	// watch events of the object are still handled and enable the resyncs again.
+	StopResync bool
+}
+
+// ResultHandler is like a Handler but it returns a Result to control how the object
+// will be processed after being handled.
+type ResultHandler interface {
+	HandleWithResult(context.Context, runtime.Object) (Result, error)
+}
+
+// ResultHandlerFunc knows how to handle resources returning a result.
+type ResultHandlerFunc func(context.Context, runtime.Object) (Result, error)
+
+// HandleWithResult satisfies controller.ResultHandler interface.
+func (h ResultHandlerFunc) HandleWithResult(ctx context.Context, obj runtime.Object) (Result, error) {
+	if h == nil {
+		return Result{}, fmt.Errorf("handle func is required")
+	}
+	return h(ctx, obj)
+}
+
+// resultHandlerFromHandler converts a Handler into a ResultHandler that returns empty results.
+func resultHandlerFromHandler(h Handler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		return Result{}, h.Handle(ctx, obj)
+	})
+}
+
+// newDeepCopyResultHandler returns a ResultHandler that handles a deep copy of the objects, so
+// the handler can mutate the objects safely.
+func newDeepCopyResultHandler(h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		return h.HandleWithResult(ctx, obj.DeepCopyObject())
+	})
+}
+
+// newProfilingLabelsResultHandler returns a ResultHandler that sets the pprof labels of the controller
+// and the resource kind while handling.
+func newProfilingLabelsResultHandler(name string, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (res Result, err error) {
+		labels := pprof.Labels("controller", name, "resource", GVKFromContext(ctx).GroupKind().String())
+		pprof.Do(ctx, labels, func(ctx context.Context) {
+			res, err = h.HandleWithResult(ctx, obj)
+		})
+		return res, err
+	})
+}
+
+// newOutcomeMetricsResultHandler returns a ResultHandler that measures the outcomes of the results.
+func newOutcomeMetricsResultHandler(name string, mrec OutcomeMetricsRecorder, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		res, err := h.HandleWithResult(ctx, obj)
+		if res.Outcome != "" {
+			mrec.IncResourceProcessingOutcome(ctx, name, res.Outcome)
+		}
+		return res, err
+	})
+}
+
+// newAgeMetricsResultHandler returns a ResultHandler that measures the handling durations by the age
+// of the objects, the objects without creation timestamp are not measured.
+func newAgeMetricsResultHandler(name string, mrec AgeMetricsRecorder, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return h.HandleWithResult(ctx, obj)
+		}
+		created := objMeta.GetCreationTimestamp()
+		if created.IsZero() {
+			return h.HandleWithResult(ctx, obj)
+		}
+
+		start := time.Now()
+		age := start.Sub(created.Time)
+		res, err := h.HandleWithResult(ctx, obj)
+		mrec.ObserveResourceProcessingDurationByAge(ctx, name, age, start)
+
+		return res, err
+	})
+}
+
+// ProcessingTimeoutAnnotation is the annotation that can be set on the objects to set the
+// handling timeout of an object (e.g: `30s`), overriding the controller `ProcessingTimeout`.
+const ProcessingTimeoutAnnotation = "kooper.io/reconcile-timeout"
+
+// newTimeoutResultHandler returns a ResultHandler that sets a deadline on the handling context, the
+// timeout of the `ProcessingTimeoutAnnotation` annotation overrides the default one, limited by the
+// max timeout. A 0 timeout means no timeout.
+func newTimeoutResultHandler(timeout, maxTimeout time.Duration, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		timeout := timeout
+		if objMeta, err := meta.Accessor(obj); err == nil {
+			if d, err := time.ParseDuration(objMeta.GetAnnotations()[ProcessingTimeoutAnnotation]); err == nil && d > 0 {
+				timeout = d
+			}
+		}
+
+		if maxTimeout > 0 && (timeout == 0 || timeout > maxTimeout) {
+			timeout = maxTimeout
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		return h.HandleWithResult(ctx, obj)
+	})
+}
+
+// newRequeueOnSuccessResultHandler returns a ResultHandler that requeues the objects handled successfully
+// after the duration, when the handler didn't request a requeue.
+func newRequeueOnSuccessResultHandler(requeueAfter time.Duration, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		res, err := h.HandleWithResult(ctx, obj)
+		if err == nil && res.RequeueAfter <= 0 {
+			res.RequeueAfter = requeueAfter
+		}
+		return res, err
+	})
+}
+
+// newRequeueBoundsResultHandler returns a ResultHandler that clamps the requeue durations requested by
+// the handler between the min and max durations, logging the clamped ones. A 0 bound means no bound.
+func newRequeueBoundsResultHandler(min, max time.Duration, logger log.Logger, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		res, err := h.HandleWithResult(ctx, obj)
+		if err != nil || res.RequeueAfter <= 0 {
+			return res, err
+		}
+
+		requeueAfter := res.RequeueAfter
+		switch {
+		case min > 0 && requeueAfter < min:
+			requeueAfter = min
+		case max > 0 && requeueAfter > max:
+			requeueAfter = max
+		default:
+			return res, nil
+		}
+
+		key, _ := cache.MetaNamespaceKeyFunc(obj)
+		logger.WithKV(log.KV{"object-key": key, "request-id": RequestIDFromContext(ctx)}).
+			Warningf("requeue after %s out of bounds, clamped to %s", res.RequeueAfter, requeueAfter)
+		res.RequeueAfter = requeueAfter
+
+		return res, nil
+	})
+}
+
+// ErrSkipReconcile can be returned by the `BeforeReconcile` hook to skip the handling of an object
+// successfully.
+var ErrSkipReconcile = fmt.Errorf("reconcile skipped")
+
+// newHooksResultHandler returns a ResultHandler that calls the before and after hooks around the
+// handler. The hooks are optional.
+func newHooksResultHandler(before func(ctx context.Context, key string) (context.Context, error), after func(ctx context.Context, key string, err error), h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		key, _ := cache.MetaNamespaceKeyFunc(obj)
+
+		if before != nil {
+			hctx, err := before(ctx, key)
+			if errors.Is(err, ErrSkipReconcile) {
+				return Result{}, nil
+			}
+			if err != nil {
+				return Result{}, fmt.Errorf("before reconcile hook failed: %w", err)
+			}
+			if hctx != nil {
+				ctx = hctx
+			}
+		}
+
+		res, err := h.HandleWithResult(ctx, obj)
+		if after != nil {
+			after(ctx, key, err)
+		}
+
+		return res, err
+	})
+}
+
+// ReconcileEnabledAnnotation is the default annotation that can be set to `false` on the objects to
+// skip their handling (e.g: to roll out the controller gradually), see `Config.ReconcileEnabledAnnotation`.
+const ReconcileEnabledAnnotation = "kooper.io/reconcile-enabled"
+
+// disabledReconciles has the keys of the objects whose handling has been skipped by the reconcile enabled
+// annotation, so the skips are logged once. The deleted objects must be forgotten.
+type disabledReconciles struct {
+	mu     sync.Mutex
+	logged map[string]bool
+}
+
+func newDisabledReconciles() *disabledReconciles {
+	return &disabledReconciles{logged: map[string]bool{}}
+}
+
+// skip marks the handling of the key as skipped or not, returning true if it's the first skip.
+func (d *disabledReconciles) skip(key string, disabled bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	first := disabled && !d.logged[key]
+	if disabled {
+		d.logged[key] = true
+	} else {
+		delete(d.logged, key)
+	}
+
+	return first
+}
+
+// forget forgets the skips of a deleted object key.
+func (d *disabledReconciles) forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.logged, key)
+}
+
+// newReconcileEnabledResultHandler returns a ResultHandler that skips successfully the handling of the
+// objects that have the annotation set to false. The skip is logged once until the object is enabled again
+// or deleted.
+func newReconcileEnabledResultHandler(annotation string, disabled *disabledReconciles, logger log.Logger, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		isDisabled := false
+		if objMeta, err := meta.Accessor(obj); err == nil {
+			enabled, err := strconv.ParseBool(objMeta.GetAnnotations()[annotation])
+			isDisabled = err == nil && !enabled
+		}
+
+		key, _ := cache.MetaNamespaceKeyFunc(obj)
+		logSkip := disabled.skip(key, isDisabled)
+
+		if !isDisabled {
+			return h.HandleWithResult(ctx, obj)
+		}
+
+		if logSkip {
+			logger.WithKV(log.KV{"object-key": key}).Infof("reconcile disabled by %q annotation, skipping", annotation)
+		}
+
+		return Result{}, nil
+	})
+}
+
+type workerCtxKey struct{}
+
+// contextWithWorker returns a context with the ID of the worker that is processing.
+func contextWithWorker(ctx context.Context, worker int) context.Context {
+	return context.WithValue(ctx, workerCtxKey{}, worker)
+}
+
+// workerHandlers is a ResultHandler that delegates the handling on a different handler
+// instance per worker.
+type workerHandlers []ResultHandler
+
+func newWorkerHandlers(workers int, factory func() Handler) workerHandlers {
+	hs := make(workerHandlers, 0, workers)
+	for i := 0; i < workers; i++ {
+		hs = append(hs, resultHandlerFromHandler(factory()))
+	}
+	return hs
+}
+
+func (w workerHandlers) HandleWithResult(ctx context.Context, obj runtime.Object) (Result, error) {
+	worker, ok := ctx.Value(workerCtxKey{}).(int)
+	if !ok || worker < 0 || worker >= len(w) {
+		return Result{}, fmt.Errorf("unknown worker handler")
+	}
+	return w[worker].HandleWithResult(ctx, obj)
+}
+
+// RoutingHandler is a Handler that dispatches the objects to different handlers based on the
+// route of the object key (e.g: by namespace, using `RouteByNamespace`).
+type RoutingHandler struct {
+	// Route returns the route of an object key.
+	Route func(key string) string
+	// Handlers are the handlers of each route.
+	Handlers map[string]Handler
+	// Default is the handler of the objects whose route doesn't have a handler, if not set these
+	// objects will be ignored.
+	Default Handler
+}
+
+// Handle satisfies controller.Handler interface.
+func (r RoutingHandler) Handle(ctx context.Context, obj runtime.Object) error {
+	if r.Route == nil {
+		return fmt.Errorf("route func is required")
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Errorf("could not get object key: %w", err)
+	}
+
+	h, ok := r.Handlers[r.Route(key)]
+	if !ok {
+		h = r.Default
+	}
+
+	if h == nil {
+		return nil
+	}
+
+	return h.Handle(ctx, obj)
+}
+
+// RouteByNamespace is a RoutingHandler route that routes the objects by their namespace.
+func RouteByNamespace(key string) string {
+	ns, _, _ := SplitKey(key)
+	return ns
+}
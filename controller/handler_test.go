@@ -0,0 +1,745 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestRoutingHandler(t *testing.T) {
+	newPod := func(ns, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+
+	tests := map[string]struct {
+		withDefault bool
+		objs        []runtime.Object
+		expHandled  map[string][]string
+	}{
+		"Objects should be handled by the handler of their route.": {
+			objs: []runtime.Object{
+				newPod("tenant-a", "pod-0"),
+				newPod("tenant-b", "pod-1"),
+				newPod("tenant-a", "pod-2"),
+			},
+			expHandled: map[string][]string{
+				"tenant-a": {"tenant-a/pod-0", "tenant-a/pod-2"},
+				"tenant-b": {"tenant-b/pod-1"},
+			},
+		},
+
+		"Objects without a route handler should be ignored if there is no default handler.": {
+			objs: []runtime.Object{
+				newPod("tenant-a", "pod-0"),
+				newPod("tenant-c", "pod-1"),
+			},
+			expHandled: map[string][]string{
+				"tenant-a": {"tenant-a/pod-0"},
+			},
+		},
+
+		"Objects without a route handler should be handled by the default handler.": {
+			withDefault: true,
+			objs: []runtime.Object{
+				newPod("tenant-a", "pod-0"),
+				newPod("tenant-c", "pod-1"),
+				newPod("tenant-d", "pod-2"),
+			},
+			expHandled: map[string][]string{
+				"tenant-a": {"tenant-a/pod-0"},
+				"default":  {"tenant-c/pod-1", "tenant-d/pod-2"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			gotHandled := map[string][]string{}
+			newHandler := func(route string) controller.Handler {
+				return controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+					pod := obj.(*corev1.Pod)
+					gotHandled[route] = append(gotHandled[route], pod.Namespace+"/"+pod.Name)
+					return nil
+				})
+			}
+
+			h := controller.RoutingHandler{
+				Route: controller.RouteByNamespace,
+				Handlers: map[string]controller.Handler{
+					"tenant-a": newHandler("tenant-a"),
+					"tenant-b": newHandler("tenant-b"),
+				},
+			}
+			if test.withDefault {
+				h.Default = newHandler("default")
+			}
+
+			for _, obj := range test.objs {
+				err := h.Handle(context.TODO(), obj)
+				require.NoError(err)
+			}
+
+			assert.Equal(test.expHandled, gotHandled)
+		})
+	}
+}
+
+func TestGenericControllerProcessingTimeout(t *testing.T) {
+	newNS := func(timeoutAnnotation string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+		if timeoutAnnotation != "" {
+			ns.Annotations = map[string]string{controller.ProcessingTimeoutAnnotation: timeoutAnnotation}
+		}
+		return ns
+	}
+
+	tests := map[string]struct {
+		ns                   *corev1.Namespace
+		processingTimeout    time.Duration
+		maxProcessingTimeout time.Duration
+		expTimeout           time.Duration
+	}{
+		"Without timeouts the handling should not have a deadline.": {
+			ns: newNS(""),
+		},
+
+		"The processing timeout should set the handling deadline.": {
+			ns:                newNS(""),
+			processingTimeout: 10 * time.Second,
+			expTimeout:        10 * time.Second,
+		},
+
+		"The object annotation should override the processing timeout.": {
+			ns:                newNS("30s"),
+			processingTimeout: 10 * time.Second,
+			expTimeout:        30 * time.Second,
+		},
+
+		"The object annotation should set the timeout without a processing timeout.": {
+			ns:         newNS("30s"),
+			expTimeout: 30 * time.Second,
+		},
+
+		"An invalid object annotation should be ignored.": {
+			ns:                newNS("wrong"),
+			processingTimeout: 10 * time.Second,
+			expTimeout:        10 * time.Second,
+		},
+
+		"The object annotation should be limited by the max processing timeout.": {
+			ns:                   newNS("1h"),
+			processingTimeout:    10 * time.Second,
+			maxProcessingTimeout: 1 * time.Minute,
+			expTimeout:           1 * time.Minute,
+		},
+
+		"The max processing timeout should limit the handling without timeouts.": {
+			ns:                   newNS(""),
+			maxProcessingTimeout: 1 * time.Minute,
+			expTimeout:           1 * time.Minute,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(test.ns)
+
+			type deadline struct {
+				at time.Time
+				ok bool
+			}
+			deadlineC := make(chan deadline, 1)
+			h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+				at, ok := ctx.Deadline()
+				select {
+				case deadlineC <- deadline{at: at, ok: ok}:
+				default:
+				}
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            newNamespaceRetriever(mc),
+				ProcessingTimeout:    test.processingTimeout,
+				MaxProcessingTimeout: test.maxProcessingTimeout,
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			start := time.Now()
+			go func() { _ = c.Run(ctx) }()
+
+			var got deadline
+			select {
+			case got = <-deadlineC:
+			case <-time.After(1 * time.Second):
+				require.FailNow("timeout waiting for the object handling")
+			}
+
+			if test.expTimeout == 0 {
+				assert.False(got.ok)
+				return
+			}
+			require.True(got.ok)
+			assert.WithinDuration(start.Add(test.expTimeout), got.at, 500*time.Millisecond)
+		})
+	}
+}
+
+func TestGenericControllerResultEnqueueKeys(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nss := []runtime.Object{}
+	for _, name := range []string{"ns-0", "ns-1", "ns-2"} {
+		nss = append(nss, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	mc := fake.NewSimpleClientset(nss...)
+
+	var mu sync.Mutex
+	handled := []string{}
+	h := controller.ResultHandlerFunc(func(_ context.Context, obj runtime.Object) (controller.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ns := obj.(*corev1.Namespace)
+		handled = append(handled, ns.Name)
+
+		// The triggered object will queue the related objects.
+		if ns.Labels["trigger"] == "true" {
+			return controller.Result{EnqueueKeys: []string{"ns-1", "ns-2"}}, nil
+		}
+		return controller.Result{}, nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		ResultHandler:     h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: 1,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	waitHandled := func(n int) {
+		require.Eventually(func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(handled) == n
+		}, 1*time.Second, 5*time.Millisecond)
+	}
+	waitHandled(3)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-0", Labels: map[string]string{"trigger": "true"}}}
+	_, err = mc.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	require.NoError(err)
+	waitHandled(6)
+
+	// The related objects should be handled after the object that queued them.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal("ns-0", handled[3])
+	assert.ElementsMatch([]string{"ns-1", "ns-2"}, handled[4:])
+}
+
+func TestGenericControllerRequeueAfterBounds(t *testing.T) {
+	tests := map[string]struct {
+		requeueAfter    time.Duration
+		minRequeueAfter time.Duration
+		maxRequeueAfter time.Duration
+		expRequeueAfter time.Duration
+		expClamped      bool
+	}{
+		"Without bounds the requested requeue should be used.": {
+			requeueAfter:    100 * time.Millisecond,
+			expRequeueAfter: 100 * time.Millisecond,
+		},
+
+		"A requeue within the bounds should be used.": {
+			requeueAfter:    100 * time.Millisecond,
+			minRequeueAfter: 50 * time.Millisecond,
+			maxRequeueAfter: 1 * time.Second,
+			expRequeueAfter: 100 * time.Millisecond,
+		},
+
+		"A requeue lower than the min should be raised to the min.": {
+			requeueAfter:    1 * time.Millisecond,
+			minRequeueAfter: 200 * time.Millisecond,
+			expRequeueAfter: 200 * time.Millisecond,
+			expClamped:      true,
+		},
+
+		"A requeue higher than the max should be lowered to the max.": {
+			requeueAfter:    1 * time.Hour,
+			maxRequeueAfter: 100 * time.Millisecond,
+			expRequeueAfter: 100 * time.Millisecond,
+			expClamped:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+			// Only requeue on the first handling.
+			var mu sync.Mutex
+			handledAt := []time.Time{}
+			requeueAfter := test.requeueAfter
+			h := controller.ResultHandlerFunc(func(_ context.Context, _ runtime.Object) (controller.Result, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				handledAt = append(handledAt, time.Now())
+				if len(handledAt) > 1 {
+					return controller.Result{}, nil
+				}
+				return controller.Result{RequeueAfter: requeueAfter}, nil
+			})
+
+			logger := newTestLogger()
+			c, err := controller.New(&controller.Config{
+				Name:            "test",
+				ResultHandler:   h,
+				Retriever:       newNamespaceRetriever(mc),
+				MinRequeueAfter: test.minRequeueAfter,
+				MaxRequeueAfter: test.maxRequeueAfter,
+				Logger:          logger,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handledAt) == 2
+			}, 2*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			gotRequeueAfter := handledAt[1].Sub(handledAt[0])
+			assert.GreaterOrEqual(gotRequeueAfter, test.expRequeueAfter)
+			assert.Less(gotRequeueAfter, test.expRequeueAfter+500*time.Millisecond)
+			assert.Equal(test.expClamped, len(logger.Lines("out of bounds, clamped to "+test.expRequeueAfter.String())) == 1)
+		})
+	}
+}
+
+func TestGenericControllerReconcileHooks(t *testing.T) {
+	type ctxKey struct{}
+	errWanted := fmt.Errorf("wanted error")
+
+	tests := map[string]struct {
+		beforeErr  error
+		handlerErr error
+		expCalls   []string
+	}{
+		"The hooks should be called around the handler with the enriched context.": {
+			expCalls: []string{"before test", "handle enriched", "after test enriched <nil>"},
+		},
+
+		"A handler error should be received by the after hook and retried.": {
+			handlerErr: errWanted,
+			expCalls: []string{
+				"before test", "handle enriched", "after test enriched wanted error",
+				"before test", "handle enriched", "after test enriched wanted error",
+			},
+		},
+
+		"A before hook error should skip the handler and be retried.": {
+			beforeErr: errWanted,
+			expCalls:  []string{"before test", "before test"},
+		},
+
+		"A before hook skip should skip the handler without retrying.": {
+			beforeErr: controller.ErrSkipReconcile,
+			expCalls:  []string{"before test"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+			var mu sync.Mutex
+			calls := []string{}
+			addCall := func(format string, args ...interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, fmt.Sprintf(format, args...))
+			}
+
+			beforeErr, handlerErr := test.beforeErr, test.handlerErr
+			c, err := controller.New(&controller.Config{
+				Name: "test",
+				Handler: controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+					addCall("handle %v", ctx.Value(ctxKey{}))
+					return handlerErr
+				}),
+				BeforeReconcile: func(ctx context.Context, key string) (context.Context, error) {
+					addCall("before %s", key)
+					return context.WithValue(ctx, ctxKey{}, "enriched"), beforeErr
+				},
+				AfterReconcile: func(ctx context.Context, key string, err error) {
+					addCall("after %s %v %v", key, ctx.Value(ctxKey{}), err)
+				},
+				Retriever:            newNamespaceRetriever(mc),
+				ProcessingJobRetries: 1,
+				RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Millisecond, 1),
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			expCalls := test.expCalls
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return reflect.DeepEqual(expCalls, calls)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// No more calls should be made.
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(expCalls, calls)
+		})
+	}
+}
+
+func TestGenericControllerRequeueAfterOnSuccess(t *testing.T) {
+	tests := map[string]struct {
+		handlerRequeueAfter time.Duration
+		expInterval         time.Duration
+	}{
+		"A successful handling without requeue should be requeued after the configured interval.": {
+			expInterval: 50 * time.Millisecond,
+		},
+
+		"A successful handling with requeue should use the handler requeue.": {
+			handlerRequeueAfter: 100 * time.Millisecond,
+			expInterval:         100 * time.Millisecond,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+			var mu sync.Mutex
+			handledAt := []time.Time{}
+			requeueAfter := test.handlerRequeueAfter
+			h := controller.ResultHandlerFunc(func(context.Context, runtime.Object) (controller.Result, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				handledAt = append(handledAt, time.Now())
+				return controller.Result{RequeueAfter: requeueAfter}, nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                  "test",
+				ResultHandler:         h,
+				Retriever:             newNamespaceRetriever(mc),
+				RequeueAfterOnSuccess: 50 * time.Millisecond,
+				Logger:                log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handledAt) >= 4
+			}, 2*time.Second, 5*time.Millisecond)
+
+			// The object should be handled periodically at the interval.
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 1; i < 4; i++ {
+				interval := handledAt[i].Sub(handledAt[i-1])
+				assert.GreaterOrEqual(interval, test.expInterval)
+				assert.Less(interval, test.expInterval+50*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestGenericControllerProfilingLabels(t *testing.T) {
+	tests := map[string]struct {
+		profilingLabels bool
+		expLabels       map[string]string
+	}{
+		"Without profiling labels the handling should not have labels.": {
+			expLabels: map[string]string{},
+		},
+
+		"With profiling labels the handling should have the controller and resource labels.": {
+			profilingLabels: true,
+			expLabels:       map[string]string{"controller": "test", "resource": "Namespace"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+			labelsC := make(chan map[string]string, 1)
+			h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+				labels := map[string]string{}
+				pprof.ForLabels(ctx, func(k, v string) bool {
+					labels[k] = v
+					return true
+				})
+				select {
+				case labelsC <- labels:
+				default:
+				}
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:            "test",
+				Handler:         h,
+				Retriever:       newNamespaceRetriever(mc),
+				ProfilingLabels: test.profilingLabels,
+				Logger:          log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			select {
+			case labels := <-labelsC:
+				assert.Equal(test.expLabels, labels)
+			case <-time.After(1 * time.Second):
+				require.Fail("object not handled")
+			}
+		})
+	}
+}
+
+func TestGenericControllerReconcileEnabledAnnotation(t *testing.T) {
+	tests := map[string]struct {
+		annotation     string
+		annotations    map[string]string
+		expHandled     bool
+		expSkippedLogs int
+	}{
+		"An object without the annotation should be handled.": {
+			expHandled: true,
+		},
+
+		"An opted in object should be handled.": {
+			annotations: map[string]string{controller.ReconcileEnabledAnnotation: "true"},
+			expHandled:  true,
+		},
+
+		"An opted out object should be skipped and logged once.": {
+			annotations:    map[string]string{controller.ReconcileEnabledAnnotation: "false"},
+			expHandled:     false,
+			expSkippedLogs: 1,
+		},
+
+		"An opted out object with a custom annotation should be skipped and logged once.": {
+			annotation:     "example.com/enabled",
+			annotations:    map[string]string{"example.com/enabled": "false"},
+			expHandled:     false,
+			expSkippedLogs: 1,
+		},
+
+		"An object opted out with other annotation than the custom one should be handled.": {
+			annotation:  "example.com/enabled",
+			annotations: map[string]string{controller.ReconcileEnabledAnnotation: "false"},
+			expHandled:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: test.annotations}})
+
+			var mu sync.Mutex
+			handled, succeeded := 0, 0
+			logger := newTestLogger()
+			c, err := controller.New(&controller.Config{
+				Name: "test",
+				Handler: controller.HandlerFunc(func(context.Context, runtime.Object) error {
+					mu.Lock()
+					defer mu.Unlock()
+					handled++
+					return nil
+				}),
+				Retriever:                  newNamespaceRetriever(mc),
+				ResyncInterval:             1 * time.Second, // Minimum resync allowed by the informers.
+				ReconcileEnabledAnnotation: test.annotation,
+				OnEvent: func(ev controller.Event) {
+					if ev.Type == controller.EventSucceeded {
+						mu.Lock()
+						succeeded++
+						mu.Unlock()
+					}
+				},
+				Logger: logger,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Wait for the resyncs so the object is handled multiple times.
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return succeeded >= 2
+			}, 3*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(test.expHandled, handled > 0)
+			assert.Len(logger.Lines("reconcile disabled"), test.expSkippedLogs)
+		})
+	}
+}
+
+func TestGenericControllerReconcileEnabledAnnotationDeleted(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	newNs := func() *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: map[string]string{controller.ReconcileEnabledAnnotation: "false"},
+		}}
+	}
+	mc := fake.NewSimpleClientset(newNs())
+
+	deletedC := make(chan struct{}, 1)
+	logger := newTestLogger()
+	c, err := controller.New(&controller.Config{
+		Name:    "test",
+		Handler: controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		DeleteHandler: controller.HandlerFunc(func(context.Context, runtime.Object) error {
+			deletedC <- struct{}{}
+			return nil
+		}),
+		Retriever: newNamespaceRetriever(mc),
+		Logger:    logger,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	waitSkippedLogs := func(exp int) {
+		require.Eventually(func() bool {
+			return len(logger.Lines("reconcile disabled")) == exp
+		}, 1*time.Second, 5*time.Millisecond)
+	}
+	waitSkippedLogs(1)
+
+	// The deleted object should be forgotten, so the skip of a new one with the same key is logged.
+	require.NoError(mc.CoreV1().Namespaces().Delete(ctx, "test", metav1.DeleteOptions{}))
+	select {
+	case <-deletedC:
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for delete handling")
+	}
+	_, err = mc.CoreV1().Namespaces().Create(ctx, newNs(), metav1.CreateOptions{})
+	require.NoError(err)
+	waitSkippedLogs(2)
+}
+
+func TestGenericControllerContextFactory(t *testing.T) {
+	type ctxKey struct{}
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+	var mu sync.Mutex
+	var handleCtx, factoryCtx context.Context
+	h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handleCtx = ctx
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newNamespaceRetriever(mc),
+		ContextFactory: func(parent context.Context, key string) (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.WithValue(parent, ctxKey{}, "tenant-"+key))
+			mu.Lock()
+			defer mu.Unlock()
+			factoryCtx = ctx
+			return ctx, cancel
+		},
+		ProcessingTimeout: time.Minute,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handleCtx != nil
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The handler should receive the factory context, and it should be cancelled after the handling.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal("tenant-test", handleCtx.Value(ctxKey{}))
+	_, hasDeadline := handleCtx.Deadline()
+	assert.True(hasDeadline, "the timeout should be derived from the factory context")
+	assert.Eventually(func() bool { return factoryCtx.Err() != nil }, 1*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// idleNotifier knows how to notify when the controller has processed all the queued objects
+// (queue empty and no objects being processed). The notification is debounced so it doesn't
+// flap between consecutive events.
+//
+// The notifications are only made while `run` is running, with its context.
+//
+// A nil idleNotifier is valid and will not notify.
+type idleNotifier struct {
+	mu       sync.Mutex
+	inFlight int
+	busy     bool   // busy is true when objects have been processed since the last notification.
+	gen      uint64 // gen invalidates the pending notifications when new processing starts.
+	idleC    chan struct{}
+	debounce time.Duration
+	queueLen func(ctx context.Context) int
+	onIdle   func(ctx context.Context)
+}
+
+func newIdleNotifier(debounce time.Duration, queueLen func(ctx context.Context) int, onIdle func(ctx context.Context)) *idleNotifier {
+	return &idleNotifier{
+		idleC:    make(chan struct{}, 1),
+		debounce: debounce,
+		queueLen: queueLen,
+		onIdle:   onIdle,
+	}
+}
+
+// run will notify the idle states until the context is done.
+func (i *idleNotifier) run(ctx context.Context) {
+	if i == nil {
+		return
+	}
+
+	debounceT := time.NewTimer(i.debounce)
+	debounceT.Stop()
+	defer debounceT.Stop()
+
+	var gen uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-i.idleC:
+			// Wait the debounce time and notify only if nothing has been processed meanwhile.
+			i.mu.Lock()
+			gen = i.gen
+			i.mu.Unlock()
+			if !debounceT.Stop() {
+				select {
+				case <-debounceT.C:
+				default:
+				}
+			}
+			debounceT.Reset(i.debounce)
+		case <-debounceT.C:
+			i.mu.Lock()
+			notify := i.busy && gen == i.gen && i.isIdle(ctx)
+			if notify {
+				i.busy = false
+			}
+			i.mu.Unlock()
+
+			if notify {
+				i.onIdle(ctx)
+			}
+		}
+	}
+}
+
+func (i *idleNotifier) processingStarted() {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.inFlight++
+	i.busy = true
+	i.gen++
+}
+
+func (i *idleNotifier) processingFinished(ctx context.Context) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.inFlight--
+	if !i.isIdle(ctx) {
+		return
+	}
+
+	select {
+	case i.idleC <- struct{}{}:
+	default:
+	}
+}
+
+func (i *idleNotifier) isIdle(ctx context.Context) bool {
+	return i.inFlight == 0 && i.queueLen(ctx) == 0
+}
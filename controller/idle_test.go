@@ -0,0 +1,115 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerOnIdle(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 5)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	var mu sync.Mutex
+	handled := 0
+	idleCalls := 0
+	handledOnIdle := 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newNamespaceRetriever(mc),
+		OnIdle: func(_ context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			idleCalls++
+			handledOnIdle = handled
+		},
+		IdleDebounce: 50 * time.Millisecond,
+		Logger:       log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return idleCalls > 0
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Wait to check the idle callback is not called multiple times.
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(1, idleCalls)
+	assert.Equal(len(nsList.Items), handledOnIdle)
+}
+
+func TestGenericControllerOnIdleStopsWithController(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	handledC := make(chan struct{}, 1)
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		handledC <- struct{}{}
+		return nil
+	})
+
+	var mu sync.Mutex
+	idleCalls := 0
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newNamespaceRetriever(mc),
+		OnIdle: func(_ context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			idleCalls++
+		},
+		IdleDebounce: 100 * time.Millisecond,
+		Logger:       log.Dummy,
+	})
+	require.NoError(err)
+	resultC := make(chan error)
+	go func() { resultC <- c.Run(ctx) }()
+
+	// Stop the controller while the idle notification is waiting the debounce.
+	<-handledC
+	cancelCtx()
+	require.NoError(<-resultC)
+
+	// The pending idle notification should not be made after the controller has stopped.
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(0, idleCalls)
+}
@@ -0,0 +1,11 @@
+package controller
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+// SplitKey returns the namespace and name of an object key received by the controller. Cluster
+// scoped objects will have an empty namespace.
+func SplitKey(key string) (namespace, name string, err error) {
+	return cache.SplitMetaNamespaceKey(key)
+}
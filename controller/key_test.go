@@ -0,0 +1,49 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := map[string]struct {
+		key          string
+		expNamespace string
+		expName      string
+		expErr       bool
+	}{
+		"A namespaced object key should return the namespace and the name.": {
+			key:          "test-ns/test-name",
+			expNamespace: "test-ns",
+			expName:      "test-name",
+		},
+
+		"A cluster scoped object key should return only the name.": {
+			key:     "test-name",
+			expName: "test-name",
+		},
+
+		"An invalid key should fail.": {
+			key:    "test-ns/test-name/other",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotNamespace, gotName, err := controller.SplitKey(test.key)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expNamespace, gotNamespace)
+				assert.Equal(test.expName, gotName)
+			}
+		})
+	}
+}
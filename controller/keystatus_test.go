@@ -0,0 +1,167 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerKeyStatus(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 2)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The first object blocks the only worker, the second one blocks and then fails.
+	release0 := make(chan struct{})
+	release1 := make(chan struct{})
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		switch obj.(*corev1.Namespace).Name {
+		case "testing-0":
+			<-release0
+		case "testing-1":
+			<-release1
+			return fmt.Errorf("wanted error")
+		}
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		ConcurrentWorkers:    1,
+		ProcessingJobRetries: 1,
+		RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Hour, time.Hour, 1),
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+
+	require.Equal(controller.KeyStatusNotPresent, c.(controller.KeyStatuser).KeyStatus("testing-0"))
+	go func() { _ = c.Run(ctx) }()
+
+	waitStatus := func(key string, exp controller.KeyStatus) {
+		require.Eventually(func() bool { return c.(controller.KeyStatuser).KeyStatus(key) == exp }, 1*time.Second, 5*time.Millisecond, "%s should be %s", key, exp)
+	}
+
+	// First object is being handled and the second one waits.
+	waitStatus("testing-0", controller.KeyStatusProcessing)
+	waitStatus("testing-1", controller.KeyStatusQueued)
+
+	// First object is done and the second one is being handled.
+	close(release0)
+	waitStatus("testing-0", controller.KeyStatusNotPresent)
+	waitStatus("testing-1", controller.KeyStatusProcessing)
+
+	// Second object fails and waits to be retried.
+	close(release1)
+	waitStatus("testing-1", controller.KeyStatusFailing)
+	require.Equal(controller.KeyStatusNotPresent, c.(controller.KeyStatuser).KeyStatus("unknown"))
+}
+
+func TestGenericControllerKeyStatusRetryPolicy(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		return fmt.Errorf("wanted error")
+	})
+
+	// The failed object is retried by the policy after a long delay.
+	c, err := controller.New(&controller.Config{
+		Name:        "test",
+		Handler:     h,
+		Retriever:   newNamespaceRetriever(mc),
+		RetryPolicy: func(error, int) (bool, time.Duration) { return true, time.Hour },
+		Logger:      log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The retried object should be failing while it waits to be retried.
+	require.Eventually(func() bool {
+		return c.(controller.KeyStatuser).KeyStatus("testing-0") == controller.KeyStatusFailing
+	}, 1*time.Second, 5*time.Millisecond)
+	require.Equal([]controller.QueueItem{{Key: "testing-0", Requeues: 1, Status: controller.KeyStatusFailing}}, c.(controller.KeyStatuser).QueueSnapshot())
+}
+
+func TestGenericControllerQueueSnapshot(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 3)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The first object blocks the only worker, the second one blocks and then fails.
+	release0 := make(chan struct{})
+	release1 := make(chan struct{})
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		switch obj.(*corev1.Namespace).Name {
+		case "testing-0":
+			<-release0
+		case "testing-1":
+			<-release1
+			return fmt.Errorf("wanted error")
+		}
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		ConcurrentWorkers:    1,
+		ProcessingJobRetries: 1,
+		RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Hour, time.Hour, 1),
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+
+	require.Empty(c.(controller.KeyStatuser).QueueSnapshot())
+	go func() { _ = c.Run(ctx) }()
+
+	waitSnapshot := func(exp []controller.QueueItem) {
+		require.Eventually(func() bool { return reflect.DeepEqual(exp, c.(controller.KeyStatuser).QueueSnapshot()) }, 1*time.Second, 5*time.Millisecond, "snapshot should be %v", exp)
+	}
+
+	// First object is being handled and the rest wait.
+	waitSnapshot([]controller.QueueItem{
+		{Key: "testing-0", Status: controller.KeyStatusProcessing},
+		{Key: "testing-1", Status: controller.KeyStatusQueued},
+		{Key: "testing-2", Status: controller.KeyStatusQueued},
+	})
+
+	// First object is done and the second one is being handled.
+	close(release0)
+	waitSnapshot([]controller.QueueItem{
+		{Key: "testing-1", Status: controller.KeyStatusProcessing},
+		{Key: "testing-2", Status: controller.KeyStatusQueued},
+	})
+
+	// Second object fails and waits to be retried, the third one is done.
+	close(release1)
+	waitSnapshot([]controller.QueueItem{
+		{Key: "testing-1", Requeues: 1, Status: controller.KeyStatusFailing},
+	})
+}
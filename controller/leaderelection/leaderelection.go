@@ -6,13 +6,10 @@ import (
 	"os"
 	"time"
 
-	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/tools/record"
 
 	"github.com/spotahome/kooper/v2/log"
 )
@@ -37,10 +34,66 @@ type LockConfig struct {
 	RetryPeriod time.Duration
 }
 
+// MetricsRecorder knows how to record the leader election metrics.
+type MetricsRecorder interface {
+	// ObserveLeaderElectionAcquisitionDuration measures how long it took to acquire the leadership
+	// since the runner started.
+	ObserveLeaderElectionAcquisitionDuration(ctx context.Context, id string, startedAt time.Time)
+	// IncLeaderElectionTransition increments in one the leadership transitions (new leaders) observed.
+	IncLeaderElectionTransition(ctx context.Context, id string)
+}
+
+// DummyMetricsRecorder is a dummy leader election metrics recorder.
+var DummyMetricsRecorder = dummyMetricsRecorder(0)
+var _ MetricsRecorder = DummyMetricsRecorder
+
+type dummyMetricsRecorder int
+
+func (dummyMetricsRecorder) ObserveLeaderElectionAcquisitionDuration(context.Context, string, time.Time) {
+}
+func (dummyMetricsRecorder) IncLeaderElectionTransition(context.Context, string) {}
+
+// Config is the leader election runner configuration.
+type Config struct {
+	// Key is the key of the lock, it identifies the instances of the same controller.
+	Key string
+	// Namespace is the namespace where the lock will be stored.
+	Namespace string
+	// LockConfig is the lock configuration, by default it will use safe settings.
+	LockConfig *LockConfig
+	// KubeClient is the Kubernetes client used to manage the lock.
+	KubeClient kubernetes.Interface
+	// Logger is the logger, by default a dummy logger.
+	Logger log.Logger
+	// MetricsRecorder is the leader election metrics recorder, by default metrics are disabled.
+	MetricsRecorder MetricsRecorder
+}
+
+func (c *Config) setDefaults() {
+	// If lock configuration is nil then fallback to defaults.
+	if c.LockConfig == nil {
+		c.LockConfig = &LockConfig{
+			LeaseDuration: defLeaseDuration,
+			RenewDeadline: defRenewDeadline,
+			RetryPeriod:   defRetryPeriod,
+		}
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Dummy
+	}
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = DummyMetricsRecorder
+	}
+}
+
 // Runner knows how to run using the leader election.
 type Runner interface {
-	// Run will run if the instance takes the lead. It's a blocking action.
-	Run(func() error) error
+	// Run will run if the instance takes the lead. It's a blocking action. The context received
+	// by the function will be cancelled when the leadership is lost, the function must stop when
+	// the context is done.
+	Run(func(ctx context.Context) error) error
 }
 
 // runner is the leader election default implementation.
@@ -51,6 +104,7 @@ type runner struct {
 	lockCfg      *LockConfig
 	resourceLock resourcelock.Interface
 	logger       log.Logger
+	metrics      MetricsRecorder
 }
 
 // NewDefault returns a new leader election service with a safe lock configuration.
@@ -60,23 +114,28 @@ func NewDefault(key, namespace string, k8scli kubernetes.Interface, logger log.L
 
 // New returns a new leader election service.
 func New(key, namespace string, lockCfg *LockConfig, k8scli kubernetes.Interface, logger log.Logger) (Runner, error) {
-	// If lock configuration is nil then fallback to defaults.
-	if lockCfg == nil {
-		lockCfg = &LockConfig{
-			LeaseDuration: defLeaseDuration,
-			RenewDeadline: defRenewDeadline,
-			RetryPeriod:   defRetryPeriod,
-		}
-	}
+	return NewFromConfig(Config{
+		Key:        key,
+		Namespace:  namespace,
+		LockConfig: lockCfg,
+		KubeClient: k8scli,
+		Logger:     logger,
+	})
+}
+
+// NewFromConfig returns a new leader election service using a configuration.
+func NewFromConfig(cfg Config) (Runner, error) {
+	cfg.setDefaults()
 
 	r := &runner{
-		lockCfg:   lockCfg,
-		key:       key,
-		namespace: namespace,
-		k8scli:    k8scli,
-		logger: logger.WithKV(log.KV{
+		lockCfg:   cfg.LockConfig,
+		key:       cfg.Key,
+		namespace: cfg.Namespace,
+		k8scli:    cfg.KubeClient,
+		metrics:   cfg.MetricsRecorder,
+		logger: cfg.Logger.WithKV(log.KV{
 			"source-service":     "kooper/leader-election",
-			"leader-election-id": fmt.Sprintf("%s/%s", namespace, key),
+			"leader-election-id": fmt.Sprintf("%s/%s", cfg.Namespace, cfg.Key),
 		}),
 	}
 
@@ -111,9 +170,8 @@ func (r *runner) initResourceLock() error {
 	}
 	id := hostname + "_" + string(uuid.NewUUID())
 
-	eventBroadcaster := record.NewBroadcaster()
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: r.key, Host: id})
-
+	// Don't use an event recorder, the events were not sent to any sink and the
+	// event broadcaster goroutine was leaked.
 	rl, err := resourcelock.New(
 		resourcelock.ConfigMapsLeasesResourceLock,
 		r.namespace,
@@ -121,8 +179,7 @@ func (r *runner) initResourceLock() error {
 		r.k8scli.CoreV1(),
 		r.k8scli.CoordinationV1(),
 		resourcelock.ResourceLockConfig{
-			Identity:      id,
-			EventRecorder: recorder,
+			Identity: id,
 		},
 	)
 	if err != nil {
@@ -134,18 +191,18 @@ func (r *runner) initResourceLock() error {
 
 }
 
-func (r *runner) Run(f func() error) error {
-	errC := make(chan error, 1) // Channel to get the function returning error.
+func (r *runner) Run(f func(ctx context.Context) error) error {
+	fErrC := make(chan error, 1)    // Channel to get the function result.
+	lostC := make(chan struct{}, 1) // Channel to know when the leadership has been lost.
+	id := fmt.Sprintf("%s/%s", r.namespace, r.key)
+	startedAt := time.Now()
 
-	// The function to execute when leader acquired.
+	// The function to execute when leader acquired, the context will be cancelled by
+	// the leader elector when the leadership is lost.
 	lef := func(ctx context.Context) {
+		r.metrics.ObserveLeaderElectionAcquisitionDuration(ctx, id, startedAt)
 		r.logger.Infof("lead acquire, starting...")
-		// Wait until f finishes or leader elector runner stops.
-		select {
-		case <-ctx.Done():
-			errC <- nil
-		case errC <- f():
-		}
+		fErrC <- f(ctx)
 		r.logger.Infof("lead execution stopped")
 	}
 
@@ -158,7 +215,13 @@ func (r *runner) Run(f func() error) error {
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: lef,
 			OnStoppedLeading: func() {
-				errC <- fmt.Errorf("leadership lost")
+				select {
+				case lostC <- struct{}{}:
+				default:
+				}
+			},
+			OnNewLeader: func(string) {
+				r.metrics.IncLeaderElectionTransition(context.Background(), id)
 			},
 		},
 	}
@@ -170,10 +233,20 @@ func (r *runner) Run(f func() error) error {
 	}
 
 	// Execute!
+	// Stop the leader elector when we finish so we don't leak the goroutine renewing the lease.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	r.logger.Infof("running in leader election mode, waiting to acquire leadership...")
-	go le.Run(context.TODO())
+	go le.Run(ctx)
 
 	// Wait until stopping the execution returns the result.
-	err = <-errC
-	return err
+	select {
+	case err := <-fErrC:
+		return err
+	case <-lostC:
+		// The leader elector only stops leading (before we stop it) after acquiring the leadership,
+		// so wait until the function stops, we don't want to keep running without the lead.
+		<-fErrC
+		return fmt.Errorf("leadership lost")
+	}
 }
@@ -0,0 +1,167 @@
+package leaderelection_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/spotahome/kooper/v2/controller/leaderelection"
+)
+
+// testMetricsRecorder records the leader election metrics.
+type testMetricsRecorder struct {
+	mu           sync.Mutex
+	acquisitions []time.Duration
+	transitions  int
+}
+
+func (t *testMetricsRecorder) ObserveLeaderElectionAcquisitionDuration(_ context.Context, id string, startedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.acquisitions = append(t.acquisitions, time.Since(startedAt))
+}
+
+func (t *testMetricsRecorder) IncLeaderElectionTransition(_ context.Context, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transitions++
+}
+
+func TestRunnerMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mc := fake.NewSimpleClientset()
+	lockCfg := &leaderelection.LockConfig{
+		LeaseDuration: 9999 * time.Second,
+		RenewDeadline: 9998 * time.Second,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+
+	// The leader.
+	mrec1 := &testMetricsRecorder{}
+	r1, err := leaderelection.NewFromConfig(leaderelection.Config{
+		Key:             "test",
+		Namespace:       "default",
+		LockConfig:      lockCfg,
+		KubeClient:      mc,
+		MetricsRecorder: mrec1,
+	})
+	require.NoError(err)
+
+	// The follower.
+	mrec2 := &testMetricsRecorder{}
+	r2, err := leaderelection.NewFromConfig(leaderelection.Config{
+		Key:             "test",
+		Namespace:       "default",
+		LockConfig:      lockCfg,
+		KubeClient:      mc,
+		MetricsRecorder: mrec2,
+	})
+	require.NoError(err)
+
+	// Run the leader until the follower observes the leader.
+	stopC := make(chan struct{})
+	resultC := make(chan error)
+	go func() {
+		resultC <- r1.Run(func(context.Context) error {
+			<-stopC
+			return nil
+		})
+	}()
+	require.Eventually(func() bool {
+		mrec1.mu.Lock()
+		defer mrec1.mu.Unlock()
+		return len(mrec1.acquisitions) == 1 && mrec1.transitions == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	go func() {
+		_ = r2.Run(func(context.Context) error { return nil })
+	}()
+	require.Eventually(func() bool {
+		mrec2.mu.Lock()
+		defer mrec2.mu.Unlock()
+		return mrec2.transitions == 1
+	}, 1*time.Second, 5*time.Millisecond)
+
+	close(stopC)
+	require.NoError(<-resultC)
+
+	// The leader acquired the leadership and observed itself as the new leader, the follower
+	// only observed the leader.
+	mrec1.mu.Lock()
+	defer mrec1.mu.Unlock()
+	mrec2.mu.Lock()
+	defer mrec2.mu.Unlock()
+	assert.Len(mrec1.acquisitions, 1)
+	assert.Less(mrec1.acquisitions[0], 1*time.Second)
+	assert.Equal(1, mrec1.transitions)
+	assert.Empty(mrec2.acquisitions)
+}
+
+func TestRunnerLeadershipLost(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// The lock updates fail when enabled.
+	mc := fake.NewSimpleClientset()
+	var failUpdates int32
+	mc.PrependReactor("update", "*", func(kubetesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(&failUpdates) == 1 {
+			return true, nil, fmt.Errorf("wanted error")
+		}
+		return false, nil, nil
+	})
+
+	r, err := leaderelection.NewFromConfig(leaderelection.Config{
+		Key:       "test",
+		Namespace: "default",
+		LockConfig: &leaderelection.LockConfig{
+			LeaseDuration: 1 * time.Second,
+			RenewDeadline: 500 * time.Millisecond,
+			RetryPeriod:   100 * time.Millisecond,
+		},
+		KubeClient: mc,
+	})
+	require.NoError(err)
+
+	// Run until the function context is cancelled.
+	leadingC := make(chan struct{})
+	fStoppedC := make(chan struct{})
+	resultC := make(chan error)
+	go func() {
+		resultC <- r.Run(func(ctx context.Context) error {
+			close(leadingC)
+			<-ctx.Done()
+			close(fStoppedC)
+			return nil
+		})
+	}()
+	<-leadingC
+
+	// Make the lock renewals fail so the leadership is lost.
+	atomic.StoreInt32(&failUpdates, 1)
+
+	select {
+	case err := <-resultC:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		require.FailNow("timeout waiting for the leadership to be lost")
+	}
+
+	// The function should have been stopped before returning.
+	select {
+	case <-fStoppedC:
+	default:
+		assert.Fail("the function should be stopped when the leadership is lost")
+	}
+}
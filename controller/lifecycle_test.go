@@ -0,0 +1,138 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/leaderelection"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// countControllerGoroutines counts the goroutines related with the controllers, ignoring
+// the ones from the tests and the runtime.
+func countControllerGoroutines() int {
+	buf := make([]byte, 1<<22)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	count := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "kooper/v2/controller.") ||
+			strings.Contains(g, "k8s.io/client-go/tools/cache.") ||
+			strings.Contains(g, "k8s.io/client-go/tools/leaderelection.") ||
+			strings.Contains(g, "k8s.io/apimachinery/pkg/watch.") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGenericControllerDoesntLeakGoroutines(t *testing.T) {
+	tests := map[string]struct {
+		leaderElection bool
+		loseLeadership bool
+	}{
+		"Running and stopping controllers multiple times shouldn't leak goroutines.": {},
+
+		"Running and stopping controllers with leader election multiple times shouldn't leak goroutines.": {
+			leaderElection: true,
+		},
+
+		"Controllers that lose the leadership should stop and shouldn't leak goroutines.": {
+			leaderElection: true,
+			loseLeadership: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			runAndStop := func() {
+				ctx, cancelCtx := context.WithCancel(context.Background())
+				defer cancelCtx()
+
+				_, nss := createNamespaceList("testing", 3)
+				mc := fake.NewSimpleClientset(nss[0], nss[1], nss[2])
+				var failUpdates int32
+				mc.PrependReactor("update", "*", func(kubetesting.Action) (bool, kruntime.Object, error) {
+					if atomic.LoadInt32(&failUpdates) == 1 {
+						return true, nil, fmt.Errorf("wanted error")
+					}
+					return false, nil, nil
+				})
+				handledC := make(chan struct{}, 3)
+				h := controller.HandlerFunc(func(context.Context, kruntime.Object) error {
+					handledC <- struct{}{}
+					return nil
+				})
+
+				var le leaderelection.Runner
+				if test.leaderElection {
+					var err error
+					lockCfg := &leaderelection.LockConfig{
+						LeaseDuration: 9999 * time.Second,
+						RenewDeadline: 9998 * time.Second,
+						RetryPeriod:   500 * time.Second,
+					}
+					if test.loseLeadership {
+						lockCfg = &leaderelection.LockConfig{
+							LeaseDuration: 1 * time.Second,
+							RenewDeadline: 500 * time.Millisecond,
+							RetryPeriod:   100 * time.Millisecond,
+						}
+					}
+					le, err = leaderelection.New("test", "default", lockCfg, mc, log.Dummy)
+					require.NoError(err)
+				}
+
+				c, err := controller.New(&controller.Config{
+					Name:          "test",
+					Handler:       h,
+					Retriever:     newNamespaceRetriever(mc),
+					LeaderElector: le,
+					Logger:        log.Dummy,
+				})
+				require.NoError(err)
+
+				resultC := make(chan error)
+				go func() { resultC <- c.Run(ctx) }()
+				for i := 0; i < 3; i++ {
+					<-handledC
+				}
+
+				// Losing the leadership (failing the lock renewals) should stop the controller.
+				if test.loseLeadership {
+					atomic.StoreInt32(&failUpdates, 1)
+					require.Error(<-resultC)
+					return
+				}
+
+				cancelCtx()
+				require.NoError(<-resultC)
+			}
+
+			baseGoroutines := countControllerGoroutines()
+
+			for i := 0; i < 5; i++ {
+				runAndStop()
+			}
+
+			// Give some time to the goroutines that are finishing after the controllers have stopped.
+			assert.Eventually(t, func() bool {
+				return countControllerGoroutines() <= baseGoroutines
+			}, 1*time.Second, 10*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ConcurrencyLimiter limits the number of objects handled concurrently, it can be shared by
+// multiple controllers (`Config.ConcurrencyLimiter`) to limit the global concurrent handlings
+// (e.g: to protect a shared rate limited API).
+type ConcurrencyLimiter struct {
+	sem chan struct{}
+}
+
+// NewConcurrencyLimiter returns a new ConcurrencyLimiter that allows up to max concurrent
+// handlings.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	if max < 1 {
+		max = 1
+	}
+
+	return &ConcurrencyLimiter{sem: make(chan struct{}, max)}
+}
+
+// InFlight returns the number of objects being handled with the limiter.
+func (c *ConcurrencyLimiter) InFlight() int {
+	return len(c.sem)
+}
+
+func (c *ConcurrencyLimiter) acquire(ctx context.Context) error {
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *ConcurrencyLimiter) release() {
+	<-c.sem
+}
+
+// newConcurrencyLimitResultHandler returns a ResultHandler that waits for the limiter before
+// handling the objects.
+func newConcurrencyLimitResultHandler(limiter *ConcurrencyLimiter, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		err := limiter.acquire(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		defer limiter.release()
+
+		return h.HandleWithResult(ctx, obj)
+	})
+}
+
+// newRateLimitResultHandler returns a ResultHandler that waits for the rate limiter before
+// handling the objects.
+func newRateLimitResultHandler(limiter *rate.Limiter, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		err := limiter.Wait(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+
+		return h.HandleWithResult(ctx, obj)
+	})
+}
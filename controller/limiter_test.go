@@ -0,0 +1,149 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerSharedConcurrencyLimiter(t *testing.T) {
+	const (
+		objects        = 6
+		maxConcurrency = 2
+	)
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	limiter := controller.NewConcurrencyLimiter(maxConcurrency)
+
+	// Track the concurrent handlings of all the controllers.
+	var mu sync.Mutex
+	inFlight, maxInFlight, handled := 0, 0, 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		handled++
+		mu.Unlock()
+		return nil
+	})
+
+	for _, name := range []string{"ctrl1", "ctrl2"} {
+		objs := []runtime.Object{}
+		_, nss := createNamespaceList(name, objects)
+		for _, ns := range nss {
+			objs = append(objs, ns)
+		}
+
+		c, err := controller.New(&controller.Config{
+			Name:               name,
+			Handler:            h,
+			Retriever:          newNamespaceRetriever(fake.NewSimpleClientset(objs...)),
+			ConcurrentWorkers:  objects,
+			ConcurrencyLimiter: limiter,
+			Logger:             log.Dummy,
+		})
+		require.NoError(err)
+		go func() { _ = c.Run(ctx) }()
+	}
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == 2*objects
+	}, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(maxConcurrency, maxInFlight)
+	assert.Equal(0, limiter.InFlight())
+}
+
+func TestGenericControllerMaxReconcileRate(t *testing.T) {
+	tests := map[string]struct {
+		rate           float64
+		burst          int
+		objects        int
+		expMinDuration time.Duration
+	}{
+		"The handlings should start at the max rate regardless of the workers.": {
+			rate:           20,
+			objects:        6,
+			expMinDuration: 5 * 50 * time.Millisecond,
+		},
+
+		"The burst should start multiple handlings at once over the max rate.": {
+			rate:           20,
+			burst:          3,
+			objects:        6,
+			expMinDuration: 3 * 50 * time.Millisecond,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", test.objects)
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			var mu sync.Mutex
+			startedAt := []time.Time{}
+			h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				startedAt = append(startedAt, time.Now())
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:              "test",
+				Handler:           h,
+				Retriever:         newNamespaceRetriever(mc),
+				ConcurrentWorkers: test.objects,
+				MaxReconcileRate:  test.rate,
+				MaxReconcileBurst: test.burst,
+				Logger:            log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(startedAt) == test.objects
+			}, 2*time.Second, 5*time.Millisecond)
+
+			// With all the objects queued at once, the handlings can't start faster than the rate.
+			mu.Lock()
+			defer mu.Unlock()
+			duration := startedAt[len(startedAt)-1].Sub(startedAt[0])
+			assert.GreaterOrEqual(duration, test.expMinDuration-10*time.Millisecond)
+			assert.Less(duration, test.expMinDuration+200*time.Millisecond)
+		})
+	}
+}
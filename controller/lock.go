@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"context"
+)
+
+// ObjectLocker knows how to get exclusive access to an object, this is useful when
+// multiple processes (not only replicas of the same app) could handle the same objects
+// and only one of them should handle a particular object at the same time (e.g
+// using a lease per object).
+//
+// This is more granular (and heavier) than leader election.
+type ObjectLocker interface {
+	// Lock tries to acquire the lock of the object key. If the lock is held by
+	// someone else it will return false.
+	Lock(ctx context.Context, key string) (acquired bool, err error)
+	// Unlock releases the lock of the object key.
+	Unlock(ctx context.Context, key string) error
+}
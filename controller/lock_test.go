@@ -0,0 +1,163 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// testObjectLocker is a fake lock where some keys are held by someone else.
+type testObjectLocker struct {
+	mu          sync.Mutex
+	heldByOther map[string]bool
+	locked      map[string]bool
+	unlocked    []string
+	lockCalls   int
+}
+
+func (t *testObjectLocker) Lock(_ context.Context, key string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lockCalls++
+	if t.heldByOther[key] {
+		return false, nil
+	}
+	t.locked[key] = true
+	return true, nil
+}
+
+func (t *testObjectLocker) Unlock(_ context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.locked, key)
+	t.unlocked = append(t.unlocked, key)
+	return nil
+}
+
+func TestGenericControllerObjectLocker(t *testing.T) {
+	tests := map[string]struct {
+		heldByOther map[string]bool
+		expHandled  []string
+		expUnlocked []string
+	}{
+		"Objects without the lock held elsewhere should be handled and the lock released.": {
+			heldByOther: map[string]bool{},
+			expHandled:  []string{"testing-0", "testing-1", "testing-2"},
+			expUnlocked: []string{"testing-0", "testing-1", "testing-2"},
+		},
+
+		"Objects with the lock held elsewhere should not be handled while held.": {
+			heldByOther: map[string]bool{"testing-1": true},
+			expHandled:  []string{"testing-0", "testing-2"},
+			expUnlocked: []string{"testing-0", "testing-2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", 3)
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			locker := &testObjectLocker{heldByOther: test.heldByOther, locked: map[string]bool{}}
+
+			var mu sync.Mutex
+			handled := []string{}
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled = append(handled, obj.(*corev1.Namespace).Name)
+				return nil
+			})
+
+			c, err := controller.New(&controller.Config{
+				Name:                    "test",
+				Handler:                 h,
+				Retriever:               newNamespaceRetriever(mc),
+				ObjectLocker:            locker,
+				ObjectLockRetryInterval: time.Hour,
+				Logger:                  log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			// Wait until all the objects have tried to get the lock.
+			require.Eventually(func() bool {
+				locker.mu.Lock()
+				defer locker.mu.Unlock()
+				return locker.lockCalls == len(nsList.Items) && len(locker.unlocked) == len(test.expUnlocked)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			locker.mu.Lock()
+			defer locker.mu.Unlock()
+			assert.ElementsMatch(test.expHandled, handled)
+			assert.ElementsMatch(test.expUnlocked, locker.unlocked)
+			assert.Empty(locker.locked)
+		})
+	}
+}
+
+func TestGenericControllerObjectLockerRetry(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	locker := &testObjectLocker{heldByOther: map[string]bool{"testing-0": true}, locked: map[string]bool{}}
+
+	handledC := make(chan string, 1)
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		handledC <- obj.(*corev1.Namespace).Name
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:                    "test",
+		Handler:                 h,
+		Retriever:               newNamespaceRetriever(mc),
+		ObjectLocker:            locker,
+		ObjectLockRetryInterval: 10 * time.Millisecond,
+		Logger:                  log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The object should be retried while the lock is held elsewhere.
+	require.Eventually(func() bool {
+		locker.mu.Lock()
+		defer locker.mu.Unlock()
+		return locker.lockCalls >= 2
+	}, 1*time.Second, 5*time.Millisecond)
+	require.Empty(handledC)
+
+	// Once released, the object should be handled.
+	locker.mu.Lock()
+	locker.heldByOther = map[string]bool{}
+	locker.mu.Unlock()
+	select {
+	case name := <-handledC:
+		require.Equal("testing-0", name)
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for handling")
+	}
+}
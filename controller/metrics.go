@@ -2,7 +2,10 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // MetricsRecorder knows how to record metrics of a controller.
@@ -10,7 +13,8 @@ type MetricsRecorder interface {
 	// IncResourceEvent increments in one the metric records of a queued event.
 	IncResourceEventQueued(ctx context.Context, controller string, isRequeue bool)
 	// ObserveResourceInQueueDuration measures how long takes to dequeue a queued object. If the object is already in queue
-	// it will be measured once, since the first time it was added to the queue.
+	// it will be measured once, since the first time it was added to the queue. High values usually
+	// mean that there are not enough workers to process the queued objects.
 	ObserveResourceInQueueDuration(ctx context.Context, controller string, queuedAt time.Time)
 	// ObserveResourceProcessingDuration measures how long it takes to process a resources (handling).
 	ObserveResourceProcessingDuration(ctx context.Context, controller string, success bool, startProcessingAt time.Time)
@@ -19,6 +23,54 @@ type MetricsRecorder interface {
 	RegisterResourceQueueLengthFunc(controller string, f func(context.Context) int) error
 }
 
+// ErrorMetricsRecorder is an optional interface that the MetricsRecorder can implement to
+// record the handler errors by category.
+type ErrorMetricsRecorder interface {
+	// IncResourceProcessingError increments in one the metric records of a handler error with
+	// the category of the error (e.g: conflict, not-found...).
+	IncResourceProcessingError(ctx context.Context, controller string, category string)
+}
+
+// OutcomeMetricsRecorder is an optional interface that the MetricsRecorder can implement to
+// record the outcomes of the handler results (`Result.Outcome`).
+type OutcomeMetricsRecorder interface {
+	// IncResourceProcessingOutcome increments in one the metric records of a handler result outcome.
+	IncResourceProcessingOutcome(ctx context.Context, controller string, outcome string)
+}
+
+// AgeMetricsRecorder is an optional interface that the MetricsRecorder can implement to record
+// the handling durations by the age of the handled objects (the time since their creation), this
+// helps detecting if the new objects take longer to be handled.
+type AgeMetricsRecorder interface {
+	// ObserveResourceProcessingDurationByAge measures how long it takes to process a resource (handling)
+	// with the age of the resource when the processing started.
+	ObserveResourceProcessingDurationByAge(ctx context.Context, controller string, age time.Duration, startProcessingAt time.Time)
+}
+
+// InFlightMetricsRecorder is an optional interface that the MetricsRecorder can implement to record
+// the number of objects being handled at a given point in time (the active concurrency).
+type InFlightMetricsRecorder interface {
+	// IncResourceProcessingInFlight increments in one the objects being handled.
+	IncResourceProcessingInFlight(ctx context.Context, controller string)
+	// DecResourceProcessingInFlight decrements in one the objects being handled.
+	DecResourceProcessingInFlight(ctx context.Context, controller string)
+}
+
+// TriggerMetricsRecorder is an optional interface that the MetricsRecorder can implement to record the
+// handlings by what triggered them (`Trigger`), this shows what drives the handling load.
+type TriggerMetricsRecorder interface {
+	// IncResourceProcessingTrigger increments in one the metric records of a handling trigger.
+	IncResourceProcessingTrigger(ctx context.Context, controller string, trigger string)
+}
+
+// CacheMetricsRecorder is an optional interface that the MetricsRecorder can implement to
+// record the number of objects in the controller cache.
+type CacheMetricsRecorder interface {
+	// RegisterResourceCacheLengthFunc will register a function that will be called by the
+	// metrics recorder to get the number of objects in the cache at a given point in time.
+	RegisterResourceCacheLengthFunc(controller string, f func(context.Context) int) error
+}
+
 // DummyMetricsRecorder is a dummy metrics recorder.
 var DummyMetricsRecorder = dummy(0)
 var _ MetricsRecorder = DummyMetricsRecorder
@@ -31,3 +83,29 @@ func (dummy) ObserveResourceProcessingDuration(context.Context, string, bool, ti
 func (dummy) RegisterResourceQueueLengthFunc(controller string, f func(context.Context) int) error {
 	return nil
 }
+
+// Error categories used by the DefaultErrorClassifier.
+const (
+	ErrorCategoryConflict = "conflict"
+	ErrorCategoryNotFound = "not-found"
+	ErrorCategoryTimeout  = "timeout"
+	ErrorCategoryOther    = "other"
+)
+
+// ErrorClassifier knows how to categorize the handler errors for the metrics.
+type ErrorClassifier func(err error) string
+
+// DefaultErrorClassifier categorizes the errors using the Kubernetes API errors, the
+// categories are conflict, not-found, timeout and other.
+var DefaultErrorClassifier ErrorClassifier = func(err error) string {
+	switch {
+	case apierrors.IsConflict(err):
+		return ErrorCategoryConflict
+	case apierrors.IsNotFound(err):
+		return ErrorCategoryNotFound
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryTimeout
+	}
+
+	return ErrorCategoryOther
+}
@@ -0,0 +1,683 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	testclock "k8s.io/utils/clock/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// testInQueueMetricsRecorder records the in queue durations measured with the clock.
+type testInQueueMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	clock     clock.PassiveClock
+	mu        sync.Mutex
+	durations []time.Duration
+}
+
+func (t *testInQueueMetricsRecorder) ObserveResourceInQueueDuration(_ context.Context, _ string, queuedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations = append(t.durations, t.clock.Since(queuedAt))
+}
+
+func TestGenericControllerInQueueDurationMetrics(t *testing.T) {
+	const handleLatency = 30 * time.Millisecond
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset()
+
+	// A single worker will delay the pickup of the queued objects, each handling moves the time.
+	clk := testclock.NewFakeClock(time.Now())
+	mrec := &testInQueueMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder, clock: clk}
+	startedC := make(chan struct{})
+	release := make(chan struct{})
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		if obj.(*corev1.Namespace).Name == "testing-0" {
+			close(startedC)
+			<-release
+		}
+		clk.Step(handleLatency)
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		MetricsRecorder:   mrec,
+		Clock:             clk,
+		ConcurrentWorkers: 1,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The rest of objects are queued while the first one is being handled.
+	add := func(name string) {
+		err := c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		require.NoError(err)
+	}
+	add("testing-0")
+	<-startedC
+	add("testing-1")
+	add("testing-2")
+	add("testing-3")
+	close(release)
+
+	// Each object should have waited for all the previous handlings, the objects are picked in order.
+	exp := []time.Duration{0, 1 * handleLatency, 2 * handleLatency, 3 * handleLatency}
+	require.Eventually(func() bool {
+		mrec.mu.Lock()
+		defer mrec.mu.Unlock()
+		return len(mrec.durations) == len(exp)
+	}, 1*time.Second, 5*time.Millisecond)
+	mrec.mu.Lock()
+	defer mrec.mu.Unlock()
+	assert.Equal(exp, mrec.durations)
+}
+
+// testQueuedMetricsRecorder records the queued events by requeue mode.
+type testQueuedMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu     sync.Mutex
+	queued map[bool]int
+}
+
+func (t *testQueuedMetricsRecorder) IncResourceEventQueued(_ context.Context, _ string, isRequeue bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queued[isRequeue]++
+}
+
+func TestGenericControllerRetryPolicyQueuedMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 1)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	var mu sync.Mutex
+	calls := 0
+	h := controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return fmt.Errorf("wanted error")
+	})
+
+	// The policy retries twice without delay.
+	mrec := &testQueuedMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder, queued: map[bool]int{}}
+	c, err := controller.New(&controller.Config{
+		Name:            "test",
+		Handler:         h,
+		Retriever:       newNamespaceRetriever(mc),
+		MetricsRecorder: mrec,
+		RetryPolicy:     func(_ error, attempt int) (bool, time.Duration) { return attempt <= 2, 0 },
+		Logger:          log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls == 3
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The retries should be measured as requeues.
+	mrec.mu.Lock()
+	defer mrec.mu.Unlock()
+	assert.Equal(map[bool]int{false: 1, true: 2}, mrec.queued)
+}
+
+// testErrorMetricsRecorder records the handler error categories.
+type testErrorMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu         sync.Mutex
+	categories map[string]int
+}
+
+func (t *testErrorMetricsRecorder) IncResourceProcessingError(_ context.Context, _ string, category string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.categories[category]++
+}
+
+func TestGenericControllerErrorCategoryMetrics(t *testing.T) {
+	gr := schema.GroupResource{Resource: "namespaces"}
+	errs := map[string]error{
+		"testing-0": apierrors.NewConflict(gr, "testing-0", fmt.Errorf("wanted error")),
+		"testing-1": fmt.Errorf("wrapped: %w", apierrors.NewNotFound(gr, "testing-1")),
+		"testing-2": apierrors.NewTimeoutError("wanted error", 1),
+		"testing-3": fmt.Errorf("wanted error"),
+		"testing-4": fmt.Errorf("wanted error"),
+		"testing-5": nil,
+	}
+
+	tests := map[string]struct {
+		classifier    controller.ErrorClassifier
+		expCategories map[string]int
+	}{
+		"The default classifier should categorize the errors based on the Kubernetes API errors.": {
+			expCategories: map[string]int{
+				controller.ErrorCategoryConflict: 1,
+				controller.ErrorCategoryNotFound: 1,
+				controller.ErrorCategoryTimeout:  1,
+				controller.ErrorCategoryOther:    2,
+			},
+		},
+
+		"A custom classifier should categorize the errors.": {
+			classifier: func(err error) string { return "custom" },
+			expCategories: map[string]int{
+				"custom": 5,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			nsList, _ := createNamespaceList("testing", len(errs))
+			mc := &fake.Clientset{}
+			onKubeClientListNamespaceReturn(mc, nsList)
+
+			var mu sync.Mutex
+			handled := 0
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled++
+				return errs[obj.(*corev1.Namespace).Name]
+			})
+
+			mrec := &testErrorMetricsRecorder{
+				MetricsRecorder: controller.DummyMetricsRecorder,
+				categories:      map[string]int{},
+			}
+			c, err := controller.New(&controller.Config{
+				Name:            "test",
+				Handler:         h,
+				Retriever:       newNamespaceRetriever(mc),
+				MetricsRecorder: mrec,
+				ErrorClassifier: test.classifier,
+				Logger:          log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return handled == len(errs)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// The metrics are recorded after handling.
+			require.Eventually(func() bool {
+				mrec.mu.Lock()
+				defer mrec.mu.Unlock()
+				total := 0
+				for _, v := range mrec.categories {
+					total += v
+				}
+				return total == len(errs)-1
+			}, 1*time.Second, 5*time.Millisecond)
+
+			mrec.mu.Lock()
+			defer mrec.mu.Unlock()
+			assert.Equal(test.expCategories, mrec.categories)
+		})
+	}
+}
+
+func TestGenericControllerDistinctErrorMetrics(t *testing.T) {
+	// The object fails the same way many times and then with a different error.
+	errConflict := apierrors.NewConflict(schema.GroupResource{Resource: "namespaces"}, "test", fmt.Errorf("wanted error"))
+	errOther := fmt.Errorf("wanted error")
+	handlerErrs := []error{errConflict, errConflict, errConflict, errConflict, errOther, errOther}
+
+	tests := map[string]struct {
+		distinct      bool
+		expCategories map[string]int
+	}{
+		"Without distinct errors every failed attempt should be measured.": {
+			expCategories: map[string]int{
+				controller.ErrorCategoryConflict: 4,
+				controller.ErrorCategoryOther:    2,
+			},
+		},
+
+		"With distinct errors only the consecutive error changes should be measured.": {
+			distinct: true,
+			expCategories: map[string]int{
+				controller.ErrorCategoryConflict: 1,
+				controller.ErrorCategoryOther:    1,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+
+			mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+			var mu sync.Mutex
+			handled := 0
+			h := controller.HandlerFunc(func(context.Context, runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				err := handlerErrs[handled]
+				handled++
+				return err
+			})
+
+			mrec := &testErrorMetricsRecorder{
+				MetricsRecorder: controller.DummyMetricsRecorder,
+				categories:      map[string]int{},
+			}
+			c, err := controller.New(&controller.Config{
+				Name:                 "test",
+				Handler:              h,
+				Retriever:            newNamespaceRetriever(mc),
+				MetricsRecorder:      mrec,
+				DistinctErrorMetrics: test.distinct,
+				ProcessingJobRetries: len(handlerErrs) - 1,
+				RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Millisecond, 1),
+				Logger:               log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = c.Run(ctx) }()
+
+			require.Eventually(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return handled == len(handlerErrs)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// The metrics are recorded after handling.
+			expCategories := test.expCategories
+			require.Eventually(func() bool {
+				mrec.mu.Lock()
+				defer mrec.mu.Unlock()
+				return reflect.DeepEqual(expCategories, mrec.categories)
+			}, 1*time.Second, 5*time.Millisecond)
+
+			// No more metrics should be recorded.
+			time.Sleep(20 * time.Millisecond)
+			mrec.mu.Lock()
+			defer mrec.mu.Unlock()
+			assert.Equal(test.expCategories, mrec.categories)
+		})
+	}
+}
+
+// testOutcomeMetricsRecorder records the handler result outcomes.
+type testOutcomeMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu       sync.Mutex
+	outcomes map[string]int
+}
+
+func (t *testOutcomeMetricsRecorder) IncResourceProcessingOutcome(_ context.Context, _ string, outcome string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes[outcome]++
+}
+
+func TestGenericControllerOutcomeMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	outcomes := map[string]string{
+		"testing-0": "created",
+		"testing-1": "updated",
+		"testing-2": "noop",
+		"testing-3": "noop",
+		"testing-4": "",
+	}
+	nsList, _ := createNamespaceList("testing", len(outcomes))
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	h := controller.ResultHandlerFunc(func(_ context.Context, obj runtime.Object) (controller.Result, error) {
+		return controller.Result{Outcome: outcomes[obj.(*corev1.Namespace).Name]}, nil
+	})
+
+	mrec := &testOutcomeMetricsRecorder{
+		MetricsRecorder: controller.DummyMetricsRecorder,
+		outcomes:        map[string]int{},
+	}
+	c, err := controller.New(&controller.Config{
+		Name:            "test",
+		ResultHandler:   h,
+		Retriever:       newNamespaceRetriever(mc),
+		MetricsRecorder: mrec,
+		Logger:          log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mrec.mu.Lock()
+		defer mrec.mu.Unlock()
+		total := 0
+		for _, v := range mrec.outcomes {
+			total += v
+		}
+		return total == 4
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The results without outcome should not be recorded.
+	mrec.mu.Lock()
+	defer mrec.mu.Unlock()
+	assert.Equal(map[string]int{"created": 1, "updated": 1, "noop": 2}, mrec.outcomes)
+}
+
+// testAgeMetricsRecorder records the handled object ages.
+type testAgeMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu   sync.Mutex
+	ages []time.Duration
+}
+
+func (t *testAgeMetricsRecorder) ObserveResourceProcessingDurationByAge(_ context.Context, _ string, age time.Duration, _ time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ages = append(t.ages, age)
+}
+
+func TestGenericControllerAgeMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	// A new object, an old one and one without creation timestamp.
+	now := time.Now()
+	nsList, _ := createNamespaceList("testing", 3)
+	nsList.Items[0].CreationTimestamp = metav1.NewTime(now.Add(-5 * time.Second))
+	nsList.Items[1].CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	var mu sync.Mutex
+	handled := 0
+	h := controller.HandlerFunc(func(context.Context, runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+		return nil
+	})
+
+	mrec := &testAgeMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder}
+	c, err := controller.New(&controller.Config{
+		Name:              "test",
+		Handler:           h,
+		Retriever:         newNamespaceRetriever(mc),
+		ConcurrentWorkers: 1,
+		MetricsRecorder:   mrec,
+		Logger:            log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == 3
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The object without creation timestamp should not be recorded.
+	mrec.mu.Lock()
+	defer mrec.mu.Unlock()
+	require.Len(mrec.ages, 2)
+	assert.InDelta(5*time.Second, mrec.ages[0], float64(time.Second))
+	assert.InDelta(2*time.Hour, mrec.ages[1], float64(time.Second))
+}
+
+// testInFlightMetricsRecorder records the objects being handled.
+type testInFlightMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu       sync.Mutex
+	inFlight int
+}
+
+func (t *testInFlightMetricsRecorder) IncResourceProcessingInFlight(context.Context, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight++
+}
+
+func (t *testInFlightMetricsRecorder) DecResourceProcessingInFlight(context.Context, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+}
+
+func (t *testInFlightMetricsRecorder) InFlight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inFlight
+}
+
+func TestGenericControllerInFlightMetrics(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 3)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The handlings block until released, one of them panics.
+	releaseC := make(chan struct{})
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		<-releaseC
+		if obj.(*corev1.Namespace).Name == "testing-0" {
+			panic("wanted panic")
+		}
+		return nil
+	})
+
+	mrec := &testInFlightMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder}
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		ConcurrentWorkers:    5,
+		RecoverHandlerPanics: true,
+		MetricsRecorder:      mrec,
+		Logger:               log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The gauge should have the concurrent handlings, and be back to 0 when they finish.
+	require.Eventually(func() bool { return mrec.InFlight() == 3 }, 1*time.Second, 5*time.Millisecond)
+	close(releaseC)
+	require.Eventually(func() bool { return mrec.InFlight() == 0 }, 1*time.Second, 5*time.Millisecond)
+}
+
+// testTriggerMetricsRecorder records the handling triggers.
+type testTriggerMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu       sync.Mutex
+	triggers map[string]int
+}
+
+func (t *testTriggerMetricsRecorder) IncResourceProcessingTrigger(_ context.Context, _ string, trigger string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.triggers[trigger]++
+}
+
+func (t *testTriggerMetricsRecorder) Triggers() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := map[string]int{}
+	for k, v := range t.triggers {
+		res[k] = v
+	}
+	return res
+}
+
+func TestGenericControllerTriggerMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+
+	// The handlers should receive the trigger too.
+	var mu sync.Mutex
+	handled := map[controller.Trigger]int{}
+	h := controller.HandlerFunc(func(ctx context.Context, _ runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[controller.TriggerFromContext(ctx)]++
+		return nil
+	})
+	getHandled := func(t controller.Trigger) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[t]
+	}
+
+	mrec := &testTriggerMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder, triggers: map[string]int{}}
+	c, err := controller.New(&controller.Config{
+		Name:            "test",
+		Handler:         h,
+		Retriever:       newNamespaceRetriever(mc),
+		ResyncInterval:  1 * time.Second, // Minimum resync allowed by the informers.
+		MetricsRecorder: mrec,
+		Logger:          log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Add.
+	require.Eventually(func() bool { return getHandled(controller.TriggerAdd) == 1 }, 1*time.Second, 5*time.Millisecond)
+
+	// Resync.
+	require.Eventually(func() bool { return getHandled(controller.TriggerResync) == 1 }, 3*time.Second, 5*time.Millisecond)
+
+	// Update.
+	ns, err := mc.CoreV1().Namespaces().Get(ctx, "test", metav1.GetOptions{})
+	require.NoError(err)
+	ns.Labels = map[string]string{"updated": "true"}
+	ns.ResourceVersion = "2"
+	_, err = mc.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	require.NoError(err)
+	require.Eventually(func() bool { return getHandled(controller.TriggerUpdate) == 1 }, 1*time.Second, 5*time.Millisecond)
+
+	// Other (not an informer event).
+	err = c.(controller.ObjectAdder).AddForProcessing(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}})
+	require.NoError(err)
+	require.Eventually(func() bool { return getHandled(controller.TriggerOther) == 1 }, 1*time.Second, 5*time.Millisecond)
+
+	// Delete, the deleted objects are not handled by the handler.
+	err = mc.CoreV1().Namespaces().Delete(ctx, "test", metav1.DeleteOptions{})
+	require.NoError(err)
+	require.Eventually(func() bool { return mrec.Triggers()["delete"] == 1 }, 1*time.Second, 5*time.Millisecond)
+
+	// The metrics should have the same triggers as the handlings plus the delete. The resyncs continue
+	// meanwhile, so they can't be compared exactly.
+	got := mrec.Triggers()
+	assert.Equal(1, got["add"])
+	assert.Equal(1, got["update"])
+	assert.Equal(1, got["other"])
+	assert.Equal(1, got["delete"])
+	assert.GreaterOrEqual(got["resync"], 1)
+}
+
+// testCacheMetricsRecorder stores the registered cache length func.
+type testCacheMetricsRecorder struct {
+	controller.MetricsRecorder
+
+	mu      sync.Mutex
+	cacheFn func(context.Context) int
+}
+
+func (t *testCacheMetricsRecorder) RegisterResourceCacheLengthFunc(_ string, f func(context.Context) int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cacheFn = f
+	return nil
+}
+
+func TestGenericControllerCacheMetrics(t *testing.T) {
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, nss := createNamespaceList("testing", 3)
+	mc := fake.NewSimpleClientset(nss[0], nss[1], nss[2])
+
+	mrec := &testCacheMetricsRecorder{MetricsRecorder: controller.DummyMetricsRecorder}
+	c, err := controller.New(&controller.Config{
+		Name: "test",
+		Handler: controller.HandlerFunc(func(_ context.Context, _ runtime.Object) error {
+			return nil
+		}),
+		Retriever:       newNamespaceRetriever(mc),
+		MetricsRecorder: mrec,
+		Logger:          log.Dummy,
+	})
+	require.NoError(err)
+
+	mrec.mu.Lock()
+	cacheFn := mrec.cacheFn
+	mrec.mu.Unlock()
+	require.NotNil(cacheFn)
+	require.Equal(0, cacheFn(ctx))
+
+	go func() { _ = c.Run(ctx) }()
+
+	// The gauge should follow the objects of the cache.
+	require.Eventually(func() bool { return cacheFn(ctx) == 3 }, 1*time.Second, 5*time.Millisecond)
+	require.NoError(mc.CoreV1().Namespaces().Delete(ctx, nss[0].Name, metav1.DeleteOptions{}))
+	require.Eventually(func() bool { return cacheFn(ctx) == 2 }, 1*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/spotahome/kooper/v2/log"
+)
+
+type clusterCtxKey struct{}
+
+// ClusterFromContext returns the ID of the cluster of the handled object, for the controllers
+// created with `NewMultiClusterGroup`. If the handling is not from a multi cluster controller it
+// will return an empty string.
+func ClusterFromContext(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterCtxKey{}).(string)
+	return cluster
+}
+
+// NewMultiClusterGroup returns a group with a controller for each of the clusters, the retrievers
+// are the retrievers of each cluster by cluster ID (e.g: retrievers of clients with different
+// rest.Config). The controllers use the same configuration (`Retriever` is ignored) and they are
+// named `<name>-<cluster ID>`, the handlers receive the ID of the object cluster on the context
+// (`ClusterFromContext`).
+//
+// Every cluster has its own controller cache, queue and workers, so the same object key of
+// different clusters will be handled independently.
+func NewMultiClusterGroup(cfg *Config, retrievers map[string]Retriever) (*Group, error) {
+	if len(retrievers) == 0 {
+		return nil, fmt.Errorf("could not create multi cluster controller: %w: at least one cluster retriever is required", ErrControllerNotValid)
+	}
+
+	clusters := make([]string, 0, len(retrievers))
+	for cluster := range retrievers {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+
+	ctrls := make([]Controller, 0, len(clusters))
+	for _, cluster := range clusters {
+		clusterCfg := *cfg
+		clusterCfg.Name = fmt.Sprintf("%s-%s", cfg.Name, cluster)
+		clusterCfg.Retriever = retrievers[cluster]
+		clusterCfg.Handler = newClusterHandler(cluster, cfg.Handler)
+		clusterCfg.DeleteHandler = newClusterHandler(cluster, cfg.DeleteHandler)
+		if cfg.ResultHandler != nil {
+			clusterCfg.ResultHandler = newClusterResultHandler(cluster, cfg.ResultHandler)
+		}
+		if cfg.HandlerFactory != nil {
+			clusterCfg.HandlerFactory = func() Handler { return newClusterHandler(cluster, cfg.HandlerFactory()) }
+		}
+		if cfg.Logger != nil {
+			clusterCfg.Logger = cfg.Logger.WithKV(log.KV{"cluster": cluster})
+		}
+
+		ctrl, err := New(&clusterCfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not create %q cluster controller: %w", cluster, err)
+		}
+		ctrls = append(ctrls, ctrl)
+	}
+
+	return NewGroup(ctrls...), nil
+}
+
+// newClusterHandler returns a handler that sets the cluster ID on the handling context, a nil
+// handler returns nil.
+func newClusterHandler(cluster string, h Handler) Handler {
+	if h == nil {
+		return nil
+	}
+
+	return HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		return h.Handle(context.WithValue(ctx, clusterCtxKey{}, cluster), obj)
+	})
+}
+
+// newClusterResultHandler is like newClusterHandler but for a ResultHandler.
+func newClusterResultHandler(cluster string, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		return h.HandleWithResult(context.WithValue(ctx, clusterCtxKey{}, cluster), obj)
+	})
+}
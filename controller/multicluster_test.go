@@ -0,0 +1,88 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestMultiClusterGroup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	// Both clusters have an object with the same key.
+	newNs := func(name, cluster string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"cluster": cluster}}}
+	}
+	mcA := fake.NewSimpleClientset(newNs("common", "a"), newNs("only-a", "a"))
+	mcB := fake.NewSimpleClientset(newNs("common", "b"))
+
+	type handling struct {
+		cluster     string
+		name        string
+		objectLabel string
+	}
+	var mu sync.Mutex
+	handled := map[handling]int{}
+	h := controller.HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ns := obj.(*corev1.Namespace)
+		handled[handling{cluster: controller.ClusterFromContext(ctx), name: ns.Name, objectLabel: ns.Labels["cluster"]}]++
+		return nil
+	})
+
+	g, err := controller.NewMultiClusterGroup(&controller.Config{
+		Name:    "test",
+		Handler: h,
+		Logger:  log.Dummy,
+	}, map[string]controller.Retriever{
+		"a": newNamespaceRetriever(mcA),
+		"b": newNamespaceRetriever(mcB),
+	})
+	require.NoError(err)
+	go func() { _ = g.Run(ctx) }()
+
+	// The events of each cluster should reach the handler with their cluster.
+	_, err = mcB.CoreV1().Namespaces().Create(ctx, newNs("only-b", "b"), metav1.CreateOptions{})
+	require.NoError(err)
+
+	exp := map[handling]int{
+		{cluster: "a", name: "common", objectLabel: "a"}: 1,
+		{cluster: "a", name: "only-a", objectLabel: "a"}: 1,
+		{cluster: "b", name: "common", objectLabel: "b"}: 1,
+		{cluster: "b", name: "only-b", objectLabel: "b"}: 1,
+	}
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == len(exp)
+	}, 1*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(exp, handled)
+	assert.Empty(controller.ClusterFromContext(context.Background()))
+}
+
+func TestMultiClusterGroupWithoutClusters(t *testing.T) {
+	_, err := controller.NewMultiClusterGroup(&controller.Config{
+		Name:    "test",
+		Handler: controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+		Logger:  log.Dummy,
+	}, nil)
+	assert.ErrorIs(t, err, controller.ErrControllerNotValid)
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// multiNamespaceRetriever is a Retriever that lists and watches multiple namespaces with a retriever per
+// namespace, merging their objects and events.
+//
+// The resource versions are per namespace retriever, so they are tracked internally and the resource
+// versions requested by the informer are ignored.
+type multiNamespaceRetriever struct {
+	namespaces []string
+	retrievers map[string]Retriever
+
+	mu               sync.Mutex
+	resourceVersions map[string]string
+}
+
+// NewMultiNamespaceRetriever returns a Retriever of the objects of multiple namespaces (not all of them nor
+// a single one), the retriever func returns the retriever of a namespace (e.g: `NewPodRetriever`). The objects
+// and events of all the namespaces are merged, so a single controller handles them.
+//
+// The lists of the namespaces are not paginated. Use a different multi namespace retriever per controller.
+func NewMultiNamespaceRetriever(namespaces []string, retriever func(namespace string) Retriever) (Retriever, error) {
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("at least one namespace is required")
+	}
+
+	if retriever == nil {
+		return nil, fmt.Errorf("namespace retriever func is required")
+	}
+
+	m := &multiNamespaceRetriever{
+		retrievers:       map[string]Retriever{},
+		resourceVersions: map[string]string{},
+	}
+	for _, ns := range namespaces {
+		if _, ok := m.retrievers[ns]; ok {
+			continue
+		}
+		m.namespaces = append(m.namespaces, ns)
+		m.retrievers[ns] = retriever(ns)
+	}
+
+	return m, nil
+}
+
+func (m *multiNamespaceRetriever) List(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+	options.Limit = 0
+	options.Continue = ""
+
+	var list runtime.Object
+	items := []runtime.Object{}
+	versions := map[string]string{}
+	for _, ns := range m.namespaces {
+		obj, err := m.retrievers[ns].List(ctx, options)
+		if err != nil {
+			return nil, fmt.Errorf("could not list %q namespace: %w", ns, err)
+		}
+
+		nsItems, err := meta.ExtractList(obj)
+		if err != nil {
+			return nil, fmt.Errorf("could not extract %q namespace list items: %w", ns, err)
+		}
+		items = append(items, nsItems...)
+
+		listMeta, err := meta.ListAccessor(obj)
+		if err != nil {
+			return nil, fmt.Errorf("could not get %q namespace list metadata: %w", ns, err)
+		}
+		versions[ns] = listMeta.GetResourceVersion()
+
+		// The first list is used as the merged list.
+		if list == nil {
+			list = obj
+		}
+	}
+
+	err := meta.SetList(list, items)
+	if err != nil {
+		return nil, fmt.Errorf("could not set merged list items: %w", err)
+	}
+
+	m.mu.Lock()
+	m.resourceVersions = versions
+	m.mu.Unlock()
+
+	return list, nil
+}
+
+func (m *multiNamespaceRetriever) Watch(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	mw := &multiNamespaceWatcher{
+		resultC: make(chan watch.Event),
+		stopC:   make(chan struct{}),
+	}
+
+	for _, ns := range m.namespaces {
+		nsOptions := options
+		m.mu.Lock()
+		nsOptions.ResourceVersion = m.resourceVersions[ns]
+		m.mu.Unlock()
+
+		w, err := m.retrievers[ns].Watch(ctx, nsOptions)
+		if err != nil {
+			mw.Stop()
+			return nil, fmt.Errorf("could not watch %q namespace: %w", ns, err)
+		}
+		mw.watchers = append(mw.watchers, w)
+	}
+
+	for i, ns := range m.namespaces {
+		mw.wg.Add(1)
+		go m.forward(ns, mw.watchers[i], mw)
+	}
+
+	// Close the merged channel when all the namespace watchers have ended.
+	go func() {
+		mw.wg.Wait()
+		close(mw.resultC)
+	}()
+
+	return mw, nil
+}
+
+// forward sends the events of a namespace watcher to the merged watcher, tracking the namespace resource
+// version. When the namespace watcher ends, the merged watcher is stopped, so the informer watches again.
+func (m *multiNamespaceRetriever) forward(ns string, w watch.Interface, mw *multiNamespaceWatcher) {
+	defer mw.wg.Done()
+	defer mw.Stop()
+
+	for {
+		select {
+		case <-mw.stopC:
+			return
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+
+			if ev.Type != watch.Error {
+				if objMeta, err := meta.Accessor(ev.Object); err == nil {
+					m.mu.Lock()
+					m.resourceVersions[ns] = objMeta.GetResourceVersion()
+					m.mu.Unlock()
+				}
+			}
+
+			select {
+			case mw.resultC <- ev:
+			case <-mw.stopC:
+				return
+			}
+		}
+	}
+}
+
+type multiNamespaceWatcher struct {
+	watchers []watch.Interface
+	resultC  chan watch.Event
+	stopC    chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func (m *multiNamespaceWatcher) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopC)
+		for _, w := range m.watchers {
+			w.Stop()
+		}
+	})
+}
+
+func (m *multiNamespaceWatcher) ResultChan() <-chan watch.Event {
+	return m.resultC
+}
@@ -0,0 +1,95 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestMultiNamespaceRetriever(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	newPod := func(ns, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	mc := fake.NewSimpleClientset(
+		newPod("ns-a", "listed"),
+		newPod("ns-b", "listed"),
+		newPod("ns-c", "listed"),
+		newPod("ns-other", "listed"),
+	)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pod := obj.(*corev1.Pod)
+		handled[pod.Namespace+"/"+pod.Name]++
+		return nil
+	})
+
+	ret, err := controller.NewMultiNamespaceRetriever([]string{"ns-a", "ns-b", "ns-c", "ns-a"}, func(ns string) controller.Retriever {
+		return controller.NewPodRetriever(mc, ns)
+	})
+	require.NoError(err)
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: ret,
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The listed objects of the configured namespaces should be handled.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// The watched objects of the configured namespaces should be handled.
+	for _, ns := range []string{"ns-a", "ns-b", "ns-c", "ns-other"} {
+		_, err := mc.CoreV1().Pods(ns).Create(ctx, newPod(ns, "watched"), metav1.CreateOptions{})
+		require.NoError(err)
+	}
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 6
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Give time to not expected objects to be handled.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	exp := map[string]int{
+		"ns-a/listed":  1,
+		"ns-b/listed":  1,
+		"ns-c/listed":  1,
+		"ns-a/watched": 1,
+		"ns-b/watched": 1,
+		"ns-c/watched": 1,
+	}
+	assert.Equal(exp, handled)
+}
+
+func TestMultiNamespaceRetrieverWithoutNamespaces(t *testing.T) {
+	_, err := controller.NewMultiNamespaceRetriever(nil, func(ns string) controller.Retriever { return nil })
+	assert.Error(t, err)
+}
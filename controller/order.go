@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ObjectLess reports whether the object a should be processed before the object b.
+type ObjectLess func(a, b runtime.Object) bool
+
+// ByCreationTimestamp is an ObjectLess that orders the objects by their creation timestamp, and
+// by their namespace and name when they have been created at the same time.
+var ByCreationTimestamp ObjectLess = func(a, b runtime.Object) bool {
+	aMeta, aErr := meta.Accessor(a)
+	bMeta, bErr := meta.Accessor(b)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+
+	aTime, bTime := aMeta.GetCreationTimestamp(), bMeta.GetCreationTimestamp()
+	if !aTime.Equal(&bTime) {
+		return aTime.Before(&bTime)
+	}
+
+	if aMeta.GetNamespace() != bMeta.GetNamespace() {
+		return aMeta.GetNamespace() < bMeta.GetNamespace()
+	}
+
+	return aMeta.GetName() < bMeta.GetName()
+}
+
+// sortList sorts the items of a list object in place.
+func sortList(list runtime.Object, less ObjectLess) error {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return fmt.Errorf("could not extract list items: %w", err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+
+	err = meta.SetList(list, items)
+	if err != nil {
+		return fmt.Errorf("could not set list items: %w", err)
+	}
+
+	return nil
+}
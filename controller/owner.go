@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ObjectFilter knows if the events of an object should be handled, returning false means that the
+// events of the object will be ignored.
+type ObjectFilter func(obj runtime.Object) bool
+
+// OwnedBy returns an ObjectFilter that only accepts the objects controlled by an owner of the kind (the
+// owner reference with `controller` set), e.g: the pods of a `apps/v1` `ReplicaSet`. The owner API group
+// must match but not the version, so the owner references of other versions are accepted.
+func OwnedBy(apiVersion, kind string) ObjectFilter {
+	group := ""
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+		group = gv.Group
+	}
+
+	return func(obj runtime.Object) bool {
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return false
+		}
+
+		owner := metav1.GetControllerOfNoCopy(objMeta)
+		if owner == nil || owner.Kind != kind {
+			return false
+		}
+
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		return err == nil && gv.Group == group
+	}
+}
+
+// filterAccepts returns true if the informer event object is accepted by the filter, the deleted objects
+// with an unknown final state are checked with their last known state. A nil filter accepts all.
+func filterAccepts(filter ObjectFilter, obj interface{}) bool {
+	if filter == nil {
+		return true
+	}
+
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	rObj, ok := obj.(runtime.Object)
+	return ok && filter(rObj)
+}
@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func newOwnedPod(name string, owners ...metav1.OwnerReference) *corev1.Pod {
+	pod := newPod("ns1", name)
+	pod.OwnerReferences = owners
+	return pod
+}
+
+func TestOwnedBy(t *testing.T) {
+	tests := map[string]struct {
+		obj    runtime.Object
+		expOwn bool
+	}{
+		"An object controlled by the owner kind should be accepted.": {
+			obj:    newOwnedPod("test", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", Controller: pointer.Bool(true)}),
+			expOwn: true,
+		},
+
+		"An object controlled by the owner kind of other version should be accepted.": {
+			obj:    newOwnedPod("test", metav1.OwnerReference{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", Name: "rs", Controller: pointer.Bool(true)}),
+			expOwn: true,
+		},
+
+		"An object owned by the owner kind without being its controller should not be accepted.": {
+			obj:    newOwnedPod("test", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"}),
+			expOwn: false,
+		},
+
+		"An object controlled by other kind should not be accepted.": {
+			obj:    newOwnedPod("test", metav1.OwnerReference{APIVersion: "batch/v1", Kind: "Job", Name: "job", Controller: pointer.Bool(true)}),
+			expOwn: false,
+		},
+
+		"An object controlled by the owner kind of other group should not be accepted.": {
+			obj:    newOwnedPod("test", metav1.OwnerReference{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", Name: "rs", Controller: pointer.Bool(true)}),
+			expOwn: false,
+		},
+
+		"An object without owners should not be accepted.": {
+			obj:    newOwnedPod("test"),
+			expOwn: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			filter := controller.OwnedBy("apps/v1", "ReplicaSet")
+			assert.Equal(test.expOwn, filter(test.obj))
+		})
+	}
+}
+
+func TestGenericControllerObjectFilter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	rsOwner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", Controller: pointer.Bool(true)}
+	jobOwner := metav1.OwnerReference{APIVersion: "batch/v1", Kind: "Job", Name: "job", Controller: pointer.Bool(true)}
+	mc := fake.NewSimpleClientset(
+		newOwnedPod("owned-0", rsOwner),
+		newOwnedPod("unowned-0"),
+		newOwnedPod("other-owner-0", jobOwner),
+	)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[obj.(*corev1.Pod).Name]++
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:         "test",
+		Handler:      h,
+		Retriever:    newPodRetriever(mc),
+		ObjectFilter: controller.OwnedBy("apps/v1", "ReplicaSet"),
+		Logger:       log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+	<-c.(controller.SyncNotifier).Synced()
+
+	// The watched objects should be filtered too.
+	for _, pod := range []*corev1.Pod{newOwnedPod("owned-1", rsOwner), newOwnedPod("unowned-1")} {
+		_, err := mc.CoreV1().Pods("ns1").Create(ctx, pod, metav1.CreateOptions{})
+		require.NoError(err)
+	}
+
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["owned-0"] > 0 && handled["owned-1"] > 0
+	}, 1*time.Second, 5*time.Millisecond)
+
+	// Only the owned objects should reach the handler.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"owned-0": 1, "owned-1": 1}, handled)
+}
@@ -0,0 +1,79 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerMaxHandlerPanics(t *testing.T) {
+	const maxPanics = 3
+
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	nsList, _ := createNamespaceList("testing", 3)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	// The handling of one of the objects always panics.
+	var mu sync.Mutex
+	handled := map[string]int{}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		name := obj.(*corev1.Namespace).Name
+		mu.Lock()
+		handled[name]++
+		mu.Unlock()
+		if name == "testing-1" {
+			panic("wanted panic")
+		}
+		return nil
+	})
+
+	droppedC := make(chan controller.Event, 1)
+	c, err := controller.New(&controller.Config{
+		Name:                 "test",
+		Handler:              h,
+		Retriever:            newNamespaceRetriever(mc),
+		RecoverHandlerPanics: true,
+		MaxHandlerPanics:     maxPanics,
+		ProcessingJobRetries: 10,
+		RetryRateLimiter:     workqueue.NewItemFastSlowRateLimiter(time.Millisecond, time.Millisecond, 10),
+		OnEvent: func(e controller.Event) {
+			if e.Type == controller.EventForgotten && errors.Is(e.Err, controller.ErrMaxHandlerPanicsReached) {
+				droppedC <- e
+			}
+		},
+		Logger: log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// The panicking object should be dropped after the max panics.
+	select {
+	case e := <-droppedC:
+		assert.Equal("testing-1", e.Key)
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for the object to be dropped")
+	}
+
+	// Wait to check the dropped object is not retried.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"testing-0": 1, "testing-1": maxPanics, "testing-2": 1}, handled)
+}
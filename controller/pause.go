@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// namespacePauser knows how to hold the processing of the objects of paused namespaces.
+// The held object keys are not dropped, they will be queued again when the namespace
+// is resumed.
+type namespacePauser struct {
+	mu     sync.Mutex
+	paused map[string]map[string]struct{} // Paused namespaces with their held object keys.
+	queue  blockingQueue
+	logger log.Logger
+}
+
+func newNamespacePauser(queue blockingQueue, logger log.Logger) *namespacePauser {
+	return &namespacePauser{
+		paused: map[string]map[string]struct{}{},
+		queue:  queue,
+		logger: logger,
+	}
+}
+
+// Pause pauses the processing of the objects of a namespace.
+func (n *namespacePauser) Pause(namespace string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.paused[namespace]; !ok {
+		n.paused[namespace] = map[string]struct{}{}
+	}
+}
+
+// Resume resumes the processing of the objects of a namespace and queues
+// again the objects that have been held while the namespace was paused.
+func (n *namespacePauser) Resume(ctx context.Context, namespace string) {
+	n.mu.Lock()
+	held := n.paused[namespace]
+	delete(n.paused, namespace)
+	n.mu.Unlock()
+
+	for key := range held {
+		n.queue.Add(ctx, key)
+	}
+}
+
+// hold will hold the key if the object namespace is paused and return true
+// if the key has been held.
+func (n *namespacePauser) hold(key string) bool {
+	namespace, _, err := SplitKey(key)
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	held, ok := n.paused[namespace]
+	if !ok {
+		return false
+	}
+	held[key] = struct{}{}
+
+	return true
+}
+
+// newPauseProcessor returns a processor that will not delegate the processing of the keys
+// that belong to paused namespaces, instead these will be held until the namespace is resumed.
+func newPauseProcessor(pauser *namespacePauser, logger log.Logger, next processor) processor {
+	return processorFunc(func(ctx context.Context, key string) error {
+		if pauser.hold(key) {
+			logger.WithKV(log.KV{"object-key": key}).Debugf("object namespace paused, holding object")
+			return nil
+		}
+
+		return next.Process(ctx, key)
+	})
+}
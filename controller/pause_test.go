@@ -0,0 +1,67 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerPauseNamespace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mc := fake.NewSimpleClientset(
+		newPod("ns1", "pod1"),
+		newPod("ns1", "pod2"),
+		newPod("ns2", "pod1"),
+		newPod("ns2", "pod2"),
+	)
+
+	var mu sync.Mutex
+	handled := []string{}
+	getHandled := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, handled...)
+	}
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pod := obj.(*corev1.Pod)
+		handled = append(handled, pod.Namespace+"/"+pod.Name)
+		return nil
+	})
+
+	c, err := controller.New(&controller.Config{
+		Name:      "test",
+		Handler:   h,
+		Retriever: newPodRetriever(mc),
+		Logger:    log.Dummy,
+	})
+	require.NoError(err)
+
+	// Pause before running so the paused namespace objects are never handled.
+	c.(controller.Pauser).PauseNamespace("ns1")
+	go func() { _ = c.Run(ctx) }()
+
+	require.Eventually(func() bool { return len(getHandled()) >= 2 }, 1*time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch([]string{"ns2/pod1", "ns2/pod2"}, getHandled())
+
+	// Resuming should handle the held objects.
+	c.(controller.Pauser).ResumeNamespace("ns1")
+	require.Eventually(func() bool { return len(getHandled()) >= 4 }, 1*time.Second, 5*time.Millisecond)
+	assert.ElementsMatch([]string{"ns2/pod1", "ns2/pod2", "ns1/pod1", "ns1/pod2"}, getHandled())
+}
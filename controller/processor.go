@@ -2,9 +2,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/cache"
 
@@ -24,7 +28,12 @@ func (p processorFunc) Process(ctx context.Context, key string) error { return p
 // newIndexerProcessor returns a processor that processes a key that will get the kubernetes object
 // from a cache called indexer were the kubernetes watch updates have been indexed and stored
 // by the listerwatchers from the informers.
-func newIndexerProcessor(indexer cache.Indexer, handler Handler) processor {
+//
+// The result of the handler will be applied after a successful handling (e.g: requeue the object).
+//
+// If the object doesn't exist and there is a delete handler, the last known state of the deleted
+// object will be handled by the delete handler.
+func newIndexerProcessor(indexer cache.Indexer, queue blockingQueue, enqueue func(key string), scheme *runtime.Scheme, handler ResultHandler, deleted *deletedObjectsCache, deleteHandler Handler, stopped *stoppedResyncs) processor {
 	return processorFunc(func(ctx context.Context, key string) error {
 		// Get the object
 		obj, exists, err := indexer.GetByKey(key)
@@ -33,10 +42,157 @@ func newIndexerProcessor(indexer cache.Indexer, handler Handler) processor {
 		}
 
 		if !exists {
+			if deleteHandler == nil {
+				return nil
+			}
+
+			// If we don't have the last known state, handle only with the object key information.
+			var err error
+			if obj, ok := deleted.get(key); ok {
+				err = deleteHandler.Handle(contextWithGVK(ctx, scheme, obj), obj)
+			} else {
+				err = handleDeletedKey(ctx, deleteHandler, key)
+			}
+			if err != nil {
+				return err
+			}
+			deleted.evict(key)
+
+			return nil
+		}
+
+		// The object could have been created again after being deleted.
+		deleted.evict(key)
+
+		rtObj := obj.(runtime.Object)
+		res, err := handler.HandleWithResult(contextWithGVK(ctx, scheme, rtObj), rtObj)
+		if err != nil {
+			return err
+		}
+
+		stopped.set(key, res.StopResync)
+
+		if res.RequeueAfter > 0 {
+			queue.AddAfter(ctx, key, res.RequeueAfter)
+		}
+
+		for _, k := range res.EnqueueKeys {
+			enqueue(k)
+		}
+
+		return nil
+	})
+}
+
+// FaultInjector returns an error to simulate a handling failure of an object key, nil otherwise.
+type FaultInjector func(ctx context.Context, key string) error
+
+// newFaultInjectorProcessor returns a processor that fails the processing of the keys with the
+// errors of the fault injector without calling the next processor.
+func newFaultInjectorProcessor(injector FaultInjector, next processor) processor {
+	return processorFunc(func(ctx context.Context, key string) error {
+		err := injector(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		return next.Process(ctx, key)
+	})
+}
+
+// newCancelableProcessor returns a processor that will delegate the processing of a key to the
+// received processor with a context that the canceler can cancel (e.g: when the object is deleted).
+// Only the wrapped processors receive the cancelable context, so the outer processors can finish
+// their work (e.g: release the object lock).
+func newCancelableProcessor(canceler *processingCanceler, next processor) processor {
+	return processorFunc(func(ctx context.Context, key string) error {
+		ctx, cancel := canceler.track(ctx, key)
+		defer cancel()
+
+		return next.Process(ctx, key)
+	})
+}
+
+// ErrMaxHandlerPanicsReached is the error returned when an object has been dropped because its
+// handling panicked the maximum number of times, the object will not be retried.
+var ErrMaxHandlerPanicsReached = fmt.Errorf("max handler panics reached")
+
+// newPanicRecoverProcessor returns a processor that will recover the panics of the received processor
+// and return them as errors. The panics are counted per key, when the count reaches the max panics the
+// processing will return `ErrMaxHandlerPanicsReached` so the key is not retried. A successful processing
+// resets the count of the key.
+func newPanicRecoverProcessor(maxPanics int, next processor) processor {
+	var mu sync.Mutex
+	panics := map[string]int{}
+
+	return processorFunc(func(ctx context.Context, key string) (err error) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				if err == nil {
+					mu.Lock()
+					delete(panics, key)
+					mu.Unlock()
+				}
+				return
+			}
+
+			mu.Lock()
+			panics[key]++
+			reached := panics[key] >= maxPanics
+			mu.Unlock()
+
+			err = fmt.Errorf("handler panic: %v", r)
+			if reached {
+				err = fmt.Errorf("%w: %s", ErrMaxHandlerPanicsReached, err)
+			}
+		}()
+
+		return next.Process(ctx, key)
+	})
+}
+
+// handleDeletedKey handles a deleted object without its last known state, using an object
+// that only has the namespace and name of the key.
+func handleDeletedKey(ctx context.Context, deleteHandler Handler, key string) error {
+	ns, name, err := SplitKey(key)
+	if err != nil {
+		return err
+	}
+
+	return deleteHandler.Handle(ctx, &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+		Namespace: ns,
+		Name:      name,
+	}})
+}
+
+// newObjectLockProcessor returns a processor that will only delegate the processing of a key
+// to the received processor if the lock of the object can be acquired, the lock will be released
+// after processing it. If the lock is held by someone else the key will be queued again after the
+// retry interval.
+func newObjectLockProcessor(locker ObjectLocker, queue blockingQueue, retryInterval time.Duration, logger log.Logger, next processor) processor {
+	return processorFunc(func(ctx context.Context, key string) error {
+		logger := logger.WithKV(log.KV{"object-key": key})
+
+		acquired, err := locker.Lock(ctx, key)
+		if err != nil {
+			return fmt.Errorf("could not acquire object lock: %w", err)
+		}
+
+		if !acquired {
+			logger.Debugf("object lock held by someone else, retrying in %s", retryInterval)
+			queue.AddAfter(ctx, key, retryInterval)
 			return nil
 		}
 
-		return handler.Handle(ctx, obj.(runtime.Object))
+		defer func() {
+			err := locker.Unlock(ctx, key)
+			if err != nil {
+				logger.Warningf("could not release object lock: %s", err)
+			}
+		}()
+
+		return next.Process(ctx, key)
 	})
 }
 
@@ -47,16 +203,29 @@ var errRequeued = fmt.Errorf("requeued after receiving error")
 // again to a queue if it has retrys pending.
 //
 // If the processing errored and has been retried, it will return a `errRequeued` error.
-func newRetryProcessor(name string, queue blockingQueue, logger log.Logger, next processor) processor {
+//
+// If forgetOnNotFound is set, the Kubernetes not found errors will not be retried. The retries are
+// logged with the log level.
+func newRetryProcessor(name string, queue blockingQueue, logger log.Logger, logLevel log.Level, events *eventNotifier, forgetOnNotFound bool, next processor) processor {
 	return processorFunc(func(ctx context.Context, key string) error {
 		err := next.Process(ctx, key)
+		if errors.Is(err, ErrMaxHandlerPanicsReached) {
+			return err
+		}
+
+		if forgetOnNotFound && apierrors.IsNotFound(err) {
+			return err
+		}
+
 		if err != nil {
 			// Retry if possible.
 			requeueErr := queue.Requeue(ctx, key)
 			if requeueErr != nil {
 				return fmt.Errorf("could not retry: %s: %w", requeueErr, err)
 			}
-			logger.WithKV(log.KV{"object-key": key}).Warningf("item requeued due to processing error: %s", err)
+			kv := log.KV{"object-key": key, "request-id": RequestIDFromContext(ctx)}
+			log.Logf(logger.WithKV(kv), logLevel, "item requeued due to processing error: %s", err)
+			events.notify(EventRetried, key, err)
 			return nil
 		}
 
@@ -64,6 +233,47 @@ func newRetryProcessor(name string, queue blockingQueue, logger log.Logger, next
 	})
 }
 
+// RetryPolicy decides if a failed object will be retried based on the error and the number of consecutive
+// failed attempts (1 on the first failure). The object will be queued again after the returned duration
+// (0 is immediately).
+type RetryPolicy func(err error, attempt int) (retry bool, after time.Duration)
+
+// newRetryPolicyProcessor is like newRetryProcessor but the retries are decided by the retry policy. The
+// attempts of an object are reset when it's processed successfully or it's not retried anymore.
+func newRetryPolicyProcessor(queue blockingQueue, policy RetryPolicy, logger log.Logger, logLevel log.Level, events *eventNotifier, next processor) processor {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+
+	return processorFunc(func(ctx context.Context, key string) error {
+		err := next.Process(ctx, key)
+
+		mu.Lock()
+		if err == nil || errors.Is(err, ErrMaxHandlerPanicsReached) {
+			delete(attempts, key)
+			mu.Unlock()
+			return err
+		}
+		attempts[key]++
+		attempt := attempts[key]
+		retry, after := policy(err, attempt)
+		if !retry {
+			delete(attempts, key)
+		}
+		mu.Unlock()
+
+		if !retry {
+			return err
+		}
+
+		queue.RequeueAfter(ctx, key, after)
+		kv := log.KV{"object-key": key, "request-id": RequestIDFromContext(ctx)}
+		log.Logf(logger.WithKV(kv), logLevel, "item requeued due to processing error (attempt %d): %s", attempt, err)
+		events.notify(EventRetried, key, err)
+
+		return nil
+	})
+}
+
 // newMetricsProcessor returns a processor that measures everything related with the processing logic.
 func newMetricsProcessor(name string, mrec MetricsRecorder, next processor) processor {
 	return processorFunc(func(ctx context.Context, key string) (err error) {
@@ -74,3 +284,54 @@ func newMetricsProcessor(name string, mrec MetricsRecorder, next processor) proc
 		return next.Process(ctx, key)
 	})
 }
+
+// newErrorMetricsProcessor returns a processor that measures the categories of the processing errors. If
+// distinct is set, the same consecutive errors of a key are measured once.
+func newErrorMetricsProcessor(name string, mrec ErrorMetricsRecorder, classifier ErrorClassifier, distinct bool, next processor) processor {
+	var mu sync.Mutex
+	lastErrs := map[string]string{}
+
+	// isRepeated returns true if the error is the same as the last error of the key, tracking it.
+	isRepeated := func(key string, err error) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err == nil {
+			delete(lastErrs, key)
+			return false
+		}
+
+		last, ok := lastErrs[key]
+		lastErrs[key] = err.Error()
+		return ok && last == err.Error()
+	}
+
+	return processorFunc(func(ctx context.Context, key string) error {
+		err := next.Process(ctx, key)
+		if distinct && isRepeated(key, err) {
+			return err
+		}
+
+		if err != nil {
+			mrec.IncResourceProcessingError(ctx, name, classifier(err))
+		}
+
+		return err
+	})
+}
+
+// newEventsProcessor returns a processor that notifies the processing lifecycle events.
+func newEventsProcessor(events *eventNotifier, next processor) processor {
+	return processorFunc(func(ctx context.Context, key string) error {
+		events.notify(EventStarted, key, nil)
+
+		err := next.Process(ctx, key)
+		if err != nil {
+			events.notify(EventFailed, key, err)
+			return err
+		}
+		events.notify(EventSucceeded, key, nil)
+
+		return nil
+	})
+}
@@ -3,10 +3,12 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 
 	"github.com/spotahome/kooper/v2/log"
 )
@@ -16,10 +18,15 @@ import (
 type blockingQueue interface {
 	// Add will add an item to the queue.
 	Add(ctx context.Context, item interface{})
+	// AddAfter will add an item to the queue after the duration.
+	AddAfter(ctx context.Context, item interface{}, duration time.Duration)
 	// Requeue will add an item to the queue in a requeue mode.
 	// If doesn't accept requeueing or max requeue have been reached
 	// it will return an error.
 	Requeue(ctx context.Context, item interface{}) error
+	// RequeueAfter will add an item to the queue in a requeue mode after the duration, without
+	// the requeue rate limit nor the max requeues (e.g: the retries of a `RetryPolicy`).
+	RequeueAfter(ctx context.Context, item interface{}, duration time.Duration)
 	// Get is a blocking operation, if the last object usage has not been finished (`done`)
 	// being used it will block until this has been done.
 	Get(ctx context.Context) (item interface{}, shutdown bool)
@@ -51,6 +58,10 @@ func (r rateLimitingBlockingQueue) Add(_ context.Context, item interface{}) {
 	r.queue.Add(item)
 }
 
+func (r rateLimitingBlockingQueue) AddAfter(_ context.Context, item interface{}, duration time.Duration) {
+	r.queue.AddAfter(item, duration)
+}
+
 func (r rateLimitingBlockingQueue) Requeue(_ context.Context, item interface{}) error {
 	// If there was an error and we have retries pending then requeue.
 	if r.queue.NumRequeues(item) < r.maxRetries {
@@ -62,6 +73,14 @@ func (r rateLimitingBlockingQueue) Requeue(_ context.Context, item interface{})
 	return errMaxRetriesReached
 }
 
+func (r rateLimitingBlockingQueue) RequeueAfter(_ context.Context, item interface{}, duration time.Duration) {
+	if duration > 0 {
+		r.queue.AddAfter(item, duration)
+	} else {
+		r.queue.Add(item)
+	}
+}
+
 func (r rateLimitingBlockingQueue) Get(_ context.Context) (item interface{}, shutdown bool) {
 	return r.queue.Get()
 }
@@ -83,12 +102,13 @@ type metricsBlockingQueue struct {
 	mu            sync.Mutex
 	name          string
 	mrec          MetricsRecorder
+	clock         clock.PassiveClock
 	itemsQueuedAt map[interface{}]time.Time
 	logger        log.Logger
 	queue         blockingQueue
 }
 
-func newMetricsBlockingQueue(name string, mrec MetricsRecorder, queue blockingQueue, logger log.Logger) (blockingQueue, error) {
+func newMetricsBlockingQueue(name string, mrec MetricsRecorder, clk clock.PassiveClock, queue blockingQueue, logger log.Logger) (blockingQueue, error) {
 	// Register func/callback based metrics. These are controlled by the MetricsRecorder.
 	err := mrec.RegisterResourceQueueLengthFunc(name, func(ctx context.Context) int { return queue.Len(ctx) })
 	if err != nil {
@@ -98,6 +118,7 @@ func newMetricsBlockingQueue(name string, mrec MetricsRecorder, queue blockingQu
 	return &metricsBlockingQueue{
 		name:          name,
 		mrec:          mrec,
+		clock:         clk,
 		itemsQueuedAt: map[interface{}]time.Time{},
 		logger:        logger,
 		queue:         queue,
@@ -107,7 +128,7 @@ func newMetricsBlockingQueue(name string, mrec MetricsRecorder, queue blockingQu
 func (m *metricsBlockingQueue) Add(ctx context.Context, item interface{}) {
 	m.mu.Lock()
 	if _, ok := m.itemsQueuedAt[item]; !ok {
-		m.itemsQueuedAt[item] = time.Now()
+		m.itemsQueuedAt[item] = m.clock.Now()
 	}
 	m.mu.Unlock()
 
@@ -115,10 +136,22 @@ func (m *metricsBlockingQueue) Add(ctx context.Context, item interface{}) {
 	m.queue.Add(ctx, item)
 }
 
+func (m *metricsBlockingQueue) AddAfter(ctx context.Context, item interface{}, duration time.Duration) {
+	// The item will be in the queue after the duration.
+	m.mu.Lock()
+	if _, ok := m.itemsQueuedAt[item]; !ok {
+		m.itemsQueuedAt[item] = m.clock.Now().Add(duration)
+	}
+	m.mu.Unlock()
+
+	m.mrec.IncResourceEventQueued(ctx, m.name, true)
+	m.queue.AddAfter(ctx, item, duration)
+}
+
 func (m *metricsBlockingQueue) Requeue(ctx context.Context, item interface{}) error {
 	m.mu.Lock()
 	if _, ok := m.itemsQueuedAt[item]; !ok {
-		m.itemsQueuedAt[item] = time.Now()
+		m.itemsQueuedAt[item] = m.clock.Now()
 	}
 	m.mu.Unlock()
 
@@ -126,6 +159,17 @@ func (m *metricsBlockingQueue) Requeue(ctx context.Context, item interface{}) er
 	return m.queue.Requeue(ctx, item)
 }
 
+func (m *metricsBlockingQueue) RequeueAfter(ctx context.Context, item interface{}, duration time.Duration) {
+	m.mu.Lock()
+	if _, ok := m.itemsQueuedAt[item]; !ok {
+		m.itemsQueuedAt[item] = m.clock.Now().Add(duration)
+	}
+	m.mu.Unlock()
+
+	m.mrec.IncResourceEventQueued(ctx, m.name, true)
+	m.queue.RequeueAfter(ctx, item, duration)
+}
+
 func (m *metricsBlockingQueue) Get(ctx context.Context) (interface{}, bool) {
 	// Here should get blocked, warning with the mutexes.
 	item, shutdown := m.queue.Get(ctx)
@@ -160,3 +204,184 @@ func (m *metricsBlockingQueue) Len(ctx context.Context) int {
 	// mode, should be already registered, check factory. This is NOOP.
 	return m.queue.Len(ctx)
 }
+
+// KeyStatus is the processing status of an object key on the controller.
+type KeyStatus string
+
+const (
+	// KeyStatusNotPresent is the status of the keys unknown by the controller.
+	KeyStatusNotPresent KeyStatus = "not-present"
+	// KeyStatusQueued is the status of the keys waiting in the queue to be processed.
+	KeyStatusQueued KeyStatus = "queued"
+	// KeyStatusProcessing is the status of the keys that are being processed.
+	KeyStatusProcessing KeyStatus = "processing"
+	// KeyStatusFailing is the status of the keys that failed on the last processing and
+	// are waiting to be retried.
+	KeyStatusFailing KeyStatus = "failing"
+)
+
+// QueueItem is the state of an object key on the controller queue.
+type QueueItem struct {
+	// Key is the object key.
+	Key string
+	// Requeues is the number of times the key has been requeued after failing.
+	Requeues int
+	// Status is the processing status of the key.
+	Status KeyStatus
+}
+
+type trackedItem struct {
+	queued     bool
+	processing bool
+	failing    bool
+	requeues   int
+}
+
+// status returns the processing status of the tracked item.
+func (t *trackedItem) status() KeyStatus {
+	switch {
+	case t.processing:
+		return KeyStatusProcessing
+	case t.failing:
+		return KeyStatusFailing
+	case t.queued:
+		return KeyStatusQueued
+	}
+
+	return KeyStatusNotPresent
+}
+
+// trackingBlockingQueue is a wrapper for a queue that tracks the status of the items.
+type trackingBlockingQueue struct {
+	mu    sync.Mutex
+	items map[interface{}]*trackedItem
+	queue blockingQueue
+}
+
+func newTrackingBlockingQueue(queue blockingQueue) *trackingBlockingQueue {
+	return &trackingBlockingQueue{
+		items: map[interface{}]*trackedItem{},
+		queue: queue,
+	}
+}
+
+// Status returns the status of an item.
+func (t *trackingBlockingQueue) Status(item interface{}) KeyStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ti, ok := t.items[item]
+	if !ok {
+		return KeyStatusNotPresent
+	}
+
+	return ti.status()
+}
+
+// Snapshot returns the state of the tracked items sorted by key.
+func (t *trackingBlockingQueue) Snapshot() []QueueItem {
+	t.mu.Lock()
+	items := make([]QueueItem, 0, len(t.items))
+	for item, ti := range t.items {
+		status := ti.status()
+		if status == KeyStatusNotPresent {
+			continue
+		}
+		key, _ := item.(string)
+		items = append(items, QueueItem{Key: key, Requeues: ti.requeues, Status: status})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+
+	return items
+}
+
+// item returns the tracked item, must be called with the lock acquired.
+func (t *trackingBlockingQueue) item(item interface{}) *trackedItem {
+	ti, ok := t.items[item]
+	if !ok {
+		ti = &trackedItem{}
+		t.items[item] = ti
+	}
+	return ti
+}
+
+func (t *trackingBlockingQueue) Add(ctx context.Context, item interface{}) {
+	t.mu.Lock()
+	t.item(item).queued = true
+	t.mu.Unlock()
+
+	t.queue.Add(ctx, item)
+}
+
+func (t *trackingBlockingQueue) AddAfter(ctx context.Context, item interface{}, duration time.Duration) {
+	t.mu.Lock()
+	t.item(item).queued = true
+	t.mu.Unlock()
+
+	t.queue.AddAfter(ctx, item, duration)
+}
+
+func (t *trackingBlockingQueue) Requeue(ctx context.Context, item interface{}) error {
+	err := t.queue.Requeue(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	ti := t.item(item)
+	ti.queued = true
+	ti.failing = true
+	ti.requeues++
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *trackingBlockingQueue) RequeueAfter(ctx context.Context, item interface{}, duration time.Duration) {
+	t.mu.Lock()
+	ti := t.item(item)
+	ti.queued = true
+	ti.failing = true
+	ti.requeues++
+	t.mu.Unlock()
+
+	t.queue.RequeueAfter(ctx, item, duration)
+}
+
+func (t *trackingBlockingQueue) Get(ctx context.Context) (interface{}, bool) {
+	item, shutdown := t.queue.Get(ctx)
+	if shutdown {
+		return item, shutdown
+	}
+
+	t.mu.Lock()
+	ti := t.item(item)
+	ti.queued = false
+	ti.failing = false
+	ti.processing = true
+	t.mu.Unlock()
+
+	return item, shutdown
+}
+
+func (t *trackingBlockingQueue) Done(ctx context.Context, item interface{}) {
+	t.mu.Lock()
+	ti := t.item(item)
+	ti.processing = false
+	if !ti.queued && !ti.failing {
+		delete(t.items, item)
+	}
+	t.mu.Unlock()
+
+	t.queue.Done(ctx, item)
+}
+
+func (t *trackingBlockingQueue) ShutDown(ctx context.Context) {
+	t.queue.ShutDown(ctx)
+}
+
+func (t *trackingBlockingQueue) Len(ctx context.Context) int {
+	return t.queue.Len(ctx)
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// fullJitterRateLimiter is an exponential backoff rate limiter with full jitter, the delay
+// is a random duration between 0 and the exponential backoff. This spreads the retries of
+// the items failing at the same time (e.g: a dependency recovering).
+type fullJitterRateLimiter struct {
+	mu        sync.Mutex
+	failures  map[interface{}]int
+	rand      *rand.Rand
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+// NewFullJitterRateLimiter returns a per item exponential backoff rate limiter with full jitter
+// that can be used as the controller `RetryRateLimiter`. The backoff of each retry will be a
+// random duration between 0 and `baseDelay*2^retries` (capped to `maxDelay`).
+//
+// The random source can be set for deterministic delays (e.g: tests), if nil a time seeded
+// source will be used.
+func NewFullJitterRateLimiter(baseDelay, maxDelay time.Duration, rnd *rand.Rand) workqueue.RateLimiter {
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return &fullJitterRateLimiter{
+		failures:  map[interface{}]int{},
+		rand:      rnd,
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+	}
+}
+
+func (f *fullJitterRateLimiter) When(item interface{}) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exp := f.failures[item]
+	f.failures[item]++
+
+	backoff := float64(f.baseDelay.Nanoseconds()) * math.Pow(2, float64(exp))
+	if backoff > float64(f.maxDelay.Nanoseconds()) {
+		backoff = float64(f.maxDelay.Nanoseconds())
+	}
+	if backoff < 1 {
+		return 0
+	}
+
+	return time.Duration(f.rand.Int63n(int64(backoff) + 1))
+}
+
+func (f *fullJitterRateLimiter) NumRequeues(item interface{}) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failures[item]
+}
+
+func (f *fullJitterRateLimiter) Forget(item interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failures, item)
+}
@@ -0,0 +1,73 @@
+package controller_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+func TestFullJitterRateLimiter(t *testing.T) {
+	const (
+		baseDelay = 10 * time.Millisecond
+		maxDelay  = 1 * time.Second
+		retries   = 20
+	)
+
+	tests := map[string]struct {
+		items []string
+	}{
+		"A single item retried multiple times should have varied delays within the backoff bounds.": {
+			items: []string{"test"},
+		},
+
+		"Multiple items failing at the same time should have varied delays within the backoff bounds.": {
+			items: []string{"test-0", "test-1", "test-2", "test-3"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rl := controller.NewFullJitterRateLimiter(baseDelay, maxDelay, rand.New(rand.NewSource(42)))
+
+			delays := map[time.Duration]bool{}
+			for i := 0; i < retries; i++ {
+				for _, item := range test.items {
+					d := rl.When(item)
+
+					// The delay should be between 0 and the exponential backoff (capped).
+					backoff := baseDelay * time.Duration(1<<uint(i))
+					if backoff > maxDelay {
+						backoff = maxDelay
+					}
+					assert.GreaterOrEqual(d, time.Duration(0))
+					assert.LessOrEqual(d, backoff)
+					delays[d] = true
+				}
+			}
+
+			// The delays should not be synchronized.
+			assert.Greater(len(delays), retries*len(test.items)/2)
+			for _, item := range test.items {
+				assert.Equal(retries, rl.NumRequeues(item))
+				rl.Forget(item)
+				assert.Equal(0, rl.NumRequeues(item))
+			}
+		})
+	}
+}
+
+func TestFullJitterRateLimiterSeeded(t *testing.T) {
+	rl1 := controller.NewFullJitterRateLimiter(10*time.Millisecond, time.Second, rand.New(rand.NewSource(42)))
+	rl2 := controller.NewFullJitterRateLimiter(10*time.Millisecond, time.Second, rand.New(rand.NewSource(42)))
+
+	// Same random source should return the same delays.
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, rl1.When("test"), rl2.When("test"))
+	}
+}
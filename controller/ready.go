@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// runReadyAfterKey queues the ready key periodically until it's handled successfully, then the
+// controller is marked as ready.
+func (g *generic) runReadyAfterKey(ctx context.Context) {
+	key := g.cfg.ReadyAfterKey
+
+	// Subscribe before queueing the key so the handling is not missed.
+	doneC := make(chan struct{})
+	var once sync.Once
+	unsubscribe := g.events.subscribe(func(ev Event) {
+		if ev.Key == key && ev.Type == EventSucceeded {
+			once.Do(func() { close(doneC) })
+		}
+	})
+	defer unsubscribe()
+
+	ticker := time.NewTicker(g.cfg.ReadyAfterKeyInterval)
+	defer ticker.Stop()
+	for {
+		g.queue.Add(ctx, key)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-doneC:
+			g.logger.Infof("ready key %q handled, controller is ready", key)
+			g.setReady()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setReady marks the controller as ready.
+func (g *generic) setReady() {
+	g.readyOnce.Do(func() { close(g.readyC) })
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// ObjectVersion returns the version of an object, two objects with the same version are considered
+// equal (e.g: a hash of the spec).
+type ObjectVersion func(obj runtime.Object) string
+
+// ResourceVersion is an ObjectVersion that uses the Kubernetes resource version of the objects.
+var ResourceVersion ObjectVersion = func(obj runtime.Object) string {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return objMeta.GetResourceVersion()
+}
+
+// Generation is an ObjectVersion that uses the Kubernetes generation of the objects, so only the
+// changes of the spec are considered. The objects without generation don't have a version.
+var Generation ObjectVersion = func(obj runtime.Object) string {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil || objMeta.GetGeneration() == 0 {
+		return ""
+	}
+	return strconv.FormatInt(objMeta.GetGeneration(), 10)
+}
+
+// ReconciledStore stores the versions of the last successfully handled state of the objects by key.
+// A persistent store (e.g: `NewConfigMapReconciledStore`) lets the controller skip the unchanged
+// objects after a restart (`Config.ReconciledStore`).
+type ReconciledStore interface {
+	// Get returns the stored version of an object key, false if it's not stored.
+	Get(ctx context.Context, key string) (version string, ok bool, err error)
+	// Set stores the version of an object key.
+	Set(ctx context.Context, key, version string) error
+	// Delete removes the stored version of an object key.
+	Delete(ctx context.Context, key string) error
+}
+
+// BufferedReconciledStore is an optional interface of the ReconciledStores that keep the versions in
+// memory and persist the changes in batches (e.g: `NewConfigMapReconciledStore`), so the handlings
+// don't wait for the persistence. The controller loads the store when it starts running and flushes
+// it periodically (`Config.ReconciledStoreFlushInterval`) and once stopped.
+type BufferedReconciledStore interface {
+	ReconciledStore
+	// Load loads the persisted versions.
+	Load(ctx context.Context) error
+	// Flush persists the changes that have not been persisted.
+	Flush(ctx context.Context) error
+}
+
+type memoryReconciledStore struct {
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// NewMemoryReconciledStore returns a ReconciledStore that stores the versions in memory.
+func NewMemoryReconciledStore() ReconciledStore {
+	return &memoryReconciledStore{versions: map[string]string{}}
+}
+
+func (m *memoryReconciledStore) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.versions[key]
+	return v, ok, nil
+}
+
+func (m *memoryReconciledStore) Set(_ context.Context, key, version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.versions[key] = version
+	return nil
+}
+
+func (m *memoryReconciledStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.versions, key)
+	return nil
+}
+
+// reconciledVersions stores the version of the last successfully handled state of the objects.
+//
+// A nil reconciledVersions is valid and will not store anything.
+type reconciledVersions struct {
+	version ObjectVersion
+	store   ReconciledStore
+	logger  log.Logger
+
+	// skipFirstUnchanged skips the first handling of the objects (e.g: after a restart) when their
+	// version matches the stored one.
+	skipFirstUnchanged bool
+	mu                 sync.Mutex
+	seen               map[string]bool
+}
+
+func newReconciledVersions(version ObjectVersion, store ReconciledStore, skipFirstUnchanged bool, logger log.Logger) *reconciledVersions {
+	return &reconciledVersions{
+		version:            version,
+		store:              store,
+		logger:             logger,
+		skipFirstUnchanged: skipFirstUnchanged,
+		seen:               map[string]bool{},
+	}
+}
+
+// set stores the version of the handled object.
+func (r *reconciledVersions) set(ctx context.Context, key string, obj runtime.Object) {
+	if r == nil {
+		return
+	}
+
+	err := r.store.Set(ctx, key, r.version(obj))
+	if err != nil {
+		r.logger.Warningf("could not store %s reconciled version: %s", key, err)
+	}
+}
+
+// matches returns true if the object version is the same as the last handled one.
+func (r *reconciledVersions) matches(ctx context.Context, key string, obj runtime.Object) bool {
+	if r == nil {
+		return false
+	}
+
+	v := r.version(obj)
+	if v == "" {
+		return false
+	}
+
+	last, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		r.logger.Warningf("could not get %s reconciled version: %s", key, err)
+		return false
+	}
+
+	return ok && last == v
+}
+
+// firstMatches returns true if it's the first time the object is checked and its version is the same
+// as the last handled one.
+func (r *reconciledVersions) firstMatches(ctx context.Context, key string, obj runtime.Object) bool {
+	if r == nil || !r.skipFirstUnchanged {
+		return false
+	}
+
+	r.mu.Lock()
+	seen := r.seen[key]
+	r.seen[key] = true
+	r.mu.Unlock()
+
+	return !seen && r.matches(ctx, key, obj)
+}
+
+// delete removes the version of a deleted object.
+func (r *reconciledVersions) delete(ctx context.Context, key string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.seen, key)
+	r.mu.Unlock()
+
+	err := r.store.Delete(ctx, key)
+	if err != nil {
+		r.logger.Warningf("could not delete %s reconciled version: %s", key, err)
+	}
+}
+
+// newReconciledResultHandler returns a ResultHandler that stores the version of the objects handled
+// successfully, and skips the first handling of the unchanged objects if enabled.
+func newReconciledResultHandler(reconciled *reconciledVersions, h ResultHandler) ResultHandler {
+	return ResultHandlerFunc(func(ctx context.Context, obj runtime.Object) (Result, error) {
+		key, keyErr := cache.MetaNamespaceKeyFunc(obj)
+		if keyErr == nil && reconciled.firstMatches(ctx, key, obj) {
+			return Result{}, nil
+		}
+
+		res, err := h.HandleWithResult(ctx, obj)
+		if err != nil {
+			return res, err
+		}
+
+		if keyErr == nil {
+			reconciled.set(ctx, key, obj)
+		}
+
+		return res, nil
+	})
+}
+
+// reconciledFlusher loads and flushes a buffered reconciled store.
+//
+// A nil reconciledFlusher is valid and will not do anything.
+type reconciledFlusher struct {
+	store    BufferedReconciledStore
+	interval time.Duration
+	clock    clock.Clock
+	logger   log.Logger
+}
+
+// newReconciledFlusher returns a reconciledFlusher if the store is buffered, nil otherwise.
+func newReconciledFlusher(store ReconciledStore, interval time.Duration, clk clock.Clock, logger log.Logger) *reconciledFlusher {
+	bstore, ok := store.(BufferedReconciledStore)
+	if !ok {
+		return nil
+	}
+
+	return &reconciledFlusher{
+		store:    bstore,
+		interval: interval,
+		clock:    clk,
+		logger:   logger,
+	}
+}
+
+// load loads the store, the failed loads are retried on the next flush.
+func (r *reconciledFlusher) load(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	err := r.store.Load(ctx)
+	if err != nil {
+		r.logger.Warningf("could not load reconciled versions: %s", err)
+	}
+}
+
+// run flushes the store on each interval until the context is done.
+func (r *reconciledFlusher) run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.interval):
+			r.flush(ctx)
+		}
+	}
+}
+
+// flush flushes the store.
+func (r *reconciledFlusher) flush(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	err := r.store.Flush(ctx)
+	if err != nil {
+		r.logger.Warningf("could not flush reconciled versions: %s", err)
+	}
+}
@@ -0,0 +1,203 @@
+package controller_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	testclock "k8s.io/utils/clock/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerReconciledStore(t *testing.T) {
+	tests := map[string]struct {
+		newStore func(cli *fake.Clientset) func() controller.ReconciledStore
+		assert   func(t *testing.T, cli *fake.Clientset)
+	}{
+		"The memory store should skip the unchanged objects after a restart.": {
+			newStore: func(_ *fake.Clientset) func() controller.ReconciledStore {
+				store := controller.NewMemoryReconciledStore()
+				return func() controller.ReconciledStore { return store }
+			},
+		},
+
+		"The ConfigMap store should skip the unchanged objects after a restart.": {
+			newStore: func(cli *fake.Clientset) func() controller.ReconciledStore {
+				// A new store on each start, so the versions are only loaded from the ConfigMap.
+				return func() controller.ReconciledStore {
+					return controller.NewConfigMapReconciledStore(cli, "kooper", "reconciled")
+				}
+			},
+			assert: func(t *testing.T, cli *fake.Clientset) {
+				cm, err := cli.CoreV1().ConfigMaps("kooper").Get(context.TODO(), "reconciled", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"testing-0": "3", "testing-1": "2"}, cm.Data)
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			newNs := func(name, version string) *corev1.Namespace {
+				return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: version}}
+			}
+			mc := fake.NewSimpleClientset(newNs("testing-0", "1"), newNs("testing-1", "1"))
+			newStore := test.newStore(mc)
+
+			var mu sync.Mutex
+			handled := map[string]int{}
+			h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled[obj.(*corev1.Namespace).Name]++
+				return nil
+			})
+			waitHandled := func(exp map[string]int) {
+				require.Eventually(func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+					return reflect.DeepEqual(exp, handled)
+				}, 1*time.Second, 5*time.Millisecond)
+			}
+			run := func(ctx context.Context) (stop func()) {
+				ctx, cancel := context.WithCancel(ctx)
+				c, err := controller.New(&controller.Config{
+					Name:            "test",
+					Handler:         h,
+					Retriever:       newNamespaceRetriever(mc),
+					ReconciledStore: newStore(),
+					Logger:          log.Dummy,
+				})
+				require.NoError(err)
+				stoppedC := make(chan struct{})
+				go func() {
+					_ = c.Run(ctx)
+					close(stoppedC)
+				}()
+				return func() {
+					cancel()
+					<-stoppedC
+				}
+			}
+
+			// First start, all objects are handled.
+			stop := run(context.Background())
+			waitHandled(map[string]int{"testing-0": 1, "testing-1": 1})
+			stop()
+
+			// Change an object while the controller is stopped.
+			_, err := mc.CoreV1().Namespaces().Update(context.TODO(), newNs("testing-1", "2"), metav1.UpdateOptions{})
+			require.NoError(err)
+
+			// After the restart, only the changed object should be handled.
+			stop = run(context.Background())
+			defer stop()
+			waitHandled(map[string]int{"testing-0": 1, "testing-1": 2})
+			time.Sleep(100 * time.Millisecond)
+			waitHandled(map[string]int{"testing-0": 1, "testing-1": 2})
+
+			// Once started, the changes of the skipped objects should be handled.
+			_, err = mc.CoreV1().Namespaces().Update(context.TODO(), newNs("testing-0", "3"), metav1.UpdateOptions{})
+			require.NoError(err)
+			waitHandled(map[string]int{"testing-0": 2, "testing-1": 2})
+
+			// The buffered stores are flushed once stopped.
+			stop()
+			if test.assert != nil {
+				test.assert(t, mc)
+			}
+		})
+	}
+}
+
+func TestConfigMapReconciledStoreFlush(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	newNs := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"}}
+	}
+	mc := fake.NewSimpleClientset(newNs("testing-0"), newNs("testing-1"))
+
+	handledC := make(chan string, 10)
+	h := controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+		handledC <- obj.(*corev1.Namespace).Name
+		return nil
+	})
+	deletedC := make(chan struct{}, 1)
+	dh := controller.HandlerFunc(func(context.Context, runtime.Object) error {
+		deletedC <- struct{}{}
+		return nil
+	})
+
+	clk := testclock.NewFakeClock(time.Now())
+	c, err := controller.New(&controller.Config{
+		Name:                         "test",
+		Handler:                      h,
+		DeleteHandler:                dh,
+		Retriever:                    newNamespaceRetriever(mc),
+		ReconciledStore:              controller.NewConfigMapReconciledStore(mc, "kooper", "reconciled"),
+		ReconciledStoreFlushInterval: time.Minute,
+		Clock:                        clk,
+		Logger:                       log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handledC:
+		case <-time.After(1 * time.Second):
+			require.FailNow("timeout waiting for handling")
+		}
+	}
+
+	getData := func() map[string]string {
+		cm, err := mc.CoreV1().ConfigMaps("kooper").Get(context.TODO(), "reconciled", metav1.GetOptions{})
+		require.NoError(err)
+		return cm.Data
+	}
+	countUpdates := func() int {
+		n := 0
+		for _, a := range mc.Actions() {
+			if a.GetVerb() == "update" && a.GetResource().Resource == "configmaps" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The handlings should not update the ConfigMap until flushed.
+	assert.Empty(getData())
+	assert.Equal(0, countUpdates())
+
+	// Deleting an object should not update the ConfigMap either.
+	require.NoError(mc.CoreV1().Namespaces().Delete(ctx, "testing-1", metav1.DeleteOptions{}))
+	select {
+	case <-deletedC:
+	case <-time.After(1 * time.Second):
+		require.FailNow("timeout waiting for delete handling")
+	}
+	assert.Equal(0, countUpdates())
+
+	// All the changes should be flushed at once on the interval.
+	require.Eventually(func() bool {
+		clk.Step(time.Minute)
+		return reflect.DeepEqual(map[string]string{"testing-0": "1"}, getData())
+	}, 1*time.Second, 5*time.Millisecond)
+	assert.Equal(1, countUpdates())
+}
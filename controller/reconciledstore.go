@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type configMapReconciledStore struct {
+	cli       kubernetes.Interface
+	namespace string
+	name      string
+
+	flushMu  sync.Mutex // flushMu serializes the loads and flushes of the ConfigMap.
+	mu       sync.Mutex
+	cm       *corev1.ConfigMap // cm is the last known state of the ConfigMap, nil until loaded.
+	versions map[string]string
+	changed  map[string]uint64 // changed has the change number of the keys that have not been flushed.
+	changes  uint64
+}
+
+// NewConfigMapReconciledStore returns a ReconciledStore that persists the versions on a ConfigMap, it
+// will be created if missing. The versions are kept in memory and the changes are written to the
+// ConfigMap in batches (`Config.ReconciledStoreFlushInterval`) and once the controller stops, so only a
+// single controller instance should use the ConfigMap (e.g: with leader election). The versions are
+// not known until loaded when the controller starts. Take into account the ConfigMap size limit (1MiB)
+// for big object sets.
+func NewConfigMapReconciledStore(cli kubernetes.Interface, namespace, name string) ReconciledStore {
+	return &configMapReconciledStore{
+		cli:       cli,
+		namespace: namespace,
+		name:      name,
+		versions:  map[string]string{},
+		changed:   map[string]uint64{},
+	}
+}
+
+// The object keys have `/` that is not valid on the ConfigMap keys, the namespaces and names can't
+// have `_`.
+func configMapDataKey(key string) string { return strings.ReplaceAll(key, "/", "_") }
+
+func (c *configMapReconciledStore) Get(_ context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.versions[configMapDataKey(key)]
+	return v, ok, nil
+}
+
+func (c *configMapReconciledStore) Set(_ context.Context, key, version string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := configMapDataKey(key)
+	if v, ok := c.versions[k]; ok && v == version {
+		return nil
+	}
+	c.versions[k] = version
+	c.markChanged(k)
+
+	return nil
+}
+
+func (c *configMapReconciledStore) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := configMapDataKey(key)
+	if _, ok := c.versions[k]; !ok && c.cm != nil {
+		return nil
+	}
+	delete(c.versions, k)
+	c.markChanged(k)
+
+	return nil
+}
+
+// markChanged marks the key as not flushed, must be called with the lock acquired.
+func (c *configMapReconciledStore) markChanged(k string) {
+	c.changes++
+	c.changed[k] = c.changes
+}
+
+func (c *configMapReconciledStore) Load(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	return c.load(ctx)
+}
+
+func (c *configMapReconciledStore) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if len(c.changed) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	cm := c.cm.DeepCopy()
+	cm.Data = make(map[string]string, len(c.versions))
+	for k, v := range c.versions {
+		cm.Data[k] = v
+	}
+	flushed := make(map[string]uint64, len(c.changed))
+	for k, n := range c.changed {
+		flushed[k] = n
+	}
+	c.mu.Unlock()
+
+	cm, err = c.cli.CoreV1().ConfigMaps(c.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		// Load the ConfigMap again on the next flush, keeping the changes.
+		c.cm = nil
+		return fmt.Errorf("could not update reconciled versions configmap: %w", err)
+	}
+	c.cm = cm
+
+	// The keys changed again while flushing are flushed the next time.
+	for k, n := range flushed {
+		if c.changed[k] == n {
+			delete(c.changed, k)
+		}
+	}
+
+	return nil
+}
+
+// load gets the ConfigMap versions if not loaded, creating the ConfigMap if missing. The changes that
+// have not been flushed are kept over the loaded versions. Must be called with the flush lock acquired.
+func (c *configMapReconciledStore) load(ctx context.Context) error {
+	c.mu.Lock()
+	loaded := c.cm != nil
+	c.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	cm, err := c.cli.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm, err = c.cli.CoreV1().ConfigMaps(c.namespace).Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: c.name},
+		}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("could not load reconciled versions configmap: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	versions := map[string]string{}
+	for k, v := range cm.Data {
+		versions[k] = v
+	}
+	for k := range c.changed {
+		if v, ok := c.versions[k]; ok {
+			versions[k] = v
+		} else {
+			delete(versions, k)
+		}
+	}
+	c.versions = versions
+	c.cm = cm
+
+	return nil
+}
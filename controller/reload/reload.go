@@ -0,0 +1,189 @@
+// Package reload has a controller that watches a single configuration object (e.g: a ConfigMap or
+// a Secret) and calls a reload function when it changes, debouncing the rapid changes so they
+// result in a single reload.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// ReloadFunc is the function called with the latest state of the configuration object.
+type ReloadFunc func(ctx context.Context, obj runtime.Object) error
+
+// Config is the configuration of the reload controller.
+type Config struct {
+	// Name is the name of the controller.
+	Name string
+	// Retriever is the retriever of the configuration object, it should only retrieve
+	// one object (e.g: `ConfigMapRetriever`, `SecretRetriever`).
+	Retriever controller.Retriever
+	// ReloadFunc is called with the latest state of the object when it is added or changes. The
+	// errors are logged, the object will be reloaded again on its next change.
+	ReloadFunc ReloadFunc
+	// Debounce is the time without changes that will wait before reloading, so the rapid changes
+	// result in a single reload. By default 1s.
+	Debounce time.Duration
+	// Logger is the logger of the controller.
+	Logger log.Logger
+}
+
+func (c *Config) setDefaults() error {
+	if c.Retriever == nil {
+		return fmt.Errorf("retriever is required")
+	}
+
+	if c.ReloadFunc == nil {
+		return fmt.Errorf("reload func is required")
+	}
+
+	if c.Name == "" {
+		c.Name = "config-reload"
+	}
+
+	if c.Debounce <= 0 {
+		c.Debounce = 1 * time.Second
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.NewStd(false)
+	}
+	c.Logger = c.Logger.WithKV(log.KV{"service": "reload-controller", "controller-id": c.Name})
+
+	return nil
+}
+
+type reloader struct {
+	controller.Controller
+	cfg     Config
+	mu      sync.Mutex
+	latest  runtime.Object
+	changed chan struct{}
+}
+
+// New returns a controller that calls the reload func with the latest state of the retrieved
+// configuration object after it doesn't change for the debounce time. The deletions of the
+// object are ignored, the last reloaded state is kept.
+func New(cfg Config) (controller.Controller, error) {
+	err := cfg.setDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	r := &reloader{
+		cfg:     cfg,
+		changed: make(chan struct{}, 1),
+	}
+
+	ctrl, err := controller.New(&controller.Config{
+		Name:      cfg.Name,
+		Handler:   controller.HandlerFunc(r.handle),
+		Retriever: cfg.Retriever,
+		Logger:    cfg.Logger,
+		// The resyncs are not changes of the object.
+		SkipUnchangedResyncs: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create controller: %w", err)
+	}
+	r.Controller = ctrl
+
+	return r, nil
+}
+
+func (r *reloader) handle(_ context.Context, obj runtime.Object) error {
+	r.mu.Lock()
+	r.latest = obj
+	r.mu.Unlock()
+
+	select {
+	case r.changed <- struct{}{}:
+	default: // There is already a pending change.
+	}
+
+	return nil
+}
+
+func (r *reloader) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go r.debounce(ctx)
+
+	return r.Controller.Run(ctx)
+}
+
+func (r *reloader) debounce(ctx context.Context) {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-r.changed:
+			// Every change restarts the wait.
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(r.cfg.Debounce)
+			timerC = timer.C
+		case <-timerC:
+			timerC = nil
+			r.mu.Lock()
+			obj := r.latest
+			r.mu.Unlock()
+
+			err := r.cfg.ReloadFunc(ctx, obj)
+			if err != nil {
+				r.cfg.Logger.Errorf("error reloading configuration: %s", err)
+			}
+		}
+	}
+}
+
+// ConfigMapRetriever returns a retriever of a single ConfigMap.
+func ConfigMapRetriever(cli kubernetes.Interface, namespace, name string) controller.Retriever {
+	return controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = nameSelector(name)
+			return cli.CoreV1().ConfigMaps(namespace).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = nameSelector(name)
+			return cli.CoreV1().ConfigMaps(namespace).Watch(context.Background(), options)
+		},
+	})
+}
+
+// SecretRetriever returns a retriever of a single Secret.
+func SecretRetriever(cli kubernetes.Interface, namespace, name string) controller.Retriever {
+	return controller.MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = nameSelector(name)
+			return cli.CoreV1().Secrets(namespace).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = nameSelector(name)
+			return cli.CoreV1().Secrets(namespace).Watch(context.Background(), options)
+		},
+	})
+}
+
+func nameSelector(name string) string {
+	return fields.OneTermEqualSelector("metadata.name", name).String()
+}
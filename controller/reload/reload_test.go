@@ -0,0 +1,102 @@
+package reload_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller/reload"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func newConfigMap(data string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "test"},
+		Data:       map[string]string{"config": data},
+	}
+}
+
+func TestReloader(t *testing.T) {
+	tests := map[string]struct {
+		bursts     [][]string
+		expReloads []string
+	}{
+		"Rapid changes should result in a single reload with the latest state.": {
+			bursts: [][]string{
+				{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			},
+			expReloads: []string{"9"},
+		},
+
+		"Changes separated by more than the debounce time should result in multiple reloads.": {
+			bursts: [][]string{
+				{"1", "2", "3"},
+				{"4", "5"},
+			},
+			expReloads: []string{"3", "5"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cli := kubernetesfake.NewSimpleClientset(newConfigMap("0"))
+
+			var mu sync.Mutex
+			reloads := []string{}
+			getReloads := func() []string {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]string{}, reloads...)
+			}
+
+			const debounce = 200 * time.Millisecond
+			ctrl, err := reload.New(reload.Config{
+				Retriever: reload.ConfigMapRetriever(cli, "test", "config"),
+				ReloadFunc: func(_ context.Context, obj runtime.Object) error {
+					mu.Lock()
+					defer mu.Unlock()
+					reloads = append(reloads, obj.(*corev1.ConfigMap).Data["config"])
+					return nil
+				},
+				Debounce: debounce,
+				Logger:   log.Dummy,
+			})
+			require.NoError(err)
+			go func() { _ = ctrl.Run(ctx) }()
+
+			// Wait for the initial state reload.
+			require.Eventually(func() bool { return len(getReloads()) == 1 }, 2*time.Second, 10*time.Millisecond)
+			require.Equal([]string{"0"}, getReloads())
+
+			for i, burst := range test.bursts {
+				for _, data := range burst {
+					_, err := cli.CoreV1().ConfigMaps("test").Update(ctx, newConfigMap(data), metav1.UpdateOptions{})
+					require.NoError(err)
+					time.Sleep(debounce / 10)
+				}
+
+				// Wait for the burst reload.
+				require.Eventually(func() bool { return len(getReloads()) == i+2 }, 2*time.Second, 10*time.Millisecond,
+					fmt.Sprintf("burst %d should have been reloaded", i))
+			}
+
+			// No more reloads should happen.
+			time.Sleep(2 * debounce)
+			assert.Equal(append([]string{"0"}, test.expReloads...), getReloads())
+		})
+	}
+}
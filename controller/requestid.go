@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type requestIDCtxKey struct{}
+
+// RequestIDFromContext returns the unique ID of the processing of an object, the processing logs
+// of the controller have the same ID (`request-id`) so they can be correlated with the handler logs.
+// If the context is not from a processing it will return an empty ID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// contextWithRequestID returns a context with a new unique request ID.
+func contextWithRequestID(ctx context.Context) context.Context {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return context.WithValue(ctx, requestIDCtxKey{}, hex.EncodeToString(b))
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type listFunc func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error)
+type watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// newTypedRetriever returns a retriever from the list and watch funcs of a typed client, it's used by
+// the retrievers of the common core resources. The namespace of these retrievers can be empty
+// (`metav1.NamespaceAll`) to retrieve the objects of all the namespaces.
+func newTypedRetriever(l listFunc, w watchFunc) Retriever {
+	return MustRetrieverFromListerWatcher(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return l(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w(context.TODO(), options)
+		},
+	})
+}
+
+// NewPodRetriever returns a retriever of the Pods of a namespace.
+func NewPodRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.CoreV1().Pods(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewServiceRetriever returns a retriever of the Services of a namespace.
+func NewServiceRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.CoreV1().Services(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewConfigMapRetriever returns a retriever of the ConfigMaps of a namespace.
+func NewConfigMapRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.CoreV1().ConfigMaps(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewSecretRetriever returns a retriever of the Secrets of a namespace.
+func NewSecretRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.CoreV1().Secrets(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewNamespaceRetriever returns a retriever of the Namespaces.
+func NewNamespaceRetriever(cli kubernetes.Interface) Retriever {
+	c := cli.CoreV1().Namespaces()
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewDeploymentRetriever returns a retriever of the Deployments of a namespace.
+func NewDeploymentRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.AppsV1().Deployments(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewStatefulSetRetriever returns a retriever of the StatefulSets of a namespace.
+func NewStatefulSetRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.AppsV1().StatefulSets(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewDaemonSetRetriever returns a retriever of the DaemonSets of a namespace.
+func NewDaemonSetRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.AppsV1().DaemonSets(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
+
+// NewJobRetriever returns a retriever of the Jobs of a namespace.
+func NewJobRetriever(cli kubernetes.Interface, namespace string) Retriever {
+	c := cli.BatchV1().Jobs(namespace)
+	return newTypedRetriever(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(ctx, opts)
+	}, c.Watch)
+}
@@ -0,0 +1,106 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+func TestTypedRetrievers(t *testing.T) {
+	tests := map[string]struct {
+		retriever    func(cli *fake.Clientset) controller.Retriever
+		expResource  schema.GroupVersionResource
+		expNamespace string
+	}{
+		"Pod retriever should retrieve pods.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewPodRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			expNamespace: "test",
+		},
+
+		"Service retriever should retrieve services.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewServiceRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Version: "v1", Resource: "services"},
+			expNamespace: "test",
+		},
+
+		"ConfigMap retriever should retrieve configmaps.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewConfigMapRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			expNamespace: "test",
+		},
+
+		"Secret retriever should retrieve secrets.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewSecretRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			expNamespace: "test",
+		},
+
+		"Namespace retriever should retrieve namespaces.": {
+			retriever:   func(cli *fake.Clientset) controller.Retriever { return controller.NewNamespaceRetriever(cli) },
+			expResource: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		},
+
+		"Deployment retriever should retrieve deployments.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewDeploymentRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			expNamespace: "test",
+		},
+
+		"StatefulSet retriever should retrieve statefulsets.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewStatefulSetRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"},
+			expNamespace: "test",
+		},
+
+		"DaemonSet retriever should retrieve daemonsets.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewDaemonSetRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"},
+			expNamespace: "test",
+		},
+
+		"Job retriever should retrieve jobs.": {
+			retriever:    func(cli *fake.Clientset) controller.Retriever { return controller.NewJobRetriever(cli, "test") },
+			expResource:  schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+			expNamespace: "test",
+		},
+
+		"A retriever without namespace should retrieve all the namespaces.": {
+			retriever: func(cli *fake.Clientset) controller.Retriever {
+				return controller.NewPodRetriever(cli, metav1.NamespaceAll)
+			},
+			expResource: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			cli := fake.NewSimpleClientset()
+			ret := test.retriever(cli)
+
+			_, err := ret.List(context.TODO(), metav1.ListOptions{})
+			require.NoError(err)
+			w, err := ret.Watch(context.TODO(), metav1.ListOptions{})
+			require.NoError(err)
+			w.Stop()
+
+			actions := cli.Actions()
+			require.Len(actions, 2)
+			for i, verb := range []string{"list", "watch"} {
+				assert.Equal(verb, actions[i].GetVerb())
+				assert.Equal(test.expResource, actions[i].GetResource())
+				assert.Equal(test.expNamespace, actions[i].GetNamespace())
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// resyncPacer knows how to spread the resync enqueues over time, so on each resync the whole
+// object set is not queued at once.
+//
+// A nil resyncPacer is valid and will not delay the enqueues.
+type resyncPacer struct {
+	limiter  *rate.Limiter
+	jitter   time.Duration
+	maxDelay time.Duration
+}
+
+// newResyncPacer returns a new resync pacer, the delays will not be greater than maxDelay (the
+// resync interval), so the delays of a resync don't accumulate with the ones of the next resyncs.
+func newResyncPacer(keysPerSecond float64, jitter, maxDelay time.Duration) *resyncPacer {
+	if keysPerSecond <= 0 {
+		return nil
+	}
+
+	return &resyncPacer{
+		limiter:  rate.NewLimiter(rate.Limit(keysPerSecond), 1),
+		jitter:   jitter,
+		maxDelay: maxDelay,
+	}
+}
+
+// delay returns the time the resync key enqueue needs to wait.
+func (r *resyncPacer) delay() time.Duration {
+	if r == nil {
+		return 0
+	}
+
+	res := r.limiter.Reserve()
+	d := res.Delay()
+	if r.maxDelay > 0 && d > r.maxDelay {
+		// Don't consume the rate of the next resyncs.
+		res.Cancel()
+		d = r.maxDelay
+	}
+
+	if r.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(r.jitter)))
+	}
+
+	return d
+}
+
+// stoppedResyncs has the keys of the objects excluded from the resyncs (`Result.StopResync`).
+type stoppedResyncs struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newStoppedResyncs() *stoppedResyncs {
+	return &stoppedResyncs{keys: map[string]struct{}{}}
+}
+
+// set excludes the key from the resyncs if stopped, otherwise the key is resynced again.
+func (s *stoppedResyncs) set(key string, stopped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stopped {
+		s.keys[key] = struct{}{}
+	} else {
+		delete(s.keys, key)
+	}
+}
+
+// has returns true if the key is excluded from the resyncs.
+func (s *stoppedResyncs) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
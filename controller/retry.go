@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/utils/clock"
+)
+
+// RetryInline calls the func until it succeeds or the retry policy doesn't retry it anymore, waiting the
+// policy duration between the attempts. It can be used inside the handlers to retry idempotent steps
+// without queueing the object again. If the context is done while waiting it returns the context error.
+func RetryInline(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	return RetryInlineWithClock(ctx, clock.RealClock{}, policy, fn)
+}
+
+// RetryInlineWithClock is like RetryInline but uses the clock to wait between the attempts, this is
+// useful for tests with a fake clock.
+func RetryInlineWithClock(ctx context.Context, clk clock.Clock, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		retry, after := policy(err, attempt)
+		if !retry {
+			return err
+		}
+
+		if after <= 0 {
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped retrying after %q error: %w", err, ctx.Err())
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped retrying after %q error: %w", err, ctx.Err())
+		case <-clk.After(after):
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+
+	"github.com/spotahome/kooper/v2/controller"
+)
+
+func TestRetryInline(t *testing.T) {
+	errTransient := fmt.Errorf("transient error")
+	errPermanent := fmt.Errorf("permanent error")
+
+	// The transient errors are retried 3 times with a linear backoff.
+	policy := func(err error, attempt int) (bool, time.Duration) {
+		if errors.Is(err, errTransient) && attempt <= 3 {
+			return true, time.Duration(attempt) * time.Second
+		}
+		return false, 0
+	}
+
+	tests := map[string]struct {
+		errs        []error
+		cancel      bool
+		expBackoffs []time.Duration
+		expErr      error
+	}{
+		"A func failing twice and then succeeding should be retried with the backoff.": {
+			errs:        []error{errTransient, errTransient, nil},
+			expBackoffs: []time.Duration{1 * time.Second, 2 * time.Second},
+		},
+
+		"A func failing with a not retried error should not be retried.": {
+			errs:   []error{errTransient, errPermanent},
+			expErr: errPermanent,
+			// A single wait before the second attempt.
+			expBackoffs: []time.Duration{1 * time.Second},
+		},
+
+		"A func failing more than the policy retries should return the last error.": {
+			errs:        []error{errTransient, errTransient, errTransient, errTransient},
+			expBackoffs: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second},
+			expErr:      errTransient,
+		},
+
+		"A cancelled context while waiting should stop the retries.": {
+			errs:   []error{errTransient, nil},
+			cancel: true,
+			expErr: context.Canceled,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			clk := testclock.NewFakeClock(time.Now())
+			attempts := 0
+			errs := test.errs
+			fn := func(context.Context) error {
+				err := errs[attempts]
+				attempts++
+				return err
+			}
+
+			errC := make(chan error, 1)
+			go func() { errC <- controller.RetryInlineWithClock(ctx, clk, policy, fn) }()
+
+			// Move the time only the expected backoff when the retry is waiting.
+			for _, backoff := range test.expBackoffs {
+				require.Eventually(clk.HasWaiters, 1*time.Second, time.Millisecond)
+				clk.Step(backoff - time.Millisecond)
+				assert.True(clk.HasWaiters(), "should wait the full backoff")
+				clk.Step(time.Millisecond)
+			}
+
+			if test.cancel {
+				require.Eventually(clk.HasWaiters, 1*time.Second, time.Millisecond)
+				cancel()
+			}
+
+			select {
+			case err := <-errC:
+				if test.expErr != nil {
+					assert.ErrorIs(err, test.expErr)
+				} else {
+					assert.NoError(err)
+				}
+			case <-time.After(1 * time.Second):
+				require.Fail("retry should have ended")
+			}
+		})
+	}
+}
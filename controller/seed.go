@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SeedObjects satisfies Seeder interface.
+func (g *generic) SeedObjects(objs []runtime.Object) error {
+	if g.isRunning() {
+		return fmt.Errorf("objects can't be seeded while the controller is running")
+	}
+
+	indexer := g.informer.GetIndexer()
+	for _, obj := range objs {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return fmt.Errorf("could not get seeded object key: %w", err)
+		}
+
+		err = indexer.Add(obj)
+		if err != nil {
+			return fmt.Errorf("could not add %s seeded object to the cache: %w", key, err)
+		}
+		g.enqueue(key)
+	}
+
+	if len(objs) > 0 {
+		atomic.StoreInt32(&g.seeded, 1)
+	}
+
+	return nil
+}
+
+// isSeeded returns true if the controller cache has been seeded with objects.
+func (g *generic) isSeeded() bool {
+	return atomic.LoadInt32(&g.seeded) == 1
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// listSelector is the label selector of the informer lists and watches, it can be updated while
+// the controller is running, forcing the informer to list the objects again with the new selector.
+// On the new list, the informer will notify as deleted the objects that don't match anymore.
+type listSelector struct {
+	mu       sync.Mutex
+	selector labels.Selector
+	listed   string          // listed is the selector used on the last list.
+	watch    watch.Interface // watch is the current watch of the informer.
+}
+
+func newListSelector(selector labels.Selector) *listSelector {
+	return &listSelector{selector: selector}
+}
+
+// list sets the selector on the list options.
+func (l *listSelector) list(options *metav1.ListOptions) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.selector != nil {
+		options.LabelSelector = l.selector.String()
+	}
+	l.listed = options.LabelSelector
+}
+
+// watchStarting sets the selector on the watch options, if the selector has changed since the
+// last list it will return an error so the informer lists again.
+func (l *listSelector) watchStarting(options *metav1.ListOptions) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.selector != nil {
+		options.LabelSelector = l.selector.String()
+	}
+	if options.LabelSelector != l.listed {
+		return fmt.Errorf("label selector changed since the last list")
+	}
+
+	return nil
+}
+
+// watchStarted tracks the current watch of the informer.
+func (l *listSelector) watchStarted(w watch.Interface) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watch = w
+}
+
+// update sets a new selector and stops the current watch, so the informer lists again.
+func (l *listSelector) update(selector labels.Selector) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.selector = selector
+	if l.watch != nil {
+		l.watch.Stop()
+		l.watch = nil
+	}
+}
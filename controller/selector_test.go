@@ -0,0 +1,77 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestGenericControllerUpdateSelector(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	newNS := func(name, team string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}
+	}
+	mc := fake.NewSimpleClientset(newNS("ns-a", "a"), newNS("ns-b", "b"))
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	deleted := map[string]int{}
+	record := func(m map[string]int) controller.Handler {
+		return controller.HandlerFunc(func(_ context.Context, obj runtime.Object) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ns := obj.(*corev1.Namespace)
+			m[ns.Name]++
+			return nil
+		})
+	}
+	c, err := controller.New(&controller.Config{
+		Name:          "test",
+		Handler:       record(handled),
+		DeleteHandler: record(deleted),
+		Retriever:     newNamespaceRetriever(mc),
+		LabelSelector: labels.SelectorFromSet(labels.Set{"team": "a"}),
+		Logger:        log.Dummy,
+	})
+	require.NoError(err)
+	go func() { _ = c.Run(ctx) }()
+
+	// Only the objects of the selector should be handled.
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["ns-a"] == 1
+	}, 1*time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.Equal(map[string]int{"ns-a": 1}, handled)
+	mu.Unlock()
+
+	// Update the selector, the new objects should be handled and the dropped ones deleted.
+	c.(controller.SelectorUpdater).UpdateSelector(labels.SelectorFromSet(labels.Set{"team": "b"}))
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["ns-b"] == 1 && deleted["ns-a"] == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(map[string]int{"ns-a": 1, "ns-b": 1}, handled)
+	assert.Equal(map[string]int{"ns-a": 1}, deleted)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ErrShutdownTimeout is returned by `RunWithSignals` when the controller doesn't stop within the
+// shutdown timeout after receiving a signal.
+var ErrShutdownTimeout = errors.New("controller shutdown timeout")
+
+// SignalsConfig is the configuration of `RunWithSignals`.
+type SignalsConfig struct {
+	// Signals are the signals that will stop the controller. By default SIGTERM and SIGINT.
+	Signals []os.Signal
+	// ShutdownTimeout is the maximum time that will wait for the controller to stop (e.g: finish the
+	// current handlings) after a signal. By default 30s.
+	ShutdownTimeout time.Duration
+	// SignalC is the channel where the signals are received, if set the process signals are not
+	// listened. Useful to send synthetic signals (e.g: tests).
+	SignalC <-chan os.Signal
+}
+
+func (c *SignalsConfig) defaults() {
+	if len(c.Signals) == 0 {
+		c.Signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 30 * time.Second
+	}
+}
+
+// RunWithSignals runs the controller until one of the signals is received, then it stops the
+// controller and waits for it to end gracefully within the shutdown timeout. It returns the
+// controller error if it ends by itself, or `ErrShutdownTimeout` if it doesn't stop in time.
+func RunWithSignals(ctrl Controller, cfg SignalsConfig) error {
+	cfg.defaults()
+
+	signalC := cfg.SignalC
+	if signalC == nil {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, cfg.Signals...)
+		defer signal.Stop(c)
+		signalC = c
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- ctrl.Run(ctx)
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-signalC:
+	}
+
+	// Stop the controller and wait until it finishes.
+	cancel()
+	timeout := time.NewTimer(cfg.ShutdownTimeout)
+	defer timeout.Stop()
+	select {
+	case err := <-errC:
+		return err
+	case <-timeout.C:
+		return fmt.Errorf("%w: not stopped after %s", ErrShutdownTimeout, cfg.ShutdownTimeout)
+	}
+}
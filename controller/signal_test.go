@@ -0,0 +1,107 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestRunWithSignals(t *testing.T) {
+	errWanted := fmt.Errorf("wanted error")
+
+	tests := map[string]struct {
+		leaderErr       error
+		handleLatency   time.Duration
+		shutdownTimeout time.Duration
+		signal          bool
+		expErr          error
+		expFinished     bool
+	}{
+		"A signal should stop the controller after finishing the current handling.": {
+			handleLatency:   100 * time.Millisecond,
+			shutdownTimeout: 1 * time.Second,
+			signal:          true,
+			expFinished:     true,
+		},
+
+		"A signal should return a timeout error if the controller doesn't stop in time.": {
+			handleLatency:   500 * time.Millisecond,
+			shutdownTimeout: 50 * time.Millisecond,
+			signal:          true,
+			expErr:          controller.ErrShutdownTimeout,
+		},
+
+		"A controller ending by itself should return its error.": {
+			leaderErr: errWanted,
+			expErr:    errWanted,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var started, finished int32
+			handleLatency := test.handleLatency
+			h := controller.HandlerFunc(func(context.Context, runtime.Object) error {
+				atomic.StoreInt32(&started, 1)
+				time.Sleep(handleLatency)
+				atomic.StoreInt32(&finished, 1)
+				return nil
+			})
+
+			_, nss := createNamespaceList("testing", 1)
+			mc := fake.NewSimpleClientset(nss[0])
+			c, err := controller.New(&controller.Config{
+				Name:          "test",
+				Handler:       h,
+				Retriever:     newNamespaceRetriever(mc),
+				LeaderElector: testLeaderElector{err: test.leaderErr},
+				Logger:        log.Dummy,
+			})
+			require.NoError(err)
+
+			signalC := make(chan os.Signal, 1)
+			resultC := make(chan error)
+			go func() {
+				resultC <- controller.RunWithSignals(c, controller.SignalsConfig{
+					ShutdownTimeout: test.shutdownTimeout,
+					SignalC:         signalC,
+				})
+			}()
+
+			// Send the signal while handling.
+			if test.signal {
+				require.Eventually(func() bool {
+					return atomic.LoadInt32(&started) == 1
+				}, 1*time.Second, 5*time.Millisecond)
+				signalC <- syscall.SIGTERM
+			}
+
+			select {
+			case err := <-resultC:
+				if test.expErr != nil {
+					assert.ErrorIs(err, test.expErr)
+				} else {
+					assert.NoError(err)
+				}
+			case <-time.After(2 * time.Second):
+				require.Fail("timeout waiting for the controller to stop")
+			}
+			assert.Equal(test.expFinished, atomic.LoadInt32(&finished) == 1)
+		})
+	}
+}
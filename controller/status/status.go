@@ -0,0 +1,59 @@
+package status
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+// Object is a Kubernetes object with metadata.
+type Object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// Client knows how to get and update the status subresource of objects of a type.
+// Kubernetes typed clients already satisfy this interface (e.g: `CoreV1().Pods(ns)`).
+type Client[T Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	UpdateStatus(ctx context.Context, obj T, opts metav1.UpdateOptions) (T, error)
+}
+
+// UpdateStatus will apply the mutation on the object and update its status subresource. In case
+// of a conflict it will get the latest version of the object, apply again the mutation and retry
+// the update.
+//
+// The received object is not mutated, the mutation is applied on a copy of it.
+//
+// e.g: `status.UpdateStatus[*corev1.Pod](ctx, cli.CoreV1().Pods(ns), pod, mutate)`.
+func UpdateStatus[T Object](ctx context.Context, cli Client[T], obj T, mutate func(obj T)) error {
+	current, ok := obj.DeepCopyObject().(T)
+	if !ok {
+		return fmt.Errorf("could not copy object")
+	}
+
+	first := true
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// On retries use the latest version of the object.
+		if !first {
+			latest, err := cli.Get(ctx, current.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			current = latest
+		}
+		first = false
+
+		mutate(current)
+		_, err := cli.UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not update status: %w", err)
+	}
+
+	return nil
+}
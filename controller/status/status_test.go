@@ -0,0 +1,88 @@
+package status_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/spotahome/kooper/v2/controller/status"
+)
+
+func TestUpdateStatus(t *testing.T) {
+	tests := map[string]struct {
+		conflicts    int
+		expErr       bool
+		expUpdates   int
+		expPodStatus corev1.PodPhase
+	}{
+		"Updating the status without conflicts should update the status once.": {
+			conflicts:    0,
+			expUpdates:   1,
+			expPodStatus: corev1.PodRunning,
+		},
+
+		"Updating the status with a conflict should get the latest object and retry.": {
+			conflicts:    1,
+			expUpdates:   2,
+			expPodStatus: corev1.PodRunning,
+		},
+
+		"Updating the status with conflicts on every try should fail.": {
+			conflicts:    100,
+			expErr:       true,
+			expPodStatus: corev1.PodPending,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test"},
+				Status:     corev1.PodStatus{Phase: corev1.PodPending},
+			}
+			cli := fake.NewSimpleClientset(pod)
+
+			updates := 0
+			conflicts := test.conflicts
+			cli.PrependReactor("update", "pods", func(action kubetesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "status" {
+					return false, nil, nil
+				}
+				updates++
+				if conflicts > 0 {
+					conflicts--
+					return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "test", nil)
+				}
+				return false, nil, nil
+			})
+
+			err := status.UpdateStatus[*corev1.Pod](context.TODO(), cli.CoreV1().Pods("test"), pod, func(p *corev1.Pod) {
+				p.Status.Phase = corev1.PodRunning
+			})
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expUpdates, updates)
+			}
+
+			// Check the original object has not been mutated and the stored one has.
+			assert.Equal(corev1.PodPending, pod.Status.Phase)
+			gotPod, err := cli.CoreV1().Pods("test").Get(context.TODO(), "test", metav1.GetOptions{})
+			require.NoError(err)
+			assert.Equal(test.expPodStatus, gotPod.Status.Phase)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"sync"
+)
+
+// Trigger is what queued an object to be handled.
+type Trigger string
+
+const (
+	// TriggerAdd is the trigger of the objects queued by an add event.
+	TriggerAdd Trigger = "add"
+	// TriggerUpdate is the trigger of the objects queued by an update event.
+	TriggerUpdate Trigger = "update"
+	// TriggerDelete is the trigger of the objects queued by a delete event.
+	TriggerDelete Trigger = "delete"
+	// TriggerResync is the trigger of the objects queued by a resync.
+	TriggerResync Trigger = "resync"
+	// TriggerOther is the trigger of the objects queued by the rest of causes (e.g: retries, requeues,
+	// related object keys).
+	TriggerOther Trigger = "other"
+)
+
+// triggerPriority is the priority of the triggers, when an object is queued multiple times before being
+// handled, the handling is triggered by the highest priority one.
+var triggerPriority = map[Trigger]int{
+	TriggerOther:  0,
+	TriggerResync: 1,
+	TriggerUpdate: 2,
+	TriggerAdd:    3,
+	TriggerDelete: 4,
+}
+
+type triggerCtxKey struct{}
+
+// TriggerFromContext returns the trigger of the handling of an object. If the context is not from a
+// handling it will return an empty trigger.
+func TriggerFromContext(ctx context.Context) Trigger {
+	t, _ := ctx.Value(triggerCtxKey{}).(Trigger)
+	return t
+}
+
+// contextWithTrigger returns a context with the trigger of the handling.
+func contextWithTrigger(ctx context.Context, t Trigger) context.Context {
+	return context.WithValue(ctx, triggerCtxKey{}, t)
+}
+
+// keyTriggers has the triggers of the queued object keys until they are handled.
+type keyTriggers struct {
+	mu       sync.Mutex
+	triggers map[string]Trigger
+}
+
+func newKeyTriggers() *keyTriggers {
+	return &keyTriggers{triggers: map[string]Trigger{}}
+}
+
+// set sets the trigger of a queued key, unless it has a higher priority one.
+func (k *keyTriggers) set(key string, t Trigger) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if current, ok := k.triggers[key]; !ok || triggerPriority[t] > triggerPriority[current] {
+		k.triggers[key] = t
+	}
+}
+
+// take returns the trigger of a key that is going to be handled and forgets it, `TriggerOther` if
+// it doesn't have one.
+func (k *keyTriggers) take(key string) Trigger {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	t, ok := k.triggers[key]
+	if !ok {
+		return TriggerOther
+	}
+	delete(k.triggers, key)
+	return t
+}
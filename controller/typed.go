@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TypedHandlerFunc knows how to handle the objects of a type (e.g: `*corev1.Pod`).
+type TypedHandlerFunc[T runtime.Object] func(ctx context.Context, obj T) error
+
+// TypedConfig is the configuration of a typed controller.
+type TypedConfig[T runtime.Object] struct {
+	// Config is the configuration of the wrapped controller, its handlers (`Handler`, `ResultHandler`,
+	// `HandlerFactory` and `DeleteHandler`) must not be set, the typed ones are used instead.
+	Config Config
+	// Handler is the typed controller handler.
+	Handler TypedHandlerFunc[T]
+	// DeleteHandler is the optional typed handler of the deleted objects (see `Config.DeleteHandler`).
+	DeleteHandler TypedHandlerFunc[T]
+}
+
+// TypedController is a controller of the objects of a type, the handlers, the cache lists and the
+// enqueues use the object type so they don't need casts. The type is the pointer of the object
+// (e.g: `*corev1.Pod`), the same as the ones returned by the retriever.
+type TypedController[T runtime.Object] struct {
+	Controller
+	g *generic
+}
+
+// NewTyped returns a new typed controller that wraps a controller created with `New`.
+func NewTyped[T runtime.Object](cfg TypedConfig[T]) (*TypedController[T], error) {
+	if cfg.Handler == nil {
+		return nil, fmt.Errorf("could not create controller: %w: typed handler is required", ErrControllerNotValid)
+	}
+
+	if cfg.Config.Handler != nil || cfg.Config.ResultHandler != nil || cfg.Config.HandlerFactory != nil || cfg.Config.DeleteHandler != nil {
+		return nil, fmt.Errorf("could not create controller: %w: untyped handlers can't be used on a typed controller", ErrControllerNotValid)
+	}
+
+	c := cfg.Config
+	c.Handler = typedHandler(cfg.Handler)
+	if cfg.DeleteHandler != nil {
+		c.DeleteHandler = typedHandler(cfg.DeleteHandler)
+	}
+
+	ctrl, err := New(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedController[T]{Controller: ctrl, g: ctrl.(*generic)}, nil
+}
+
+// typedHandler returns an untyped handler from a typed one, the objects of other types are
+// handled as errors.
+func typedHandler[T runtime.Object](h TypedHandlerFunc[T]) Handler {
+	return HandlerFunc(func(ctx context.Context, obj runtime.Object) error {
+		tObj, ok := obj.(T)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T", obj)
+		}
+		return h(ctx, tObj)
+	})
+}
+
+// List returns the objects of the controller cache. The objects are shared with the handlers, so
+// they must not be mutated.
+func (t *TypedController[T]) List() []T {
+	objs := t.g.informer.GetIndexer().List()
+	res := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		if tObj, ok := obj.(T); ok {
+			res = append(res, tObj)
+		}
+	}
+	return res
+}
+
+// Get returns the object of the controller cache by its key (`namespace/name`), false if it's not
+// on the cache. The object is shared with the handlers, so it must not be mutated.
+func (t *TypedController[T]) Get(key string) (T, bool, error) {
+	var zero T
+	obj, ok, err := t.g.informer.GetIndexer().GetByKey(key)
+	if err != nil || !ok {
+		return zero, false, err
+	}
+
+	tObj, ok := obj.(T)
+	if !ok {
+		return zero, false, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	return tObj, true, nil
+}
+
+// Enqueue queues an object to be handled (e.g: a related object of other controller), the
+// handler will receive the object state of the controller cache.
+func (t *TypedController[T]) Enqueue(obj T) error {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Errorf("could not get object key: %w", err)
+	}
+
+	t.g.enqueue(key)
+
+	return nil
+}
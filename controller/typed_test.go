@@ -0,0 +1,109 @@
+package controller_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+func TestTypedController(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	nsList, _ := createNamespaceList("testing", 3)
+	mc := &fake.Clientset{}
+	onKubeClientListNamespaceReturn(mc, nsList)
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	c, err := controller.NewTyped(controller.TypedConfig[*corev1.Namespace]{
+		Config: controller.Config{
+			Name:      "test",
+			Retriever: newNamespaceRetriever(mc),
+			Logger:    log.Dummy,
+		},
+		Handler: func(_ context.Context, ns *corev1.Namespace) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[ns.Name]++
+			return nil
+		},
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	handledTimes := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[name]
+	}
+	require.Eventually(func() bool { return handledTimes("testing-0") == 1 && handledTimes("testing-2") == 1 }, 1*time.Second, 5*time.Millisecond)
+
+	// The cache objects should be listed typed.
+	nss := c.List()
+	names := []string{}
+	for _, ns := range nss {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	assert.Equal([]string{"testing-0", "testing-1", "testing-2"}, names)
+
+	// The cache objects should be get typed.
+	ns, ok, err := c.Get("testing-1")
+	require.NoError(err)
+	require.True(ok)
+	assert.Equal("testing-1", ns.Name)
+	_, ok, err = c.Get("missing")
+	require.NoError(err)
+	assert.False(ok)
+
+	// The typed objects should be enqueued to be handled again.
+	err = c.Enqueue(ns)
+	require.NoError(err)
+	require.Eventually(func() bool { return handledTimes("testing-1") == 2 }, 1*time.Second, 5*time.Millisecond)
+	assert.Equal(1, handledTimes("testing-0"))
+}
+
+func TestTypedControllerInvalidConfig(t *testing.T) {
+	tests := map[string]struct {
+		cfg controller.TypedConfig[*corev1.Namespace]
+	}{
+		"A missing typed handler should fail.": {
+			cfg: controller.TypedConfig[*corev1.Namespace]{
+				Config: controller.Config{Name: "test", Retriever: newNamespaceRetriever(&fake.Clientset{})},
+			},
+		},
+
+		"An untyped handler should fail.": {
+			cfg: controller.TypedConfig[*corev1.Namespace]{
+				Config: controller.Config{
+					Name:      "test",
+					Retriever: newNamespaceRetriever(&fake.Clientset{}),
+					Handler:   controller.HandlerFunc(func(context.Context, runtime.Object) error { return nil }),
+				},
+				Handler: func(context.Context, *corev1.Namespace) error { return nil },
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := controller.NewTyped(test.cfg)
+			assert.ErrorIs(t, err, controller.ErrControllerNotValid)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type patchOperation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// MarshalJSON satisfies json.Marshaler interface, the value is set on all the operations
+// except removes, even if it's null.
+func (p patchOperation) MarshalJSON() ([]byte, error) {
+	if p.Op == "remove" {
+		return json.Marshal(map[string]interface{}{"op": p.Op, "path": p.Path})
+	}
+	return json.Marshal(map[string]interface{}{"op": p.Op, "path": p.Path, "value": p.Value})
+}
+
+// jsonPatch returns the JSON patch (RFC 6902) that transforms the original JSON document into the
+// mutated one, nil if they are equal. The objects are patched by field, the rest of values (e.g: lists)
+// are replaced.
+func jsonPatch(original, mutated []byte) ([]byte, error) {
+	var a, b interface{}
+	err := json.Unmarshal(original, &a)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(mutated, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := diff("", a, b, nil)
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(ops)
+}
+
+func diff(path string, a, b interface{}, ops []patchOperation) []patchOperation {
+	aMap, aOK := a.(map[string]interface{})
+	bMap, bOK := b.(map[string]interface{})
+	if !aOK || !bOK {
+		if !reflect.DeepEqual(a, b) {
+			ops = append(ops, patchOperation{Op: "replace", Path: path, Value: b})
+		}
+		return ops
+	}
+
+	// Sort the keys so the patches are deterministic.
+	for _, k := range sortedKeys(aMap) {
+		if _, ok := bMap[k]; !ok {
+			ops = append(ops, patchOperation{Op: "remove", Path: path + "/" + escapePointer(k)})
+		}
+	}
+	for _, k := range sortedKeys(bMap) {
+		av, ok := aMap[k]
+		if !ok {
+			ops = append(ops, patchOperation{Op: "add", Path: path + "/" + escapePointer(k), Value: bMap[k]})
+			continue
+		}
+		ops = diff(path+"/"+escapePointer(k), av, bMap[k], ops)
+	}
+
+	return ops
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapePointer escapes a JSON pointer (RFC 6901) reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
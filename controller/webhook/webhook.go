@@ -0,0 +1,277 @@
+// Package webhook has a minimal admission webhook server that decodes the admission reviews with
+// the same scheme as the controllers, and dispatches the objects to the validating and mutating
+// functions registered for their GroupVersionKind.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/spotahome/kooper/v2/log"
+)
+
+const (
+	// ValidatePath is the path of the server that serves the validating admission reviews.
+	ValidatePath = "/validate"
+	// MutatePath is the path of the server that serves the mutating admission reviews.
+	MutatePath = "/mutate"
+)
+
+// ValidateFunc validates an object, the returned error denies the admission with the error
+// as the reason.
+type ValidateFunc func(ctx context.Context, obj runtime.Object) error
+
+// MutateFunc mutates the received object, the returned error denies the admission with the
+// error as the reason.
+type MutateFunc func(ctx context.Context, obj runtime.Object) error
+
+// Config is the configuration of the webhook server.
+type Config struct {
+	// Addr is the address where the server will listen. By default `:8443`.
+	Addr string
+	// CertFile is the TLS certificate file of the server.
+	CertFile string
+	// KeyFile is the TLS key file of the server.
+	KeyFile string
+	// Scheme is the scheme used to decode the admission review objects, use the same as the
+	// controllers. By default Kubernetes client-go scheme.
+	Scheme *runtime.Scheme
+	// Logger is the logger of the server.
+	Logger log.Logger
+}
+
+func (c *Config) setDefaults() error {
+	if c.Addr == "" {
+		c.Addr = ":8443"
+	}
+
+	if c.Scheme == nil {
+		c.Scheme = scheme.Scheme
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.NewStd(false)
+	}
+	c.Logger = c.Logger.WithKV(log.KV{"service": "webhook-server"})
+
+	return nil
+}
+
+// Server is an admission webhook server.
+type Server struct {
+	cfg          Config
+	deserializer runtime.Decoder
+	mu           sync.RWMutex
+	validators   map[schema.GroupVersionKind][]ValidateFunc
+	mutators     map[schema.GroupVersionKind][]MutateFunc
+}
+
+// New returns a new webhook server.
+func New(cfg Config) (*Server, error) {
+	err := cfg.setDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &Server{
+		cfg:          cfg,
+		deserializer: serializer.NewCodecFactory(cfg.Scheme).UniversalDeserializer(),
+		validators:   map[schema.GroupVersionKind][]ValidateFunc{},
+		mutators:     map[schema.GroupVersionKind][]MutateFunc{},
+	}, nil
+}
+
+// RegisterValidator registers a validating function for the objects of a GroupVersionKind, the
+// objects are allowed if all the registered functions of their kind are successful.
+func (s *Server) RegisterValidator(gvk schema.GroupVersionKind, f ValidateFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[gvk] = append(s.validators[gvk], f)
+}
+
+// RegisterMutator registers a mutating function for the objects of a GroupVersionKind, the
+// registered functions of a kind are called in order with the same object.
+func (s *Server) RegisterMutator(gvk schema.GroupVersionKind, f MutateFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutators[gvk] = append(s.mutators[gvk], f)
+}
+
+// Handler returns the HTTP handler of the server, it serves the validating admission reviews on
+// `ValidatePath` and the mutating ones on `MutatePath`.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, s.reviewHandler(s.validate))
+	mux.Handle(MutatePath, s.reviewHandler(s.mutate))
+	return mux
+}
+
+// Run runs the TLS server until the context is done.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{Addr: s.cfg.Addr, Handler: s.Handler()}
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.ListenAndServeTLS(s.cfg.CertFile, s.cfg.KeyFile)
+	}()
+
+	select {
+	case err := <-errC:
+		return fmt.Errorf("webhook server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not shutdown webhook server: %w", err)
+	}
+
+	return nil
+}
+
+type reviewFunc func(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error)
+
+func (s *Server) reviewHandler(review reviewFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in := &admissionv1.AdmissionReview{}
+		err := json.NewDecoder(r.Body).Decode(in)
+		if err != nil || in.Request == nil {
+			s.cfg.Logger.Warningf("invalid admission review received: %v", err)
+			http.Error(w, "invalid admission review", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := review(r.Context(), in.Request)
+		if err != nil {
+			s.cfg.Logger.Errorf("could not review %s: %s", in.Request.Kind, err)
+			resp = &admissionv1.AdmissionResponse{
+				Result: &metav1.Status{Message: err.Error(), Code: http.StatusInternalServerError},
+			}
+		}
+		resp.UID = in.Request.UID
+
+		out := &admissionv1.AdmissionReview{TypeMeta: in.TypeMeta, Response: resp}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(out)
+		if err != nil {
+			s.cfg.Logger.Errorf("could not write admission review response: %s", err)
+		}
+	})
+}
+
+// decode returns the object of the admission request, nil if the request doesn't have an object,
+// (e.g: deletions).
+func (s *Server) decode(req *admissionv1.AdmissionRequest) (runtime.Object, error) {
+	if len(req.Object.Raw) == 0 {
+		return nil, nil
+	}
+
+	gvk := gvkOf(req)
+	obj, _, err := s.deserializer.Decode(req.Object.Raw, &gvk, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode object: %w", err)
+	}
+
+	return obj, nil
+}
+
+func (s *Server) validate(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	s.mu.RLock()
+	validators := s.validators[gvkOf(req)]
+	s.mu.RUnlock()
+
+	if len(validators) == 0 {
+		return allowed(), nil
+	}
+
+	obj, err := s.decode(req)
+	if err != nil || obj == nil {
+		return allowed(), err
+	}
+
+	for _, v := range validators {
+		err := v(ctx, obj)
+		if err != nil {
+			return denied(err), nil
+		}
+	}
+
+	return allowed(), nil
+}
+
+func (s *Server) mutate(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	s.mu.RLock()
+	mutators := s.mutators[gvkOf(req)]
+	s.mu.RUnlock()
+
+	if len(mutators) == 0 {
+		return allowed(), nil
+	}
+
+	obj, err := s.decode(req)
+	if err != nil || obj == nil {
+		return allowed(), err
+	}
+
+	// Compare the encodings of the same typed object, so the fields not changed by the
+	// mutators are not patched (e.g: the defaults of the encoding).
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode object: %w", err)
+	}
+
+	for _, m := range mutators {
+		err := m(ctx, obj)
+		if err != nil {
+			return denied(err), nil
+		}
+	}
+
+	mutated, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode mutated object: %w", err)
+	}
+
+	patch, err := jsonPatch(original, mutated)
+	if err != nil {
+		return nil, fmt.Errorf("could not create patch: %w", err)
+	}
+
+	resp := allowed()
+	if patch != nil {
+		patchType := admissionv1.PatchTypeJSONPatch
+		resp.Patch = patch
+		resp.PatchType = &patchType
+	}
+
+	return resp, nil
+}
+
+func gvkOf(req *admissionv1.AdmissionRequest) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+}
+
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func denied(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: err.Error(), Reason: metav1.StatusReasonForbidden, Code: http.StatusForbidden},
+	}
+}
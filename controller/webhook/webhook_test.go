@@ -0,0 +1,161 @@
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/spotahome/kooper/v2/controller/webhook"
+	"github.com/spotahome/kooper/v2/log"
+)
+
+var podGVK = corev1.SchemeGroupVersion.WithKind("Pod")
+
+func newPodReview(labels map[string]string) *admissionv1.AdmissionReview {
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", Labels: labels},
+	}
+	raw, _ := json.Marshal(pod)
+
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("test-uid"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func TestServer(t *testing.T) {
+	// Pods require an app label.
+	validator := func(_ context.Context, obj runtime.Object) error {
+		if obj.(*corev1.Pod).Labels["app"] == "" {
+			return fmt.Errorf("app label is required")
+		}
+		return nil
+	}
+
+	// Pods get a team label and the app label escaped.
+	mutator := func(_ context.Context, obj runtime.Object) error {
+		pod := obj.(*corev1.Pod)
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels["team"] = "platform"
+		pod.Labels["kooper.io/app"] = pod.Labels["app"]
+		delete(pod.Labels, "app")
+		return nil
+	}
+
+	tests := map[string]struct {
+		path       string
+		review     *admissionv1.AdmissionReview
+		expAllowed bool
+		expMessage string
+		expPatch   string
+	}{
+		"A valid object should be allowed.": {
+			path:       webhook.ValidatePath,
+			review:     newPodReview(map[string]string{"app": "test"}),
+			expAllowed: true,
+		},
+
+		"An invalid object should be denied with the validation error.": {
+			path:       webhook.ValidatePath,
+			review:     newPodReview(nil),
+			expAllowed: false,
+			expMessage: "app label is required",
+		},
+
+		"An object without registered validators should be allowed.": {
+			path: webhook.ValidatePath,
+			review: func() *admissionv1.AdmissionReview {
+				r := newPodReview(nil)
+				r.Request.Kind = metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+				return r
+			}(),
+			expAllowed: true,
+		},
+
+		"A mutated object should be allowed with the patch of the changes.": {
+			path:       webhook.MutatePath,
+			review:     newPodReview(map[string]string{"app": "test"}),
+			expAllowed: true,
+			expPatch:   `[{"op":"remove","path":"/metadata/labels/app"},{"op":"add","path":"/metadata/labels/kooper.io~1app","value":"test"},{"op":"add","path":"/metadata/labels/team","value":"platform"}]`,
+		},
+
+		"A mutated object without labels should add them with the patch.": {
+			path:       webhook.MutatePath,
+			review:     newPodReview(nil),
+			expAllowed: true,
+			expPatch:   `[{"op":"add","path":"/metadata/labels","value":{"kooper.io/app":"","team":"platform"}}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			s, err := webhook.New(webhook.Config{Logger: log.Dummy})
+			require.NoError(err)
+			s.RegisterValidator(podGVK, validator)
+			s.RegisterMutator(podGVK, mutator)
+
+			body, err := json.Marshal(test.review)
+			require.NoError(err)
+			req := httptest.NewRequest(http.MethodPost, test.path, bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			require.Equal(http.StatusOK, rec.Code)
+
+			gotReview := &admissionv1.AdmissionReview{}
+			err = json.Unmarshal(rec.Body.Bytes(), gotReview)
+			require.NoError(err)
+			require.NotNil(gotReview.Response)
+
+			assert.Equal(test.review.TypeMeta, gotReview.TypeMeta)
+			assert.Equal(test.review.Request.UID, gotReview.Response.UID)
+			assert.Equal(test.expAllowed, gotReview.Response.Allowed)
+			if test.expMessage != "" {
+				require.NotNil(gotReview.Response.Result)
+				assert.Equal(test.expMessage, gotReview.Response.Result.Message)
+			}
+			if test.expPatch != "" {
+				require.NotNil(gotReview.Response.PatchType)
+				assert.Equal(admissionv1.PatchTypeJSONPatch, *gotReview.Response.PatchType)
+				assert.JSONEq(test.expPatch, string(gotReview.Response.Patch))
+			} else {
+				assert.Empty(gotReview.Response.Patch)
+			}
+		})
+	}
+}
+
+func TestServerInvalidReview(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s, err := webhook.New(webhook.Config{Logger: log.Dummy})
+	require.NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, webhook.ValidatePath, bytes.NewReader([]byte(`{}`)))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+// ResyncClassifier returns the resync interval of an object, so the important objects can be resynced
+// more often than the rest (e.g: by a label). A zero or negative interval uses the `ResyncInterval`.
+type ResyncClassifier func(obj runtime.Object) time.Duration
+
+// weightedResyncTick is the interval the weighted resyncer checks the objects that need a resync, it's
+// the precision of the classified resync intervals. Each check only touches the due objects.
+const weightedResyncTick = 1 * time.Second
+
+// weightedResyncer resyncs the objects of the cache, each one at the interval of its class. The objects
+// are resynced at their interval since they were seen for the first time. The object keys are tracked
+// with the informer events and scheduled on a min-heap by their next resync time.
+//
+// A nil weightedResyncer is valid and will not resync.
+type weightedResyncer struct {
+	classifier      ResyncClassifier
+	defaultInterval time.Duration
+	clock           clock.Clock
+	indexer         cache.Indexer
+	resync          func(key string, obj runtime.Object)
+
+	mu        sync.Mutex
+	next      map[string]time.Time // next is the time of the next resync of the tracked object keys.
+	scheduled resyncSchedule       // scheduled has the next resyncs, the ones not in next are stale.
+}
+
+func newWeightedResyncer(classifier ResyncClassifier, defaultInterval time.Duration, clk clock.Clock, indexer cache.Indexer, resync func(key string, obj runtime.Object)) *weightedResyncer {
+	if classifier == nil {
+		return nil
+	}
+
+	return &weightedResyncer{
+		classifier:      classifier,
+		defaultInterval: defaultInterval,
+		clock:           clk,
+		indexer:         indexer,
+		resync:          resync,
+		next:            map[string]time.Time{},
+	}
+}
+
+// handler returns the informer event handler that tracks the objects of the cache.
+func (w *weightedResyncer) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.track,
+		UpdateFunc: func(_, new interface{}) { w.track(new) },
+		DeleteFunc: w.forget,
+	}
+}
+
+// track schedules the first resync of an object if it's not tracked.
+func (w *weightedResyncer) track(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	rObj, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.next[key]; !ok {
+		w.schedule(key, w.clock.Now().Add(w.interval(rObj)))
+	}
+}
+
+// forget stops tracking a deleted object.
+func (w *weightedResyncer) forget(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.next, key)
+}
+
+// interval returns the resync interval of an object.
+func (w *weightedResyncer) interval(obj runtime.Object) time.Duration {
+	interval := w.classifier(obj)
+	if interval <= 0 {
+		interval = w.defaultInterval
+	}
+	return interval
+}
+
+// schedule sets the next resync of an object key, must be called with the lock acquired.
+func (w *weightedResyncer) schedule(key string, at time.Time) {
+	w.next[key] = at
+	heap.Push(&w.scheduled, scheduledResync{key: key, at: at})
+}
+
+// run resyncs the objects when they are due until the context is done.
+func (w *weightedResyncer) run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.clock.After(weightedResyncTick):
+			w.resyncDue(w.clock.Now())
+		}
+	}
+}
+
+// resyncDue resyncs the objects whose resync time has been reached and schedules their next resync.
+func (w *weightedResyncer) resyncDue(now time.Time) {
+	type dueResync struct {
+		key string
+		obj runtime.Object
+	}
+
+	w.mu.Lock()
+	due := []dueResync{}
+	for len(w.scheduled) > 0 && !now.Before(w.scheduled[0].at) {
+		s := heap.Pop(&w.scheduled).(scheduledResync)
+		if next, ok := w.next[s.key]; !ok || !next.Equal(s.at) {
+			continue
+		}
+
+		obj, exists, err := w.indexer.GetByKey(s.key)
+		if err != nil || !exists {
+			delete(w.next, s.key)
+			continue
+		}
+		rObj, ok := obj.(runtime.Object)
+		if !ok {
+			delete(w.next, s.key)
+			continue
+		}
+
+		due = append(due, dueResync{key: s.key, obj: rObj})
+		w.schedule(s.key, now.Add(w.interval(rObj)))
+	}
+	w.mu.Unlock()
+
+	for _, d := range due {
+		w.resync(d.key, d.obj)
+	}
+}
+
+// scheduledResync is the scheduled resync of an object key.
+type scheduledResync struct {
+	key string
+	at  time.Time
+}
+
+// resyncSchedule is a min-heap of the scheduled resyncs by time.
+type resyncSchedule []scheduledResync
+
+func (r resyncSchedule) Len() int            { return len(r) }
+func (r resyncSchedule) Less(i, j int) bool  { return r[i].at.Before(r[j].at) }
+func (r resyncSchedule) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *resyncSchedule) Push(x interface{}) { *r = append(*r, x.(scheduledResync)) }
+func (r *resyncSchedule) Pop() interface{} {
+	old := *r
+	n := len(old)
+	x := old[n-1]
+	*r = old[:n-1]
+	return x
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
@@ -17,11 +16,9 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 
+	"github.com/spotahome/kooper/v2/client"
 	"github.com/spotahome/kooper/v2/controller"
 	"github.com/spotahome/kooper/v2/controller/leaderelection"
 	"github.com/spotahome/kooper/v2/log"
@@ -62,14 +59,9 @@ func run() error {
 		WithKV(log.KV{"example": "leader-election-controller"})
 
 	// Get k8s client.
-	k8scfg, err := rest.InClusterConfig()
+	k8scfg, err := client.NewRESTConfig(client.Config{})
 	if err != nil {
-		// No in cluster? lets try locally
-		kubehome := filepath.Join(homedir.HomeDir(), ".kube", "config")
-		k8scfg, err = clientcmd.BuildConfigFromFlags("", kubehome)
-		if err != nil {
-			return fmt.Errorf("error loading kubernetes configuration: %s", err)
-		}
+		return err
 	}
 	k8scli, err := kubernetes.NewForConfig(k8scfg)
 	if err != nil {
@@ -7,7 +7,6 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,11 +17,9 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 
+	"github.com/spotahome/kooper/v2/client"
 	"github.com/spotahome/kooper/v2/controller"
 	"github.com/spotahome/kooper/v2/log"
 	kooperlogrus "github.com/spotahome/kooper/v2/log/logrus"
@@ -89,14 +86,9 @@ func run() error {
 	}
 
 	// Get k8s client.
-	k8scfg, err := rest.InClusterConfig()
+	k8scfg, err := client.NewRESTConfig(client.Config{})
 	if err != nil {
-		// No in cluster? letr's try locally
-		kubehome := filepath.Join(homedir.HomeDir(), ".kube", "config")
-		k8scfg, err = clientcmd.BuildConfigFromFlags("", kubehome)
-		if err != nil {
-			return fmt.Errorf("error loading kubernetes configuration: %w", err)
-		}
+		return err
 	}
 	k8scli, err := kubernetes.NewForConfig(k8scfg)
 	if err != nil {
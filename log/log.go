@@ -17,6 +17,31 @@ type Logger interface {
 	WithKV(KV) Logger
 }
 
+// Level is a log level.
+type Level string
+
+// Log levels.
+const (
+	LevelDebug   Level = "debug"
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+)
+
+// Logf logs the message on the logger with the level, unknown levels are logged as errors.
+func Logf(l Logger, level Level, format string, args ...interface{}) {
+	switch level {
+	case LevelDebug:
+		l.Debugf(format, args...)
+	case LevelInfo:
+		l.Infof(format, args...)
+	case LevelWarning:
+		l.Warningf(format, args...)
+	default:
+		l.Errorf(format, args...)
+	}
+}
+
 // Dummy logger doesn't log anything.
 const Dummy = dummy(0)
 
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rateLimitedMsg struct {
+	logf       func(string, ...interface{})
+	msg        string
+	suppressed int
+}
+
+type rateLimitedState struct {
+	mu         sync.Mutex
+	window     time.Duration
+	ignoreKeys map[string]bool
+	msgs       map[string]*rateLimitedMsg
+}
+
+type rateLimited struct {
+	logger Logger
+	kv     string
+	state  *rateLimitedState
+}
+
+// NewRateLimited returns a Logger that will collapse the repeated messages (same level, message
+// and KVs) logged within the window into a single line. The repeated messages are counted and
+// when the window ends, the count is logged with the message.
+//
+// The loggers obtained with `WithKV` share the rate limit with the parent logger. The KVs of the
+// ignore keys are not used to collapse the messages (e.g: unique IDs), the collapsed messages are
+// logged with the KVs of the first message.
+func NewRateLimited(l Logger, window time.Duration, ignoreKeys ...string) Logger {
+	ignore := map[string]bool{}
+	for _, k := range ignoreKeys {
+		ignore[k] = true
+	}
+
+	return rateLimited{
+		logger: l,
+		state: &rateLimitedState{
+			window:     window,
+			ignoreKeys: ignore,
+			msgs:       map[string]*rateLimitedMsg{},
+		},
+	}
+}
+
+func (r rateLimited) log(level string, logf func(string, ...interface{}), format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	key := level + "\x00" + r.kv + "\x00" + msg
+
+	r.state.mu.Lock()
+	if m, ok := r.state.msgs[key]; ok {
+		m.suppressed++
+		r.state.mu.Unlock()
+		return
+	}
+	r.state.msgs[key] = &rateLimitedMsg{logf: logf, msg: msg}
+	r.state.mu.Unlock()
+
+	// When the window ends, log the repetitions (if any) and allow logging the message again.
+	time.AfterFunc(r.state.window, func() { r.flush(key) })
+
+	logf("%s", msg)
+}
+
+func (r rateLimited) flush(key string) {
+	r.state.mu.Lock()
+	m := r.state.msgs[key]
+	delete(r.state.msgs, key)
+	r.state.mu.Unlock()
+
+	if m != nil && m.suppressed > 0 {
+		m.logf("%s (repeated %d times)", m.msg, m.suppressed)
+	}
+}
+
+func (r rateLimited) Infof(format string, args ...interface{}) {
+	r.log("info", r.logger.Infof, format, args...)
+}
+func (r rateLimited) Warningf(format string, args ...interface{}) {
+	r.log("warning", r.logger.Warningf, format, args...)
+}
+func (r rateLimited) Errorf(format string, args ...interface{}) {
+	r.log("error", r.logger.Errorf, format, args...)
+}
+func (r rateLimited) Debugf(format string, args ...interface{}) {
+	r.log("debug", r.logger.Debugf, format, args...)
+}
+
+func (r rateLimited) WithKV(kv KV) Logger {
+	// Store the KVs so the messages of different KVs (e.g: objects) are not collapsed.
+	kvs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		if r.state.ignoreKeys[k] {
+			continue
+		}
+		kvs = append(kvs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(kvs)
+
+	return rateLimited{
+		logger: r.logger.WithKV(kv),
+		kv:     r.kv + strings.Join(kvs, ",") + ";",
+		state:  r.state,
+	}
+}
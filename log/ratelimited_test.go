@@ -0,0 +1,137 @@
+package log_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spotahome/kooper/v2/log"
+)
+
+// testLogger stores the logged lines.
+type testLogger struct {
+	mu     *sync.Mutex
+	lines  *[]string
+	prefix string
+}
+
+func newTestLogger() testLogger {
+	return testLogger{mu: &sync.Mutex{}, lines: &[]string{}}
+}
+
+func (t testLogger) logf(level, format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*t.lines = append(*t.lines, level+" "+t.prefix+fmt.Sprintf(format, args...))
+}
+
+func (t testLogger) getLines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string{}, *t.lines...)
+}
+
+func (t testLogger) Infof(format string, args ...interface{})    { t.logf("info", format, args...) }
+func (t testLogger) Warningf(format string, args ...interface{}) { t.logf("warning", format, args...) }
+func (t testLogger) Errorf(format string, args ...interface{})   { t.logf("error", format, args...) }
+func (t testLogger) Debugf(format string, args ...interface{})   { t.logf("debug", format, args...) }
+func (t testLogger) WithKV(kv log.KV) log.Logger {
+	for k, v := range kv {
+		t.prefix += fmt.Sprintf("[%s=%v] ", k, v)
+	}
+	return t
+}
+
+func TestRateLimited(t *testing.T) {
+	const window = 50 * time.Millisecond
+
+	tests := map[string]struct {
+		ignoreKeys []string
+		log        func(l log.Logger)
+		expLines   []string
+	}{
+		"Repeated messages within the window should be logged once and the repetitions after the window.": {
+			log: func(l log.Logger) {
+				for i := 0; i < 100; i++ {
+					l.Errorf("error on object: %s", "wanted error")
+				}
+			},
+			expLines: []string{
+				"error error on object: wanted error",
+				"error error on object: wanted error (repeated 99 times)",
+			},
+		},
+
+		"Repeated messages after the window should be logged again.": {
+			log: func(l log.Logger) {
+				l.Errorf("error on object: %s", "wanted error")
+				time.Sleep(2 * window)
+				l.Errorf("error on object: %s", "wanted error")
+			},
+			expLines: []string{
+				"error error on object: wanted error",
+				"error error on object: wanted error",
+			},
+		},
+
+		"Different messages, levels or KVs should not be collapsed.": {
+			log: func(l log.Logger) {
+				l.Errorf("error %d", 1)
+				l.Errorf("error %d", 2)
+				l.Warningf("error %d", 1)
+				l.WithKV(log.KV{"k": "v"}).Errorf("error %d", 1)
+			},
+			expLines: []string{
+				"error error 1",
+				"error error 2",
+				"warning error 1",
+				"error [k=v] error 1",
+			},
+		},
+
+		"Repeated messages of different objects at the same time should be counted per object.": {
+			log: func(l log.Logger) {
+				for i := 0; i < 10; i++ {
+					l.WithKV(log.KV{"object-key": "a"}).Errorf("error on object processing: %s", "wanted error")
+					if i%2 == 0 {
+						l.WithKV(log.KV{"object-key": "b"}).Errorf("error on object processing: %s", "wanted error")
+					}
+				}
+			},
+			expLines: []string{
+				"error [object-key=a] error on object processing: wanted error",
+				"error [object-key=b] error on object processing: wanted error",
+				"error [object-key=a] error on object processing: wanted error (repeated 9 times)",
+				"error [object-key=b] error on object processing: wanted error (repeated 4 times)",
+			},
+		},
+
+		"Repeated messages with different ignored KVs should be collapsed with the first message KVs.": {
+			ignoreKeys: []string{"request-id"},
+			log: func(l log.Logger) {
+				for i := 0; i < 10; i++ {
+					l.WithKV(log.KV{"object-key": "a"}).WithKV(log.KV{"request-id": i}).Errorf("error on object processing: %s", "wanted error")
+				}
+			},
+			expLines: []string{
+				"error [object-key=a] [request-id=0] error on object processing: wanted error",
+				"error [object-key=a] [request-id=0] error on object processing: wanted error (repeated 9 times)",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tl := newTestLogger()
+			test.log(log.NewRateLimited(tl, window, test.ignoreKeys...))
+
+			// Wait for the windows to end.
+			assert.Eventually(t, func() bool { return len(tl.getLines()) == len(test.expLines) }, 1*time.Second, 5*time.Millisecond)
+			time.Sleep(2 * window)
+			assert.ElementsMatch(t, test.expLines, tl.getLines())
+		})
+	}
+}
@@ -2,22 +2,26 @@ package prometheus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spotahome/kooper/v2/controller"
+	"github.com/spotahome/kooper/v2/controller/leaderelection"
 )
 
 const (
-	promNamespace           = "kooper"
-	promControllerSubsystem = "controller"
+	promNamespace               = "kooper"
+	promControllerSubsystem     = "controller"
+	promLeaderElectionSubsystem = "leader_election"
 )
 
 // Config is the Recorder Config.
 type Config struct {
-	// Registerer is a prometheus registerer, e.g: prometheus.Registry.
+	// Registerer is a prometheus registerer, e.g: prometheus.Registry or controller-runtime
+	// `metrics.Registry` to expose the metrics on the same endpoint as the controller-runtime ones.
 	// By default will use Prometheus default registry.
 	Registerer prometheus.Registerer
 	// InQueueBuckets sets custom buckets for the duration/latency items in queue metrics.
@@ -26,6 +30,19 @@ type Config struct {
 	// ProcessingBuckets sets custom buckets for the duration/latency processing metrics.
 	// Check https://godoc.org/github.com/prometheus/client_golang/prometheus#pkg-variables
 	ProcessingBuckets []float64
+	// AgeBuckets sets custom object age buckets for the processing duration by object age metrics, the
+	// objects are labeled with the lowest bucket that is greater or equal than their age (`+Inf` if
+	// none).
+	AgeBuckets []time.Duration
+	// LeaderElectionBuckets sets custom buckets for the leader election acquisition duration metrics.
+	// Check https://godoc.org/github.com/prometheus/client_golang/prometheus#pkg-variables
+	LeaderElectionBuckets []float64
+	// ExemplarFromContext returns the exemplar labels of the processing durations from the processing context
+	// (e.g: the `trace_id` of the active span) to link the metrics with the traces, no labels means no exemplar.
+	// Start the spans with the controller `ContextFactory` so they are active on the processing context. The
+	// exemplars are only exposed with the OpenMetrics format (e.g: `promhttp.HandlerOpts.EnableOpenMetrics`).
+	// By default the exemplars are disabled.
+	ExemplarFromContext func(ctx context.Context) prometheus.Labels
 }
 
 func (c *Config) defaults() {
@@ -42,15 +59,34 @@ func (c *Config) defaults() {
 	if c.ProcessingBuckets == nil || len(c.ProcessingBuckets) == 0 {
 		c.ProcessingBuckets = prometheus.DefBuckets
 	}
+
+	if len(c.AgeBuckets) == 0 {
+		c.AgeBuckets = []time.Duration{time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour}
+	}
+
+	if c.LeaderElectionBuckets == nil || len(c.LeaderElectionBuckets) == 0 {
+		// Acquiring the leadership can take the lease duration of the previous leader.
+		c.LeaderElectionBuckets = []float64{.1, .5, 1, 3, 5, 10, 15, 30, 60, 120, 300}
+	}
 }
 
 // Recorder implements the metrics recording in a prometheus registry.
 type Recorder struct {
-	reg prometheus.Registerer
+	reg        prometheus.Registerer
+	ageBuckets []time.Duration
+	exemplar   func(ctx context.Context) prometheus.Labels
 
 	queuedEventsTotal      *prometheus.CounterVec
 	inQueueEventDuration   *prometheus.HistogramVec
 	processedEventDuration *prometheus.HistogramVec
+	reconcileErrorsTotal   *prometheus.CounterVec
+	reconcileOutcomesTotal *prometheus.CounterVec
+	processedByAgeDuration *prometheus.HistogramVec
+	reconcilesInFlight     *prometheus.GaugeVec
+	reconcilesTotal        *prometheus.CounterVec
+
+	leaderAcquisitionDuration *prometheus.HistogramVec
+	leaderTransitionsTotal    *prometheus.CounterVec
 }
 
 // New returns a new Prometheus implementaiton for a metrics recorder.
@@ -58,7 +94,9 @@ func New(cfg Config) *Recorder {
 	cfg.defaults()
 
 	r := &Recorder{
-		reg: cfg.Registerer,
+		reg:        cfg.Registerer,
+		ageBuckets: cfg.AgeBuckets,
+		exemplar:   cfg.ExemplarFromContext,
 
 		queuedEventsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: promNamespace,
@@ -82,17 +120,90 @@ func New(cfg Config) *Recorder {
 			Help:      "The duration for an event to be processed.",
 			Buckets:   cfg.ProcessingBuckets,
 		}, []string{"controller", "success"}),
+
+		reconcileErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "reconcile_errors_total",
+			Help:      "Total number of handler errors by category.",
+		}, []string{"controller", "category"}),
+
+		reconcileOutcomesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "reconcile_outcomes_total",
+			Help:      "Total number of handler results by outcome.",
+		}, []string{"controller", "outcome"}),
+
+		processedByAgeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "processed_event_duration_by_age_seconds",
+			Help:      "The duration for an event to be processed by the age of the object.",
+			Buckets:   cfg.ProcessingBuckets,
+		}, []string{"controller", "age"}),
+
+		reconcilesInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "reconciles_in_flight",
+			Help:      "Number of events being processed.",
+		}, []string{"controller"}),
+
+		reconcilesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "reconciles_total",
+			Help:      "Total number of events processed by trigger.",
+		}, []string{"controller", "trigger"}),
+
+		leaderAcquisitionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Subsystem: promLeaderElectionSubsystem,
+			Name:      "acquisition_duration_seconds",
+			Help:      "The duration to acquire the leadership since the leader election started.",
+			Buckets:   cfg.LeaderElectionBuckets,
+		}, []string{"id"}),
+
+		leaderTransitionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promLeaderElectionSubsystem,
+			Name:      "transitions_total",
+			Help:      "Total number of leadership transitions (new leaders) observed.",
+		}, []string{"id"}),
 	}
 
-	// Register metrics.
-	r.reg.MustRegister(
-		r.queuedEventsTotal,
-		r.inQueueEventDuration,
-		r.processedEventDuration)
+	// Register metrics, if already registered (e.g: shared registries) reuse the registered ones.
+	r.queuedEventsTotal = r.register(r.queuedEventsTotal).(*prometheus.CounterVec)
+	r.inQueueEventDuration = r.register(r.inQueueEventDuration).(*prometheus.HistogramVec)
+	r.processedEventDuration = r.register(r.processedEventDuration).(*prometheus.HistogramVec)
+	r.reconcileErrorsTotal = r.register(r.reconcileErrorsTotal).(*prometheus.CounterVec)
+	r.reconcileOutcomesTotal = r.register(r.reconcileOutcomesTotal).(*prometheus.CounterVec)
+	r.processedByAgeDuration = r.register(r.processedByAgeDuration).(*prometheus.HistogramVec)
+	r.reconcilesInFlight = r.register(r.reconcilesInFlight).(*prometheus.GaugeVec)
+	r.reconcilesTotal = r.register(r.reconcilesTotal).(*prometheus.CounterVec)
+	r.leaderAcquisitionDuration = r.register(r.leaderAcquisitionDuration).(*prometheus.HistogramVec)
+	r.leaderTransitionsTotal = r.register(r.leaderTransitionsTotal).(*prometheus.CounterVec)
 
 	return r
 }
 
+// register registers the collector on the registry, if an equal collector has already been
+// registered, it will return the registered one.
+func (r *Recorder) register(c prometheus.Collector) prometheus.Collector {
+	err := r.reg.Register(c)
+	if err == nil {
+		return c
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector
+	}
+
+	panic(err)
+}
+
 // IncResourceEventQueued satisfies controller.MetricsRecorder interface.
 func (r Recorder) IncResourceEventQueued(ctx context.Context, controller string, isRequeue bool) {
 	r.queuedEventsTotal.WithLabelValues(controller, strconv.FormatBool(isRequeue)).Inc()
@@ -106,10 +217,62 @@ func (r Recorder) ObserveResourceInQueueDuration(ctx context.Context, controller
 
 // ObserveResourceProcessingDuration satisfies controller.MetricsRecorder interface.
 func (r Recorder) ObserveResourceProcessingDuration(ctx context.Context, controller string, success bool, startProcessingAt time.Time) {
-	r.processedEventDuration.WithLabelValues(controller, strconv.FormatBool(success)).
+	obs := r.processedEventDuration.WithLabelValues(controller, strconv.FormatBool(success))
+	duration := time.Since(startProcessingAt).Seconds()
+
+	if r.exemplar != nil {
+		if labels := r.exemplar(ctx); len(labels) > 0 {
+			if eobs, ok := obs.(prometheus.ExemplarObserver); ok {
+				eobs.ObserveWithExemplar(duration, labels)
+				return
+			}
+		}
+	}
+
+	obs.Observe(duration)
+}
+
+// IncResourceProcessingError satisfies controller.ErrorMetricsRecorder interface.
+func (r Recorder) IncResourceProcessingError(ctx context.Context, controller string, category string) {
+	r.reconcileErrorsTotal.WithLabelValues(controller, category).Inc()
+}
+
+// IncResourceProcessingOutcome satisfies controller.OutcomeMetricsRecorder interface.
+func (r Recorder) IncResourceProcessingOutcome(ctx context.Context, controller string, outcome string) {
+	r.reconcileOutcomesTotal.WithLabelValues(controller, outcome).Inc()
+}
+
+// ObserveResourceProcessingDurationByAge satisfies controller.AgeMetricsRecorder interface.
+func (r Recorder) ObserveResourceProcessingDurationByAge(ctx context.Context, controller string, age time.Duration, startProcessingAt time.Time) {
+	r.processedByAgeDuration.WithLabelValues(controller, r.ageBucket(age)).
 		Observe(time.Since(startProcessingAt).Seconds())
 }
 
+// IncResourceProcessingInFlight satisfies controller.InFlightMetricsRecorder interface.
+func (r Recorder) IncResourceProcessingInFlight(ctx context.Context, controller string) {
+	r.reconcilesInFlight.WithLabelValues(controller).Inc()
+}
+
+// DecResourceProcessingInFlight satisfies controller.InFlightMetricsRecorder interface.
+func (r Recorder) DecResourceProcessingInFlight(ctx context.Context, controller string) {
+	r.reconcilesInFlight.WithLabelValues(controller).Dec()
+}
+
+// IncResourceProcessingTrigger satisfies controller.TriggerMetricsRecorder interface.
+func (r Recorder) IncResourceProcessingTrigger(ctx context.Context, controller string, trigger string) {
+	r.reconcilesTotal.WithLabelValues(controller, trigger).Inc()
+}
+
+// ageBucket returns the age label of an object age.
+func (r Recorder) ageBucket(age time.Duration) string {
+	for _, b := range r.ageBuckets {
+		if age <= b {
+			return b.String()
+		}
+	}
+	return "+Inf"
+}
+
 // RegisterResourceQueueLengthFunc satisfies controller.MetricsRecorder interface.
 func (r Recorder) RegisterResourceQueueLengthFunc(controller string, f func(context.Context) int) error {
 	err := r.reg.Register(prometheus.NewGaugeFunc(
@@ -129,5 +292,41 @@ func (r Recorder) RegisterResourceQueueLengthFunc(controller string, f func(cont
 	return nil
 }
 
+// RegisterResourceCacheLengthFunc satisfies controller.CacheMetricsRecorder interface.
+func (r Recorder) RegisterResourceCacheLengthFunc(controller string, f func(context.Context) int) error {
+	err := r.reg.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   promNamespace,
+			Subsystem:   promControllerSubsystem,
+			Name:        "cache_objects",
+			Help:        "Number of objects in the controller cache.",
+			ConstLabels: prometheus.Labels{"controller": controller},
+		},
+		func() float64 { return float64(f(context.Background())) },
+	))
+	if err != nil {
+		return fmt.Errorf("could not register ResourceCacheLengthFunc metrics: %w", err)
+	}
+
+	return nil
+}
+
+// ObserveLeaderElectionAcquisitionDuration satisfies leaderelection.MetricsRecorder interface.
+func (r Recorder) ObserveLeaderElectionAcquisitionDuration(ctx context.Context, id string, startedAt time.Time) {
+	r.leaderAcquisitionDuration.WithLabelValues(id).Observe(time.Since(startedAt).Seconds())
+}
+
+// IncLeaderElectionTransition satisfies leaderelection.MetricsRecorder interface.
+func (r Recorder) IncLeaderElectionTransition(ctx context.Context, id string) {
+	r.leaderTransitionsTotal.WithLabelValues(id).Inc()
+}
+
 // Check interfaces implementation.
 var _ controller.MetricsRecorder = &Recorder{}
+var _ controller.ErrorMetricsRecorder = &Recorder{}
+var _ controller.OutcomeMetricsRecorder = &Recorder{}
+var _ controller.CacheMetricsRecorder = &Recorder{}
+var _ controller.AgeMetricsRecorder = &Recorder{}
+var _ controller.InFlightMetricsRecorder = &Recorder{}
+var _ controller.TriggerMetricsRecorder = &Recorder{}
+var _ leaderelection.MetricsRecorder = &Recorder{}
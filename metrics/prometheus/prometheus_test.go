@@ -10,6 +10,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	kooperprometheus "github.com/spotahome/kooper/v2/metrics/prometheus"
 )
@@ -199,6 +201,132 @@ func TestPrometheusRecorder(t *testing.T) {
 			},
 		},
 
+		"Incrementing the handler errors should record the metrics by category.": {
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				r.IncResourceProcessingError(ctx, "ctrl1", "conflict")
+				r.IncResourceProcessingError(ctx, "ctrl1", "conflict")
+				r.IncResourceProcessingError(ctx, "ctrl1", "other")
+				r.IncResourceProcessingError(ctx, "ctrl2", "timeout")
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_reconcile_errors_total Total number of handler errors by category.`,
+				`# TYPE kooper_controller_reconcile_errors_total counter`,
+
+				`kooper_controller_reconcile_errors_total{category="conflict",controller="ctrl1"} 2`,
+				`kooper_controller_reconcile_errors_total{category="other",controller="ctrl1"} 1`,
+				`kooper_controller_reconcile_errors_total{category="timeout",controller="ctrl2"} 1`,
+			},
+		},
+
+		"Incrementing the handler outcomes should record the metrics by outcome.": {
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				r.IncResourceProcessingOutcome(ctx, "ctrl1", "created")
+				r.IncResourceProcessingOutcome(ctx, "ctrl1", "noop")
+				r.IncResourceProcessingOutcome(ctx, "ctrl1", "noop")
+				r.IncResourceProcessingOutcome(ctx, "ctrl2", "updated")
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_reconcile_outcomes_total Total number of handler results by outcome.`,
+				`# TYPE kooper_controller_reconcile_outcomes_total counter`,
+
+				`kooper_controller_reconcile_outcomes_total{controller="ctrl1",outcome="created"} 1`,
+				`kooper_controller_reconcile_outcomes_total{controller="ctrl1",outcome="noop"} 2`,
+				`kooper_controller_reconcile_outcomes_total{controller="ctrl2",outcome="updated"} 1`,
+			},
+		},
+
+		"Observing the processing duration by object age should record the metrics by age bucket.": {
+			cfg: kooperprometheus.Config{
+				ProcessingBuckets: []float64{1, 5},
+				AgeBuckets:        []time.Duration{time.Minute, time.Hour},
+			},
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				t0 := time.Now()
+				r.ObserveResourceProcessingDurationByAge(ctx, "ctrl1", 30*time.Second, t0.Add(-2*time.Second))
+				r.ObserveResourceProcessingDurationByAge(ctx, "ctrl1", time.Minute, t0.Add(-500*time.Millisecond))
+				r.ObserveResourceProcessingDurationByAge(ctx, "ctrl1", 10*time.Minute, t0.Add(-500*time.Millisecond))
+				r.ObserveResourceProcessingDurationByAge(ctx, "ctrl1", 2*time.Hour, t0.Add(-6*time.Second))
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_processed_event_duration_by_age_seconds The duration for an event to be processed by the age of the object.`,
+				`# TYPE kooper_controller_processed_event_duration_by_age_seconds histogram`,
+
+				`kooper_controller_processed_event_duration_by_age_seconds_bucket{age="1m0s",controller="ctrl1",le="1"} 1`,
+				`kooper_controller_processed_event_duration_by_age_seconds_bucket{age="1m0s",controller="ctrl1",le="5"} 2`,
+				`kooper_controller_processed_event_duration_by_age_seconds_count{age="1m0s",controller="ctrl1"} 2`,
+
+				`kooper_controller_processed_event_duration_by_age_seconds_bucket{age="1h0m0s",controller="ctrl1",le="1"} 1`,
+				`kooper_controller_processed_event_duration_by_age_seconds_count{age="1h0m0s",controller="ctrl1"} 1`,
+
+				`kooper_controller_processed_event_duration_by_age_seconds_bucket{age="+Inf",controller="ctrl1",le="5"} 0`,
+				`kooper_controller_processed_event_duration_by_age_seconds_count{age="+Inf",controller="ctrl1"} 1`,
+			},
+		},
+
+		"Observing the leader election acquisition duration and transitions should record the metrics.": {
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				t0 := time.Now()
+				r.ObserveLeaderElectionAcquisitionDuration(ctx, "ns1/ctrl1", t0.Add(-2*time.Second))
+				r.ObserveLeaderElectionAcquisitionDuration(ctx, "ns1/ctrl1", t0.Add(-20*time.Second))
+				r.IncLeaderElectionTransition(ctx, "ns1/ctrl1")
+				r.IncLeaderElectionTransition(ctx, "ns1/ctrl1")
+				r.IncLeaderElectionTransition(ctx, "ns2/ctrl2")
+			},
+			expMetrics: []string{
+				`# HELP kooper_leader_election_acquisition_duration_seconds The duration to acquire the leadership since the leader election started.`,
+				`# TYPE kooper_leader_election_acquisition_duration_seconds histogram`,
+				`kooper_leader_election_acquisition_duration_seconds_bucket{id="ns1/ctrl1",le="1"} 0`,
+				`kooper_leader_election_acquisition_duration_seconds_bucket{id="ns1/ctrl1",le="3"} 1`,
+				`kooper_leader_election_acquisition_duration_seconds_bucket{id="ns1/ctrl1",le="15"} 1`,
+				`kooper_leader_election_acquisition_duration_seconds_bucket{id="ns1/ctrl1",le="30"} 2`,
+				`kooper_leader_election_acquisition_duration_seconds_count{id="ns1/ctrl1"} 2`,
+
+				`# HELP kooper_leader_election_transitions_total Total number of leadership transitions (new leaders) observed.`,
+				`# TYPE kooper_leader_election_transitions_total counter`,
+				`kooper_leader_election_transitions_total{id="ns1/ctrl1"} 2`,
+				`kooper_leader_election_transitions_total{id="ns2/ctrl2"} 1`,
+			},
+		},
+
+		"Incrementing and decrementing the in flight events should record the metrics.": {
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				r.IncResourceProcessingInFlight(ctx, "ctrl1")
+				r.IncResourceProcessingInFlight(ctx, "ctrl1")
+				r.IncResourceProcessingInFlight(ctx, "ctrl1")
+				r.DecResourceProcessingInFlight(ctx, "ctrl1")
+				r.IncResourceProcessingInFlight(ctx, "ctrl2")
+				r.DecResourceProcessingInFlight(ctx, "ctrl2")
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_reconciles_in_flight Number of events being processed.`,
+				`# TYPE kooper_controller_reconciles_in_flight gauge`,
+				`kooper_controller_reconciles_in_flight{controller="ctrl1"} 2`,
+				`kooper_controller_reconciles_in_flight{controller="ctrl2"} 0`,
+			},
+		},
+
+		"Incrementing the processing triggers should record the metrics.": {
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				ctx := context.TODO()
+				r.IncResourceProcessingTrigger(ctx, "ctrl1", "add")
+				r.IncResourceProcessingTrigger(ctx, "ctrl1", "add")
+				r.IncResourceProcessingTrigger(ctx, "ctrl1", "resync")
+				r.IncResourceProcessingTrigger(ctx, "ctrl2", "delete")
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_reconciles_total Total number of events processed by trigger.`,
+				`# TYPE kooper_controller_reconciles_total counter`,
+				`kooper_controller_reconciles_total{controller="ctrl1",trigger="add"} 2`,
+				`kooper_controller_reconciles_total{controller="ctrl1",trigger="resync"} 1`,
+				`kooper_controller_reconciles_total{controller="ctrl2",trigger="delete"} 1`,
+			},
+		},
+
 		"Registering resource queue lenght function should measure the size of the queue.": {
 			cfg: kooperprometheus.Config{},
 			addMetrics: func(r *kooperprometheus.Recorder) {
@@ -214,6 +342,20 @@ func TestPrometheusRecorder(t *testing.T) {
 				`kooper_controller_event_queue_length{controller="ctrl3"} 242`,
 			},
 		},
+
+		"Registering resource cache length function should measure the objects of the cache.": {
+			cfg: kooperprometheus.Config{},
+			addMetrics: func(r *kooperprometheus.Recorder) {
+				_ = r.RegisterResourceCacheLengthFunc("ctrl1", func(_ context.Context) int { return 42 })
+				_ = r.RegisterResourceCacheLengthFunc("ctrl2", func(_ context.Context) int { return 142 })
+			},
+			expMetrics: []string{
+				`# HELP kooper_controller_cache_objects Number of objects in the controller cache.`,
+				`# TYPE kooper_controller_cache_objects gauge`,
+				`kooper_controller_cache_objects{controller="ctrl1"} 42`,
+				`kooper_controller_cache_objects{controller="ctrl2"} 142`,
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -243,3 +385,103 @@ func TestPrometheusRecorder(t *testing.T) {
 		})
 	}
 }
+
+func TestPrometheusRecorderControllerRuntimeRegistry(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Gets the kooper queued events metric from the controller-runtime registry.
+	getQueued := func() float64 {
+		mfs, err := ctrlmetrics.Registry.Gather()
+		require.NoError(err)
+		for _, mf := range mfs {
+			if mf.GetName() != "kooper_controller_queued_events_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				if m.GetLabel()[0].GetValue() == "ctrl-runtime-test" {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+
+	// Using the controller-runtime shared registry, creating multiple recorders should reuse the collectors.
+	r1 := kooperprometheus.New(kooperprometheus.Config{Registerer: ctrlmetrics.Registry})
+	r2 := kooperprometheus.New(kooperprometheus.Config{Registerer: ctrlmetrics.Registry})
+
+	start := getQueued()
+	r1.IncResourceEventQueued(context.TODO(), "ctrl-runtime-test", false)
+	assert.Equal(start+1, getQueued())
+	r2.IncResourceEventQueued(context.TODO(), "ctrl-runtime-test", false)
+	assert.Equal(start+2, getQueued())
+}
+
+func TestPrometheusRecorderExemplars(t *testing.T) {
+	type traceIDKey struct{}
+
+	tests := map[string]struct {
+		exemplarFromContext func(ctx context.Context) prometheus.Labels
+		ctx                 context.Context
+		expExemplar         map[string]string
+	}{
+		"Without exemplars, the processing durations should not have exemplars.": {
+			ctx: context.WithValue(context.TODO(), traceIDKey{}, "4bf92f3577b34da6a3ce929d0e0e4736"),
+		},
+
+		"With exemplars and an active trace, the processing durations should have the trace exemplar.": {
+			exemplarFromContext: func(ctx context.Context) prometheus.Labels {
+				id, _ := ctx.Value(traceIDKey{}).(string)
+				if id == "" {
+					return nil
+				}
+				return prometheus.Labels{"trace_id": id}
+			},
+			ctx:         context.WithValue(context.TODO(), traceIDKey{}, "4bf92f3577b34da6a3ce929d0e0e4736"),
+			expExemplar: map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+
+		"With exemplars and without an active trace, the processing durations should not have exemplars.": {
+			exemplarFromContext: func(ctx context.Context) prometheus.Labels {
+				id, _ := ctx.Value(traceIDKey{}).(string)
+				if id == "" {
+					return nil
+				}
+				return prometheus.Labels{"trace_id": id}
+			},
+			ctx: context.TODO(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			reg := prometheus.NewRegistry()
+			r := kooperprometheus.New(kooperprometheus.Config{Registerer: reg, ExemplarFromContext: test.exemplarFromContext})
+			r.ObserveResourceProcessingDuration(test.ctx, "ctrl1", true, time.Now().Add(-100*time.Millisecond))
+
+			// Get the exemplars of the processing duration buckets.
+			mfs, err := reg.Gather()
+			require.NoError(err)
+			var gotExemplar map[string]string
+			for _, mf := range mfs {
+				if mf.GetName() != "kooper_controller_processed_event_duration_seconds" {
+					continue
+				}
+				for _, b := range mf.GetMetric()[0].GetHistogram().GetBucket() {
+					if e := b.GetExemplar(); e != nil {
+						gotExemplar = map[string]string{}
+						for _, l := range e.GetLabel() {
+							gotExemplar[l.GetName()] = l.GetValue()
+						}
+					}
+				}
+			}
+
+			assert.Equal(test.expExemplar, gotExemplar)
+		})
+	}
+}